@@ -4,6 +4,7 @@ package webrtc
 
 import (
 	"github.com/pion/logging"
+	"github.com/pion/webrtc/v2/pkg/interceptor"
 )
 
 // API bundles the global funcions of the WebRTC and ORTC API.
@@ -11,8 +12,9 @@ import (
 // defaultAPI object. Note that the global version of the API
 // may be phased out in the future.
 type API struct {
-	settingEngine *SettingEngine
-	mediaEngine   *MediaEngine
+	settingEngine       *SettingEngine
+	mediaEngine         *MediaEngine
+	interceptorRegistry *interceptor.Registry
 }
 
 // NewAPI Creates a new API object for keeping semi-global settings to WebRTC objects
@@ -35,6 +37,10 @@ func NewAPI(options ...func(*API)) *API {
 		a.mediaEngine = &MediaEngine{}
 	}
 
+	if a.interceptorRegistry == nil {
+		a.interceptorRegistry = &interceptor.Registry{}
+	}
+
 	return a
 }
 
@@ -46,6 +52,15 @@ func WithMediaEngine(m MediaEngine) func(a *API) {
 	}
 }
 
+// WithInterceptorRegistry allows providing the interceptors of the
+// PeerConnections to the API. Every PeerConnection gets its own instances.
+// Interceptors should not be added after passing the registry to an API.
+func WithInterceptorRegistry(r interceptor.Registry) func(a *API) {
+	return func(a *API) {
+		a.interceptorRegistry = &r
+	}
+}
+
 // WithSettingEngine allows providing a SettingEngine to the API.
 // Settings should not be changed after passing the engine to an API.
 func WithSettingEngine(s SettingEngine) func(a *API) {
@@ -0,0 +1,215 @@
+// +build !js
+
+package webrtc
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+)
+
+const (
+	// bandwidthEstimatorHistorySize is how many sent packets are kept until
+	// their feedback arrives
+	bandwidthEstimatorHistorySize = 1 << 12
+
+	// The target bitrates in bits per second
+	initialTargetBitrate = 300000
+	minTargetBitrate     = 30000
+	maxTargetBitrate     = 10000000
+
+	// The loss of the packets sent during lossInterval changes the loss
+	// based bitrate
+	// https://tools.ietf.org/html/draft-ietf-rmcat-gcc-02#section-6
+	lossInterval          = time.Second
+	lossIncreaseThreshold = 0.02
+	lossDecreaseThreshold = 0.1
+	lossIncreaseFactor    = 1.05
+)
+
+// sentPacket is a packet sent with a transport-wide sequence number
+type sentPacket struct {
+	sequenceNumber uint16
+	sent           time.Time
+	size           int
+	valid          bool
+}
+
+// bandwidthEstimator numbers the packets sent on a transport and estimates
+// the bitrate they can be sent with from the transport-cc feedback for
+// them. The target bitrate is the lower one of a delay based and a loss
+// based estimate, like in Google Congestion Control. The bitrate of REMB
+// feedback limits it too, without transport-cc feedback it is the target.
+// https://tools.ietf.org/html/draft-ietf-rmcat-gcc-02
+type bandwidthEstimator struct {
+	lock sync.Mutex
+
+	sequenceNumber uint16
+	history        []sentPacket
+
+	// Feedback is delivered to the RTPSender of every media source that is
+	// reported on with it, it is only handled once
+	hasFeedback   bool
+	feedbackCount uint8
+
+	delay delayBasedEstimator
+
+	lossStart     time.Time
+	lost          int
+	received      int
+	lossBitrate   float64
+	rembBitrate   float64
+	targetBitrate uint64
+
+	onTargetBitrateChangeHandler func(bitrate uint64)
+}
+
+func newBandwidthEstimator() *bandwidthEstimator {
+	return &bandwidthEstimator{
+		history:       make([]sentPacket, bandwidthEstimatorHistorySize),
+		delay:         newDelayBasedEstimator(initialTargetBitrate),
+		lossBitrate:   maxTargetBitrate,
+		rembBitrate:   maxTargetBitrate,
+		targetBitrate: initialTargetBitrate,
+	}
+}
+
+// onTargetBitrateChange sets the handler that is called with the target
+// bitrate when it changes
+func (e *bandwidthEstimator) onTargetBitrateChange(f func(bitrate uint64)) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.onTargetBitrateChangeHandler = f
+}
+
+// getTargetBitrate returns the current target bitrate in bits per second
+func (e *bandwidthEstimator) getTargetBitrate() uint64 {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.targetBitrate
+}
+
+// sent returns the transport-wide sequence number of a packet of size that
+// is sent at now
+func (e *bandwidthEstimator) sent(size int, now time.Time) uint16 {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	sequenceNumber := e.sequenceNumber
+	e.sequenceNumber++
+	e.history[sequenceNumber%bandwidthEstimatorHistorySize] = sentPacket{
+		sequenceNumber: sequenceNumber,
+		sent:           now,
+		size:           size,
+		valid:          true,
+	}
+	return sequenceNumber
+}
+
+// handleFeedback updates the target bitrate with transport-cc feedback that
+// arrived at now
+func (e *bandwidthEstimator) handleFeedback(f *rtcp.TransportLayerCC, now time.Time) {
+	e.lock.Lock()
+
+	if e.hasFeedback && e.feedbackCount == f.FbPktCount {
+		e.lock.Unlock()
+		return
+	}
+	e.hasFeedback = true
+	e.feedbackCount = f.FbPktCount
+
+	var firstArrival, lastArrival time.Duration
+	acknowledged := 0
+	arrival := transportCCReferenceTime(f)
+	for i, status := range transportCCStatuses(f) {
+		if status.received {
+			arrival += time.Duration(status.delta) * transportCCDeltaUnit
+		}
+
+		sequenceNumber := f.BaseSequenceNumber + uint16(i)
+		p := &e.history[sequenceNumber%bandwidthEstimatorHistorySize]
+		if !p.valid || p.sequenceNumber != sequenceNumber {
+			continue
+		}
+
+		if !status.received {
+			e.lost++
+			continue
+		}
+		p.valid = false
+		e.received++
+
+		if acknowledged == 0 {
+			firstArrival = arrival
+		}
+		lastArrival = arrival
+		acknowledged += p.size
+		e.delay.addPacket(p.sent, arrival, now)
+	}
+
+	// The bitrate the packets arrived with is measured over at least the
+	// feedback interval
+	if acknowledged != 0 {
+		interval := lastArrival - firstArrival
+		if interval < transportCCInterval {
+			interval = transportCCInterval
+		}
+		e.delay.incoming = float64(acknowledged*8) / interval.Seconds()
+	}
+
+	e.updateBitrates(now)
+	e.updateTargetBitrate()
+}
+
+// handleREMB updates the target bitrate with the bitrate of REMB feedback
+// https://tools.ietf.org/html/draft-alvestrand-rmcat-remb-03#section-2
+func (e *bandwidthEstimator) handleREMB(bitrate uint64) {
+	e.lock.Lock()
+	e.rembBitrate = clampBitrate(float64(bitrate))
+	e.updateTargetBitrate()
+}
+
+// updateTargetBitrate sets the target bitrate from the estimates and calls
+// the handler if it changed, the lock is held when it is called and
+// released before the handler is called
+func (e *bandwidthEstimator) updateTargetBitrate() {
+	target := uint64(e.rembBitrate)
+	if e.hasFeedback {
+		target = uint64(math.Min(math.Min(e.delay.bitrate, e.lossBitrate), e.rembBitrate))
+	}
+	changed := target != e.targetBitrate
+	e.targetBitrate = target
+	handler := e.onTargetBitrateChangeHandler
+	e.lock.Unlock()
+
+	if changed && handler != nil {
+		handler(target)
+	}
+}
+
+// updateBitrates updates the delay based bitrate and the loss based
+// bitrate once per lossInterval
+func (e *bandwidthEstimator) updateBitrates(now time.Time) {
+	e.delay.update(now)
+
+	if e.lossStart.IsZero() {
+		e.lossStart = now
+	}
+	if now.Sub(e.lossStart) < lossInterval || e.lost+e.received == 0 {
+		return
+	}
+
+	// The loss based bitrate only limits the target after loss, it goes
+	// down from the target and recovers slowly
+	loss := float64(e.lost) / float64(e.lost+e.received)
+	switch {
+	case loss > lossDecreaseThreshold:
+		e.lossBitrate = math.Min(e.lossBitrate, e.delay.bitrate) * (1 - 0.5*loss)
+	case loss < lossIncreaseThreshold:
+		e.lossBitrate *= lossIncreaseFactor
+	}
+	e.lossBitrate = clampBitrate(e.lossBitrate)
+	e.lossStart, e.lost, e.received = now, 0, 0
+}
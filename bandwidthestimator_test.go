@@ -0,0 +1,120 @@
+// +build !js
+
+package webrtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// bandwidthEstimatorTestLink sends packets of 1200 bytes every 10ms, about
+// 1Mbit/s, over a link with capacity bits per second that drops the packets
+// lost tells it to
+type bandwidthEstimatorTestLink struct {
+	estimator *bandwidthEstimator
+	recorder  *transportCCRecorder
+	now       time.Time
+	free      time.Time
+	packets   int
+}
+
+func newBandwidthEstimatorTestLink() *bandwidthEstimatorTestLink {
+	recorder := newTransportCCRecorder()
+	return &bandwidthEstimatorTestLink{
+		estimator: newBandwidthEstimator(),
+		recorder:  recorder,
+		now:       recorder.start,
+	}
+}
+
+func (l *bandwidthEstimatorTestLink) run(duration time.Duration, capacity float64, lost func(i int) bool) {
+	const size = 1200
+	transmission := time.Duration(size * 8 / capacity * float64(time.Second))
+
+	for end := l.now.Add(duration); l.now.Before(end); l.now = l.now.Add(10 * time.Millisecond) {
+		sequenceNumber := l.estimator.sent(size, l.now)
+		if !lost(l.packets) {
+			arrival := l.now.Add(20 * time.Millisecond)
+			if arrival.Before(l.free) {
+				arrival = l.free
+			}
+			arrival = arrival.Add(transmission)
+			l.free = arrival
+			l.recorder.record(sequenceNumber, arrival)
+		}
+		l.packets++
+
+		if l.packets%10 == 0 {
+			if f := l.recorder.feedback(1, 5000); f != nil {
+				l.estimator.handleFeedback(f, l.now)
+			}
+		}
+	}
+}
+
+func TestBandwidthEstimator(t *testing.T) {
+	noLoss := func(int) bool { return false }
+
+	l := newBandwidthEstimatorTestLink()
+	changes := []uint64{}
+	l.estimator.onTargetBitrateChange(func(bitrate uint64) {
+		changes = append(changes, bitrate)
+	})
+	assert.Equal(t, uint64(initialTargetBitrate), l.estimator.getTargetBitrate())
+
+	// Without congestion the target grows up to what is sent
+	l.run(20*time.Second, 5000000, noLoss)
+	assert.True(t, l.estimator.getTargetBitrate() > 900000, l.estimator.getTargetBitrate())
+	assert.NotEmpty(t, changes)
+	assert.Equal(t, l.estimator.getTargetBitrate(), changes[len(changes)-1])
+
+	// The queue of a link with less capacity delays the packets more and
+	// more, the target goes down below its capacity
+	l.run(5*time.Second, 500000, noLoss)
+	assert.True(t, l.estimator.getTargetBitrate() < 500000, l.estimator.getTargetBitrate())
+}
+
+func TestBandwidthEstimator_Loss(t *testing.T) {
+	l := newBandwidthEstimatorTestLink()
+
+	// Every fourth packet is lost
+	l.run(5*time.Second, 5000000, func(i int) bool { return i%4 == 0 })
+	assert.True(t, l.estimator.getTargetBitrate() < initialTargetBitrate, l.estimator.getTargetBitrate())
+	assert.True(t, l.estimator.getTargetBitrate() >= minTargetBitrate)
+}
+
+func TestBandwidthEstimator_DuplicateFeedback(t *testing.T) {
+	e := newBandwidthEstimator()
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		e.sent(1200, now)
+	}
+
+	// Feedback reaches the RTPSender of every media source it reports on
+	f := newTransportLayerCC(1, 5000, 0, 0, 0, []transportCCStatus{{}, {}, {received: true}})
+	e.handleFeedback(f, now)
+	e.handleFeedback(f, now)
+	assert.Equal(t, 2, e.lost)
+	assert.Equal(t, 1, e.received)
+}
+
+func TestBandwidthEstimator_REMB(t *testing.T) {
+	// Without transport-cc feedback the bitrate of REMB feedback is the
+	// target
+	l := newBandwidthEstimatorTestLink()
+	changes := []uint64{}
+	l.estimator.onTargetBitrateChange(func(bitrate uint64) {
+		changes = append(changes, bitrate)
+	})
+	l.estimator.handleREMB(2000000)
+	assert.Equal(t, uint64(2000000), l.estimator.getTargetBitrate())
+	l.estimator.handleREMB(2000000)
+	assert.Equal(t, []uint64{2000000}, changes)
+
+	// With it the target doesn't exceed the bitrate of REMB feedback
+	l.estimator.handleREMB(600000)
+	l.run(20*time.Second, 5000000, func(int) bool { return false })
+	assert.Equal(t, uint64(600000), l.estimator.getTargetBitrate())
+}
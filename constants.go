@@ -1,5 +1,7 @@
 package webrtc
 
+import "time"
+
 const (
 	// Unknown defines default public constant to use for "enum" like struct
 	// comparisons when no value was defined.
@@ -7,4 +9,21 @@ const (
 	unknownStr = "unknown"
 
 	receiveMTU = 8192
+
+	// rtcpBufferSize is the number of bytes of RTCP an RTPSender or an
+	// RTPReceiver buffers for the application before dropping packets
+	rtcpBufferSize = 100 * 1000
+
+	// rtpBufferSize is the number of bytes of RTP an RTPReceiver buffers for
+	// an encoding of the track before dropping packets
+	rtpBufferSize = 1000 * 1000
+
+	// defaultSenderReportInterval is how often an RTPSender sends RTCP Sender
+	// Reports unless configured with SettingEngine.SetSenderReportInterval
+	defaultSenderReportInterval = time.Second
+
+	// defaultReceiverReportInterval is how often an RTPReceiver sends RTCP
+	// Receiver Reports unless configured with
+	// SettingEngine.SetReceiverReportInterval
+	defaultReceiverReportInterval = time.Second
 )
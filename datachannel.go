@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"time"
 
 	"github.com/pion/datachannel"
 	"github.com/pion/logging"
@@ -51,6 +52,13 @@ type DataChannel struct {
 	sctpTransport *SCTPTransport
 	dataChannel   *datachannel.DataChannel
 
+	// The message counters don't include messages of detached data channels
+	statsID          string
+	messagesSent     uint32
+	bytesSent        uint64
+	messagesReceived uint32
+	bytesReceived    uint64
+
 	// A reference to the associated api object used by this datachannel
 	api *API
 	log logging.LeveledLogger
@@ -88,6 +96,7 @@ func (api *API) newDataChannel(params *DataChannelParameters, log logging.Levele
 		maxPacketLifeTime: params.MaxPacketLifeTime,
 		maxRetransmits:    params.MaxRetransmits,
 		readyState:        DataChannelStateConnecting,
+		statsID:           newStatsID("DataChannel"),
 		api:               api,
 		log:               log,
 	}, nil
@@ -293,6 +302,11 @@ func (d *DataChannel) readLoop() {
 			return
 		}
 
+		d.mu.Lock()
+		d.messagesReceived++
+		d.bytesReceived += uint64(n)
+		d.mu.Unlock()
+
 		d.onMessage(DataChannelMessage{Data: buffer[:n], IsString: isString})
 	}
 }
@@ -309,6 +323,7 @@ func (d *DataChannel) Send(data []byte) error {
 	}
 
 	_, err = d.dataChannel.WriteDataChannel(data, false)
+	d.countSent(data, err)
 	return err
 }
 
@@ -325,9 +340,56 @@ func (d *DataChannel) SendText(s string) error {
 	}
 
 	_, err = d.dataChannel.WriteDataChannel(data, true)
+	d.countSent(data, err)
 	return err
 }
 
+func (d *DataChannel) countSent(data []byte, err error) {
+	if err != nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.messagesSent++
+	d.bytesSent += uint64(len(data))
+}
+
+// collectStats returns the stats of the DataChannel
+func (d *DataChannel) collectStats(transportID string) DataChannelStats {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	stats := DataChannelStats{
+		Timestamp:        statsTimestampFrom(time.Now()),
+		Type:             StatsTypeDataChannel,
+		ID:               d.statsID,
+		Label:            d.label,
+		Protocol:         d.protocol,
+		TransportID:      transportID,
+		State:            d.readyState,
+		MessagesSent:     d.messagesSent,
+		BytesSent:        d.bytesSent,
+		MessagesReceived: d.messagesReceived,
+		BytesReceived:    d.bytesReceived,
+	}
+	if d.id != nil {
+		stats.DataChannelIdentifier = int32(*d.id)
+	}
+	return stats
+}
+
+// GetStats returns the stats of the DataChannel
+func (d *DataChannel) GetStats() DataChannelStats {
+	transportID := ""
+	if sctpTransport := d.Transport(); sctpTransport != nil {
+		if dtlsTransport := sctpTransport.Transport(); dtlsTransport != nil {
+			transportID = dtlsTransport.statsID
+		}
+	}
+	return d.collectStats(transportID)
+}
+
 func (d *DataChannel) ensureOpen() error {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
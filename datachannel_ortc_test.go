@@ -117,12 +117,21 @@ func (s *testORTCStack) setSignal(sig *testORTCSignal, isOffer bool) error {
 }
 
 func (s *testORTCStack) getSignal() (*testORTCSignal, error) {
+	gatherFinished := make(chan struct{})
+	s.gatherer.OnLocalCandidate(func(i *ICECandidate) {
+		if i == nil {
+			close(gatherFinished)
+		}
+	})
+
 	// Gather candidates
 	err := s.gatherer.Gather()
 	if err != nil {
 		return nil, err
 	}
 
+	<-gatherFinished
+
 	iceCandidates, err := s.gatherer.GetLocalCandidates()
 	if err != nil {
 		return nil, err
@@ -0,0 +1,231 @@
+// +build !js
+
+package webrtc
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// Packets sent within a burst are compared with the burst before as a
+	// group
+	// https://tools.ietf.org/html/draft-ietf-rmcat-gcc-02#section-5.2
+	packetGroupInterval = 5 * time.Millisecond
+
+	// The trendline of the delay variations tells if the delay grows
+	trendlineWindowSize    = 20
+	trendlineSmoothing     = 0.9
+	trendlineThresholdGain = 4
+	trendlineMaxDeltas     = 60
+
+	// The overuse threshold in milliseconds adapts to the trend, it goes
+	// down faster than it goes up
+	// https://tools.ietf.org/html/draft-ietf-rmcat-gcc-02#section-5.4
+	initialOveruseThreshold = 12.5
+	minOveruseThreshold     = 6
+	maxOveruseThreshold     = 600
+	overuseThresholdUp      = 0.0087
+	overuseThresholdDown    = 0.039
+
+	// https://tools.ietf.org/html/draft-ietf-rmcat-gcc-02#section-5.5
+	bitrateDecreaseFactor = 0.85
+	bitrateIncreaseFactor = 1.08
+)
+
+// bandwidthUsage is the state of the delay based estimation
+type bandwidthUsage int
+
+const (
+	bandwidthUsageNormal bandwidthUsage = iota
+	bandwidthUsageOveruse
+	bandwidthUsageUnderuse
+)
+
+// packetGroup is a burst of packets, the arrival is relative to the
+// reference times of the feedback
+type packetGroup struct {
+	firstSent   time.Time
+	lastSent    time.Time
+	lastArrival time.Duration
+	valid       bool
+}
+
+// trendlineSample is a smoothed accumulated delay in milliseconds at an
+// arrival time in milliseconds
+type trendlineSample struct {
+	arrival, delay float64
+}
+
+// delayBasedEstimator estimates the bitrate packets can be sent with from
+// the variation of their delay, the packets of a growing queue arrive
+// further and further apart than they were sent. The owner locks it.
+// https://tools.ietf.org/html/draft-ietf-rmcat-gcc-02#section-5
+type delayBasedEstimator struct {
+	group, previousGroup packetGroup
+	accumulatedDelay     float64
+	smoothedDelay        float64
+	firstArrival         float64
+	samples              []trendlineSample
+	deltas               int
+	threshold            float64
+	lastThresholdUpdate  time.Time
+	usage                bandwidthUsage
+
+	// incoming is the bitrate the packets arrive with, it is 0 until it is
+	// measured
+	incoming   float64
+	bitrate    float64
+	lastUpdate time.Time
+}
+
+func newDelayBasedEstimator(bitrate float64) delayBasedEstimator {
+	return delayBasedEstimator{
+		threshold: initialOveruseThreshold,
+		bitrate:   bitrate,
+	}
+}
+
+// addPacket adds a packet to the packet groups, the delay variation between
+// two groups is added to the trendline once a group is complete
+// https://tools.ietf.org/html/draft-ietf-rmcat-gcc-02#section-5.3
+func (e *delayBasedEstimator) addPacket(sent time.Time, arrival time.Duration, now time.Time) {
+	if !e.group.valid {
+		e.group = packetGroup{firstSent: sent, lastSent: sent, lastArrival: arrival, valid: true}
+		return
+	}
+
+	// Reordered packets are left out
+	if sent.Before(e.group.firstSent) {
+		return
+	}
+	if sent.Sub(e.group.firstSent) <= packetGroupInterval {
+		if sent.After(e.group.lastSent) {
+			e.group.lastSent = sent
+		}
+		if arrival > e.group.lastArrival {
+			e.group.lastArrival = arrival
+		}
+		return
+	}
+
+	if e.previousGroup.valid {
+		sendDelta := e.group.lastSent.Sub(e.previousGroup.lastSent)
+		arrivalDelta := e.group.lastArrival - e.previousGroup.lastArrival
+		e.updateTrendline(milliseconds(arrivalDelta-sendDelta), milliseconds(e.group.lastArrival), now)
+	}
+	e.previousGroup = e.group
+	e.group = packetGroup{firstSent: sent, lastSent: sent, lastArrival: arrival, valid: true}
+}
+
+// updateTrendline adds a delay variation to the smoothed accumulated delay
+// and detects overuse from the slope of its trendline
+func (e *delayBasedEstimator) updateTrendline(delayVariation, arrival float64, now time.Time) {
+	if e.deltas < trendlineMaxDeltas {
+		e.deltas++
+	}
+	e.accumulatedDelay += delayVariation
+	e.smoothedDelay = trendlineSmoothing*e.smoothedDelay + (1-trendlineSmoothing)*e.accumulatedDelay
+
+	if len(e.samples) == 0 {
+		e.firstArrival = arrival
+	}
+	e.samples = append(e.samples, trendlineSample{arrival: arrival - e.firstArrival, delay: e.smoothedDelay})
+	if len(e.samples) > trendlineWindowSize {
+		e.samples = e.samples[1:]
+	}
+	if len(e.samples) < trendlineWindowSize {
+		return
+	}
+
+	slope, ok := trendlineSlope(e.samples)
+	if !ok {
+		return
+	}
+	e.detectOveruse(slope*float64(e.deltas)*trendlineThresholdGain, now)
+}
+
+// trendlineSlope fits a line through samples with linear regression
+func trendlineSlope(samples []trendlineSample) (float64, bool) {
+	var sumArrival, sumDelay float64
+	for _, s := range samples {
+		sumArrival += s.arrival
+		sumDelay += s.delay
+	}
+	meanArrival := sumArrival / float64(len(samples))
+	meanDelay := sumDelay / float64(len(samples))
+
+	var numerator, denominator float64
+	for _, s := range samples {
+		numerator += (s.arrival - meanArrival) * (s.delay - meanDelay)
+		denominator += (s.arrival - meanArrival) * (s.arrival - meanArrival)
+	}
+	if denominator == 0 {
+		return 0, false
+	}
+	return numerator / denominator, true
+}
+
+// detectOveruse compares the modified trend with the adaptive threshold
+// https://tools.ietf.org/html/draft-ietf-rmcat-gcc-02#section-5.4
+func (e *delayBasedEstimator) detectOveruse(trend float64, now time.Time) {
+	switch {
+	case trend > e.threshold:
+		e.usage = bandwidthUsageOveruse
+	case trend < -e.threshold:
+		e.usage = bandwidthUsageUnderuse
+	default:
+		e.usage = bandwidthUsageNormal
+	}
+
+	// Sudden spikes don't change the threshold
+	magnitude := math.Abs(trend)
+	if !e.lastThresholdUpdate.IsZero() && magnitude <= e.threshold+15 {
+		k := overuseThresholdUp
+		if magnitude < e.threshold {
+			k = overuseThresholdDown
+		}
+		elapsed := math.Min(milliseconds(now.Sub(e.lastThresholdUpdate)), 100)
+		e.threshold += k * (magnitude - e.threshold) * elapsed
+		e.threshold = math.Max(minOveruseThreshold, math.Min(e.threshold, maxOveruseThreshold))
+	}
+	e.lastThresholdUpdate = now
+}
+
+// update updates the bitrate with the bandwidth usage
+func (e *delayBasedEstimator) update(now time.Time) {
+	elapsed := time.Duration(0)
+	if !e.lastUpdate.IsZero() {
+		elapsed = now.Sub(e.lastUpdate)
+		if elapsed > time.Second {
+			elapsed = time.Second
+		}
+	}
+	e.lastUpdate = now
+
+	// The bitrate goes down below the one the packets arrive with on
+	// overuse, and up multiplicatively unless the delay shrinks
+	// https://tools.ietf.org/html/draft-ietf-rmcat-gcc-02#section-5.5
+	switch e.usage {
+	case bandwidthUsageOveruse:
+		if e.incoming != 0 {
+			e.bitrate = math.Min(e.bitrate, bitrateDecreaseFactor*e.incoming)
+		} else {
+			e.bitrate *= bitrateDecreaseFactor
+		}
+	case bandwidthUsageNormal:
+		e.bitrate *= math.Pow(bitrateIncreaseFactor, elapsed.Seconds())
+		if e.incoming != 0 {
+			e.bitrate = math.Min(e.bitrate, 1.5*e.incoming+10000)
+		}
+	}
+	e.bitrate = clampBitrate(e.bitrate)
+}
+
+func clampBitrate(bitrate float64) float64 {
+	return math.Max(minTargetBitrate, math.Min(bitrate, maxTargetBitrate))
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
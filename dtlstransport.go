@@ -14,9 +14,12 @@ import (
 	"time"
 
 	"github.com/pion/dtls"
+	"github.com/pion/ice"
+	"github.com/pion/rtcp"
 	"github.com/pion/srtp"
 	"github.com/pion/webrtc/v2/internal/mux"
 	"github.com/pion/webrtc/v2/internal/util"
+	"github.com/pion/webrtc/v2/pkg/interceptor"
 	"github.com/pion/webrtc/v2/pkg/rtcerr"
 )
 
@@ -31,18 +34,37 @@ type DTLSTransport struct {
 	certificates      []Certificate
 	remoteParameters  DTLSParameters
 	remoteCertificate []byte
-	// State     DTLSTransportState
 
-	// OnStateChange func()
+	// state and onStateChangeHdlr are guarded by stateLock, lock is held
+	// for the duration of the handshake
+	state             DTLSTransportState
+	onStateChangeHdlr func(DTLSTransportState)
+	stateLock         sync.RWMutex
 	// OnError       func()
 
 	conn *dtls.Conn
 
+	statsID string
+
 	srtpSession   *srtp.SessionSRTP
 	srtcpSession  *srtp.SessionSRTCP
 	srtpEndpoint  *mux.Endpoint
 	srtcpEndpoint *mux.Endpoint
 
+	// interceptor is bound to the streams of the RTPSenders and
+	// RTPReceivers, all RTCP is sent with rtcpWriter
+	interceptor interceptor.Interceptor
+	rtcpWriter  interceptor.RTCPWriter
+
+	// transportCCRecorder records the arrival of the packets received with
+	// transport-wide sequence numbers, bandwidthEstimator numbers the
+	// packets sent and estimates the bitrate from the feedback for them.
+	// rembEstimator estimates the bitrate from the packets received with
+	// absolute send times for REMB feedback.
+	transportCCRecorder *transportCCRecorder
+	bandwidthEstimator  *bandwidthEstimator
+	rembEstimator       *rembEstimator
+
 	api *API
 }
 
@@ -50,7 +72,16 @@ type DTLSTransport struct {
 // This constructor is part of the ORTC API. It is not
 // meant to be used together with the basic WebRTC API.
 func (api *API) NewDTLSTransport(transport *ICETransport, certificates []Certificate) (*DTLSTransport, error) {
-	t := &DTLSTransport{iceTransport: transport, api: api}
+	t := &DTLSTransport{
+		iceTransport: transport,
+		api:          api,
+		state:        DTLSTransportStateNew,
+		statsID:      newStatsID("DTLSTransport"),
+
+		transportCCRecorder: newTransportCCRecorder(),
+		bandwidthEstimator:  newBandwidthEstimator(),
+		rembEstimator:       newREMBEstimator(),
+	}
 
 	if len(certificates) > 0 {
 		now := time.Now()
@@ -72,6 +103,13 @@ func (api *API) NewDTLSTransport(transport *ICETransport, certificates []Certifi
 		t.certificates = []Certificate{*certificate}
 	}
 
+	i, err := api.interceptorRegistry.Build()
+	if err != nil {
+		return nil, err
+	}
+	t.interceptor = i
+	t.rtcpWriter = i.BindRTCPWriter(interceptor.RTCPWriterFunc(t.writeRTCPToSession))
+
 	return t, nil
 }
 
@@ -83,6 +121,51 @@ func (t *DTLSTransport) ICETransport() *ICETransport {
 	return t.iceTransport
 }
 
+// OnTargetBitrateChange sets a handler that is fired with the bitrate in
+// bits per second that the media sent on the transport should not exceed
+// when it changes. It is estimated from the transport-cc feedback of the
+// remote peer, or taken from its REMB feedback.
+func (t *DTLSTransport) OnTargetBitrateChange(f func(bitrate uint64)) {
+	t.bandwidthEstimator.onTargetBitrateChange(f)
+}
+
+// TargetBitrate returns the bitrate in bits per second that the media sent
+// on the transport should not exceed
+func (t *DTLSTransport) TargetBitrate() uint64 {
+	return t.bandwidthEstimator.getTargetBitrate()
+}
+
+// OnStateChange sets a handler that is fired when the DTLS
+// connection state changes.
+func (t *DTLSTransport) OnStateChange(f func(DTLSTransportState)) {
+	t.stateLock.Lock()
+	defer t.stateLock.Unlock()
+	t.onStateChangeHdlr = f
+}
+
+// State returns the current dtls transport state.
+func (t *DTLSTransport) State() DTLSTransportState {
+	t.stateLock.RLock()
+	defer t.stateLock.RUnlock()
+	return t.state
+}
+
+func (t *DTLSTransport) onStateChange(state DTLSTransportState) {
+	t.stateLock.Lock()
+	// A closed transport stays closed
+	if t.state == state || t.state == DTLSTransportStateClosed {
+		t.stateLock.Unlock()
+		return
+	}
+	t.state = state
+	hdlr := t.onStateChangeHdlr
+	t.stateLock.Unlock()
+
+	if hdlr != nil {
+		hdlr(state)
+	}
+}
+
 // GetLocalParameters returns the DTLS parameters of the local DTLSTransport upon construction.
 func (t *DTLSTransport) GetLocalParameters() (DTLSParameters, error) {
 	fingerprints := []DTLSFingerprint{}
@@ -110,6 +193,61 @@ func (t *DTLSTransport) GetRemoteCertificate() []byte {
 	return t.remoteCertificate
 }
 
+// collectStats adds the stats of the local and remote certificates to
+// report and returns the stats of the transport
+func (t *DTLSTransport) collectStats(report StatsReport) TransportStats {
+	stats := TransportStats{
+		Timestamp: statsTimestampFrom(time.Now()),
+		Type:      StatsTypeTransport,
+		ID:        t.statsID,
+		DTLSState: t.State(),
+	}
+
+	for _, certificate := range t.certificates {
+		fingerprints, err := certificate.GetFingerprints()
+		if err != nil || len(fingerprints) == 0 {
+			continue
+		}
+		stats.LocalCertificateID = collectCertificateStats(report, fingerprints[0])
+	}
+
+	// lock is held while the handshake is running, the remote certificate
+	// and SRTP sessions are only looked at once it completed
+	if stats.DTLSState != DTLSTransportStateConnected {
+		return stats
+	}
+
+	t.lock.RLock()
+	remoteCertificate := t.remoteCertificate
+	if t.srtpSession != nil {
+		stats.SRTPCipher = "SRTP_AES128_CM_HMAC_SHA1_80"
+	}
+	t.lock.RUnlock()
+
+	if certificate, err := x509.ParseCertificate(remoteCertificate); err == nil {
+		if value, err := dtls.Fingerprint(certificate, dtls.HashAlgorithmSHA256); err == nil {
+			stats.RemoteCertificateID = collectCertificateStats(report, DTLSFingerprint{
+				Algorithm: dtls.HashAlgorithmSHA256.String(),
+				Value:     value,
+			})
+		}
+	}
+
+	return stats
+}
+
+func collectCertificateStats(report StatsReport, fingerprint DTLSFingerprint) string {
+	stats := CertificateStats{
+		Timestamp:            statsTimestampFrom(time.Now()),
+		Type:                 StatsTypeCertificate,
+		ID:                   fmt.Sprintf("Certificate-%s", fingerprint.Value),
+		Fingerprint:          fingerprint.Value,
+		FingerprintAlgorithm: fingerprint.Algorithm,
+	}
+	report[stats.ID] = stats
+	return stats.ID
+}
+
 func (t *DTLSTransport) startSRTP() error {
 	t.lock.Lock()
 	defer t.lock.Unlock()
@@ -175,6 +313,35 @@ func (t *DTLSTransport) getSRTCPSession() (*srtp.SessionSRTCP, error) {
 	return t.srtcpSession, nil
 }
 
+// writeRTCP sends pkts through the interceptors, they are dropped while
+// there is no candidate pair to send them on
+func (t *DTLSTransport) writeRTCP(pkts []rtcp.Packet) error {
+	if _, err := t.rtcpWriter.Write(pkts, interceptor.Attributes{}); err != nil && err != ice.ErrNoCandidatePairs {
+		return err
+	}
+	return nil
+}
+
+// writeRTCPToSession is the last writer of the interceptors, it sends pkts
+// on the SRTCP session
+func (t *DTLSTransport) writeRTCPToSession(pkts []rtcp.Packet, _ interceptor.Attributes) (int, error) {
+	raw, err := rtcp.Marshal(pkts)
+	if err != nil {
+		return 0, err
+	}
+
+	srtcpSession, err := t.getSRTCPSession()
+	if err != nil {
+		return 0, err
+	}
+
+	writeStream, err := srtcpSession.OpenWriteStream()
+	if err != nil {
+		return 0, err
+	}
+	return writeStream.Write(raw)
+}
+
 func (t *DTLSTransport) isClient() bool {
 	isClient := true
 	switch t.remoteParameters.Role {
@@ -193,6 +360,17 @@ func (t *DTLSTransport) isClient() bool {
 
 // Start DTLS transport negotiation with the parameters of the remote DTLS transport
 func (t *DTLSTransport) Start(remoteParameters DTLSParameters) error {
+	t.onStateChange(DTLSTransportStateConnecting)
+	if err := t.start(remoteParameters); err != nil {
+		t.onStateChange(DTLSTransportStateFailed)
+		return err
+	}
+
+	t.onStateChange(DTLSTransportStateConnected)
+	return nil
+}
+
+func (t *DTLSTransport) start(remoteParameters DTLSParameters) error {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
@@ -243,6 +421,8 @@ func (t *DTLSTransport) Start(remoteParameters DTLSParameters) error {
 
 // Stop stops and closes the DTLSTransport object.
 func (t *DTLSTransport) Stop() error {
+	t.onStateChange(DTLSTransportStateClosed)
+
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
@@ -266,6 +446,10 @@ func (t *DTLSTransport) Stop() error {
 			closeErrs = append(closeErrs, err)
 		}
 	}
+
+	if err := t.interceptor.Close(); err != nil {
+		closeErrs = append(closeErrs, err)
+	}
 	return util.FlattenErrs(closeErrs)
 }
 
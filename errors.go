@@ -83,4 +83,13 @@ var (
 	// ErrIncorrectSDPSemantics indicates that the PeerConnection was configured to
 	// generate SDP Answers with different SDP Semantics than the received Offer
 	ErrIncorrectSDPSemantics = errors.New("offer SDP semantics does not match configuration")
+
+	// ErrSenderNotCreatedByConnection indicates RemoveTrack was called with a
+	// RTPSender not created by this PeerConnection
+	ErrSenderNotCreatedByConnection = errors.New("RTPSender not created by this PeerConnection")
+
+	// ErrNoHeaderExtensionID indicates that a header extension couldn't be
+	// registered or signaled because all IDs of the one-byte header form are
+	// in use
+	ErrNoHeaderExtensionID = errors.New("all header extension IDs are in use")
 )
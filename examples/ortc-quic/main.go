@@ -59,12 +59,21 @@ func main() {
 		go WriteLoop(stream)
 	})
 
+	gatherFinished := make(chan struct{})
+	gatherer.OnLocalCandidate(func(i *webrtc.ICECandidate) {
+		if i == nil {
+			close(gatherFinished)
+		}
+	})
+
 	// Gather candidates
 	err = gatherer.Gather()
 	if err != nil {
 		panic(err)
 	}
 
+	<-gatherFinished
+
 	iceCandidates, err := gatherer.GetLocalCandidates()
 	if err != nil {
 		panic(err)
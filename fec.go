@@ -0,0 +1,295 @@
+// +build !js
+
+package webrtc
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// rtpFixedHeaderSize is the size of the RTP header without CSRCs and
+	// extensions, the rest of a packet is protected as its payload
+	rtpFixedHeaderSize = 12
+
+	ulpfecHeaderSize      = 10
+	ulpfecLevelHeaderSize = 2
+	ulpfecShortMaskSize   = 2
+	ulpfecLongMaskSize    = 6
+
+	flexfecHeaderSize = 18
+)
+
+// flexfecMaskSizes are the sizes the packet mask of a FlexFEC packet can
+// have, each chunk is ended with the K bit telling if it is the last
+var flexfecMaskSizes = []int{2, 6, 14}
+
+// fecRecovery is the XOR of the media packets protected by an FEC packet,
+// any one of them can be recovered from the others
+// https://tools.ietf.org/html/rfc5109#section-7.3
+type fecRecovery struct {
+	// header is the XOR of the first two octets of the packets, they hold
+	// the padding, extension and CSRC count, marker and payload type
+	header    [2]byte
+	timestamp uint32
+
+	// length is the XOR of the lengths of the protected parts, which are
+	// everything after the fixed header of the packets
+	length  uint16
+	payload []byte
+}
+
+// add XORs a marshaled media packet into the recovery
+func (f *fecRecovery) add(packet []byte) {
+	f.header[0] ^= packet[0]
+	f.header[1] ^= packet[1]
+	f.timestamp ^= binary.BigEndian.Uint32(packet[4:])
+
+	protected := packet[rtpFixedHeaderSize:]
+	f.length ^= uint16(len(protected))
+	if len(protected) > len(f.payload) {
+		f.payload = append(f.payload, make([]byte, len(protected)-len(f.payload))...)
+	}
+	for i := range protected {
+		f.payload[i] ^= protected[i]
+	}
+}
+
+// clone returns a copy of the recovery that can be added to
+func (f *fecRecovery) clone() *fecRecovery {
+	c := *f
+	c.payload = append([]byte{}, f.payload...)
+	return &c
+}
+
+// recover returns the marshaled media packet with seq and ssrc after all
+// other protected packets have been added to the recovery
+func (f *fecRecovery) recover(seq uint16, ssrc uint32) ([]byte, error) {
+	if int(f.length) > len(f.payload) {
+		return nil, fmt.Errorf("recovered length %d exceeds the protected %d bytes", f.length, len(f.payload))
+	}
+
+	packet := make([]byte, rtpFixedHeaderSize+int(f.length))
+	packet[0] = 0x80 | f.header[0]&0x3f // RTP version 2
+	packet[1] = f.header[1]
+	binary.BigEndian.PutUint16(packet[2:], seq)
+	binary.BigEndian.PutUint32(packet[4:], f.timestamp)
+	binary.BigEndian.PutUint32(packet[8:], ssrc)
+	copy(packet[rtpFixedHeaderSize:], f.payload)
+	return packet, nil
+}
+
+// fecPacket is an FEC packet protecting the media packets with seqs of the
+// stream with ssrc
+type fecPacket struct {
+	ssrc     uint32
+	seqs     []uint16
+	recovery *fecRecovery
+}
+
+// marshalULPFEC returns the payload of an ULPFEC packet with a single
+// protection level that covers the whole packets
+// https://tools.ietf.org/html/rfc5109#section-7.3
+func marshalULPFEC(p *fecPacket) []byte {
+	maskSize, longMask := ulpfecShortMaskSize, false
+	for _, seq := range p.seqs {
+		if seq-p.seqs[0] >= ulpfecShortMaskSize*8 {
+			maskSize, longMask = ulpfecLongMaskSize, true
+		}
+	}
+
+	headerSize := ulpfecHeaderSize + ulpfecLevelHeaderSize + maskSize
+	b := make([]byte, headerSize+len(p.recovery.payload))
+	b[0] = p.recovery.header[0] & 0x3f
+	if longMask {
+		b[0] |= 0x40
+	}
+	b[1] = p.recovery.header[1]
+	binary.BigEndian.PutUint16(b[2:], p.seqs[0])
+	binary.BigEndian.PutUint32(b[4:], p.recovery.timestamp)
+	binary.BigEndian.PutUint16(b[8:], p.recovery.length)
+	binary.BigEndian.PutUint16(b[10:], uint16(len(p.recovery.payload)))
+	for _, seq := range p.seqs {
+		offset := int(seq - p.seqs[0])
+		b[12+offset/8] |= 0x80 >> uint(offset%8)
+	}
+	copy(b[headerSize:], p.recovery.payload)
+	return b
+}
+
+// unmarshalULPFEC parses the payload of an ULPFEC packet protecting media
+// packets of the stream with ssrc, only its first protection level is used
+func unmarshalULPFEC(b []byte, ssrc uint32) (*fecPacket, error) {
+	if len(b) < ulpfecHeaderSize+ulpfecLevelHeaderSize+ulpfecShortMaskSize {
+		return nil, fmt.Errorf("ULPFEC packet is too short: %d bytes", len(b))
+	}
+
+	maskSize := ulpfecShortMaskSize
+	if b[0]&0x40 != 0 {
+		maskSize = ulpfecLongMaskSize
+	}
+	headerSize := ulpfecHeaderSize + ulpfecLevelHeaderSize + maskSize
+	protectionLength := int(binary.BigEndian.Uint16(b[10:]))
+	if len(b) < headerSize+protectionLength {
+		return nil, fmt.Errorf("ULPFEC packet is too short: %d bytes", len(b))
+	}
+
+	p := &fecPacket{
+		ssrc: ssrc,
+		recovery: &fecRecovery{
+			header:    [2]byte{b[0] & 0x3f, b[1]},
+			timestamp: binary.BigEndian.Uint32(b[4:]),
+			length:    binary.BigEndian.Uint16(b[8:]),
+			payload:   append([]byte{}, b[headerSize:headerSize+protectionLength]...),
+		},
+	}
+
+	snBase := binary.BigEndian.Uint16(b[2:])
+	for i := 0; i < maskSize*8; i++ {
+		if b[12+i/8]&(0x80>>uint(i%8)) != 0 {
+			p.seqs = append(p.seqs, snBase+uint16(i))
+		}
+	}
+	return p, nil
+}
+
+// marshalFlexFEC returns the payload of a FlexFEC packet with a flexible
+// mask protecting a single stream
+// https://tools.ietf.org/html/draft-ietf-payload-flexible-fec-scheme-03#section-4.2
+func marshalFlexFEC(p *fecPacket) []byte {
+	// The first chunk of the mask has 15 bits, the second 31 and the last 63
+	maskSize := flexfecMaskSizes[0]
+	for _, seq := range p.seqs {
+		switch offset := seq - p.seqs[0]; {
+		case offset >= 46:
+			maskSize = flexfecMaskSizes[2]
+		case offset >= 15 && maskSize < flexfecMaskSizes[1]:
+			maskSize = flexfecMaskSizes[1]
+		}
+	}
+
+	headerSize := flexfecHeaderSize + maskSize
+	b := make([]byte, headerSize+len(p.recovery.payload))
+	b[0] = p.recovery.header[0] & 0x3f
+	b[1] = p.recovery.header[1]
+	binary.BigEndian.PutUint16(b[2:], p.recovery.length)
+	binary.BigEndian.PutUint32(b[4:], p.recovery.timestamp)
+	b[8] = 1 // SSRCCount
+	binary.BigEndian.PutUint32(b[12:], p.ssrc)
+	binary.BigEndian.PutUint16(b[16:], p.seqs[0])
+
+	mask := b[flexfecHeaderSize:headerSize]
+	for _, seq := range p.seqs {
+		bit := flexfecMaskBit(int(seq - p.seqs[0]))
+		mask[bit/8] |= 0x80 >> uint(bit%8)
+	}
+	for _, size := range flexfecMaskSizes {
+		if size == maskSize {
+			mask[size-flexfecMaskChunkSize(size)] |= 0x80
+			break
+		}
+	}
+	copy(b[headerSize:], p.recovery.payload)
+	return b
+}
+
+// unmarshalFlexFEC parses the payload of a FlexFEC packet, only packets
+// protecting a single stream with a flexible mask are supported
+func unmarshalFlexFEC(b []byte) (*fecPacket, error) {
+	if len(b) < flexfecHeaderSize+flexfecMaskSizes[0] {
+		return nil, fmt.Errorf("FlexFEC packet is too short: %d bytes", len(b))
+	} else if b[0]&0xc0 != 0 {
+		return nil, fmt.Errorf("FlexFEC packets with a fixed mask are not supported")
+	} else if b[8] != 1 {
+		return nil, fmt.Errorf("FlexFEC packets protecting %d streams are not supported", b[8])
+	}
+
+	maskSize := 0
+	for _, size := range flexfecMaskSizes {
+		if len(b) < flexfecHeaderSize+size {
+			return nil, fmt.Errorf("FlexFEC packet is too short: %d bytes", len(b))
+		}
+		if b[flexfecHeaderSize+size-flexfecMaskChunkSize(size)]&0x80 != 0 {
+			maskSize = size
+			break
+		}
+	}
+	if maskSize == 0 {
+		return nil, fmt.Errorf("FlexFEC packet mask is not terminated")
+	}
+	headerSize := flexfecHeaderSize + maskSize
+
+	p := &fecPacket{
+		ssrc: binary.BigEndian.Uint32(b[12:]),
+		recovery: &fecRecovery{
+			header:    [2]byte{b[0] & 0x3f, b[1]},
+			length:    binary.BigEndian.Uint16(b[2:]),
+			timestamp: binary.BigEndian.Uint32(b[4:]),
+			payload:   append([]byte{}, b[headerSize:]...),
+		},
+	}
+
+	snBase := binary.BigEndian.Uint16(b[16:])
+	mask := b[flexfecHeaderSize:headerSize]
+	for offset := 0; ; offset++ {
+		bit := flexfecMaskBit(offset)
+		if bit >= maskSize*8 {
+			break
+		}
+		if mask[bit/8]&(0x80>>uint(bit%8)) != 0 {
+			p.seqs = append(p.seqs, snBase+uint16(offset))
+		}
+	}
+	return p, nil
+}
+
+// flexfecMaskBit returns the position of the bit for the packet at offset
+// from the base sequence number in a FlexFEC mask, skipping the K bits
+func flexfecMaskBit(offset int) int {
+	switch {
+	case offset < 15:
+		return offset + 1
+	case offset < 46:
+		return offset + 2
+	default:
+		return offset + 3
+	}
+}
+
+// flexfecMaskChunkSize returns the size of the last chunk of a FlexFEC mask
+// with size
+func flexfecMaskChunkSize(size int) int {
+	for i, s := range flexfecMaskSizes {
+		if s == size && i > 0 {
+			return size - flexfecMaskSizes[i-1]
+		}
+	}
+	return size
+}
+
+// marshalRED returns the payload of a RED packet carrying only a primary
+// block with blockPayloadType
+// https://tools.ietf.org/html/rfc2198#section-3
+func marshalRED(blockPayloadType uint8, block []byte) []byte {
+	b := make([]byte, 1+len(block))
+	b[0] = blockPayloadType & 0x7f
+	copy(b[1:], block)
+	return b
+}
+
+// unmarshalRED returns the payload type and data of the primary block of a
+// RED packet, the redundant blocks are skipped
+func unmarshalRED(b []byte) (blockPayloadType uint8, block []byte, err error) {
+	offset, redundantLength := 0, 0
+	for ; offset < len(b) && b[offset]&0x80 != 0; offset += 4 {
+		if offset+4 > len(b) {
+			return 0, nil, fmt.Errorf("RED packet is too short: %d bytes", len(b))
+		}
+		redundantLength += int(b[offset+2]&0x03)<<8 | int(b[offset+3])
+	}
+
+	if offset >= len(b) || offset+1+redundantLength > len(b) {
+		return 0, nil, fmt.Errorf("RED packet is too short: %d bytes", len(b))
+	}
+	return b[offset] & 0x7f, b[offset+1+redundantLength:], nil
+}
@@ -0,0 +1,130 @@
+// +build !js
+
+package webrtc
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func fecTestPackets(t *testing.T, firstSeq uint16, count int) [][]byte {
+	packets := make([][]byte, count)
+	for i := range packets {
+		p := &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				Marker:         i == count-1,
+				PayloadType:    DefaultPayloadTypeVP8,
+				SequenceNumber: firstSeq + uint16(i),
+				Timestamp:      uint32(3000 * (i / 3)),
+				SSRC:           5000,
+			},
+			Payload: make([]byte, 10+i*7),
+		}
+		for j := range p.Payload {
+			p.Payload[j] = byte(i + j)
+		}
+
+		raw, err := p.Marshal()
+		assert.NoError(t, err)
+		packets[i] = raw
+	}
+	return packets
+}
+
+func TestFECRecovery(t *testing.T) {
+	packets := fecTestPackets(t, 65534, 5)
+
+	recovery := &fecRecovery{}
+	for _, packet := range packets {
+		recovery.add(packet)
+	}
+
+	for lost := range packets {
+		r := recovery.clone()
+		for i, packet := range packets {
+			if i != lost {
+				r.add(packet)
+			}
+		}
+
+		recovered, err := r.recover(rtpSequenceNumber(packets[lost]), 5000)
+		assert.NoError(t, err)
+		assert.Equal(t, packets[lost], recovered)
+	}
+
+	// Without all other packets the length doesn't match
+	r := recovery.clone()
+	r.add(packets[0])
+	_, err := r.recover(rtpSequenceNumber(packets[1]), 5000)
+	assert.Error(t, err)
+}
+
+func TestULPFEC_Marshal(t *testing.T) {
+	for _, seqs := range [][]uint16{
+		{100, 102, 115},
+		{65530, 65535, 10, 41},
+	} {
+		p := &fecPacket{ssrc: 5000, seqs: seqs, recovery: &fecRecovery{
+			header:    [2]byte{0x10, 0x60},
+			timestamp: 12345,
+			length:    7,
+			payload:   []byte{1, 2, 3, 4, 5, 6, 7},
+		}}
+
+		parsed, err := unmarshalULPFEC(marshalULPFEC(p), 5000)
+		assert.NoError(t, err)
+		assert.Equal(t, p, parsed)
+	}
+
+	_, err := unmarshalULPFEC([]byte{0x00, 0x01}, 5000)
+	assert.Error(t, err)
+}
+
+func TestFlexFEC_Marshal(t *testing.T) {
+	for _, seqs := range [][]uint16{
+		{100, 102, 114},
+		{100, 115, 145},
+		{65530, 40, 102},
+	} {
+		p := &fecPacket{ssrc: 5000, seqs: seqs, recovery: &fecRecovery{
+			header:    [2]byte{0x10, 0x60},
+			timestamp: 12345,
+			length:    7,
+			payload:   []byte{1, 2, 3, 4, 5, 6, 7},
+		}}
+
+		parsed, err := unmarshalFlexFEC(marshalFlexFEC(p))
+		assert.NoError(t, err)
+		assert.Equal(t, p, parsed)
+	}
+
+	// The K bit of the first chunk ends a two byte mask
+	b := marshalFlexFEC(&fecPacket{ssrc: 5000, seqs: []uint16{7}, recovery: &fecRecovery{}})
+	assert.Equal(t, flexfecHeaderSize+2, len(b))
+	assert.Equal(t, []byte{0xc0, 0x00}, b[flexfecHeaderSize:])
+
+	_, err := unmarshalFlexFEC(b[:flexfecHeaderSize])
+	assert.Error(t, err)
+}
+
+func TestRED(t *testing.T) {
+	blockPayloadType, block, err := unmarshalRED(marshalRED(DefaultPayloadTypeVP8, []byte{1, 2, 3}))
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(DefaultPayloadTypeVP8), blockPayloadType)
+	assert.Equal(t, []byte{1, 2, 3}, block)
+
+	// A redundant block of two bytes is skipped
+	// https://tools.ietf.org/html/rfc2198#section-3
+	blockPayloadType, block, err = unmarshalRED([]byte{0x80 | 100, 0x00, 0x00, 0x02, 101, 9, 9, 1, 2})
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(101), blockPayloadType)
+	assert.Equal(t, []byte{1, 2}, block)
+
+	_, _, err = unmarshalRED([]byte{0x80 | 100, 0x00, 0x00, 0x05, 101, 9})
+	assert.Error(t, err)
+	_, _, err = unmarshalRED(nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,131 @@
+// +build !js
+
+package webrtc
+
+import "sync"
+
+const (
+	// fecHistorySize is how many media packets are kept for recovering
+	// others, it divides 1<<16 so the history wraps around with the
+	// sequence numbers
+	fecHistorySize = 1024
+
+	// fecMaxPending is how many FEC packets wait for more of their media
+	// packets to arrive at most
+	fecMaxPending = 64
+)
+
+// fecDecoder recovers the lost media packets of an RTP stream from the FEC
+// packets protecting them
+type fecDecoder struct {
+	lock sync.Mutex
+	ssrc uint32
+
+	history [fecHistorySize]struct {
+		seq       uint16
+		packet    []byte
+		recovered bool
+	}
+
+	// pending are the FEC packets that more than one of their media
+	// packets is missing for
+	pending []*fecPacket
+}
+
+func newFECDecoder(ssrc uint32) *fecDecoder {
+	return &fecDecoder{ssrc: ssrc}
+}
+
+// addMedia adds a marshaled media packet of the stream, it is false if the
+// packet has been recovered before. The packets recovered with it are
+// returned.
+func (d *fecDecoder) addMedia(packet []byte) (bool, [][]byte) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	seq := rtpSequenceNumber(packet)
+	if d.has(seq) {
+		return !d.history[seq%fecHistorySize].recovered, nil
+	}
+
+	d.store(seq, packet, false)
+	return true, d.recover()
+}
+
+// addFEC adds an FEC packet and returns the media packets recovered with
+// it
+func (d *fecDecoder) addFEC(p *fecPacket) [][]byte {
+	if p.ssrc != d.ssrc || len(p.seqs) == 0 {
+		return nil
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.pending = append(d.pending, p)
+	if len(d.pending) > fecMaxPending {
+		d.pending = d.pending[len(d.pending)-fecMaxPending:]
+	}
+	return d.recover()
+}
+
+// recover recovers the media packets that are the only missing one of an
+// FEC packet until no more can be recovered
+func (d *fecDecoder) recover() [][]byte {
+	var recovered [][]byte
+	for {
+		progress := false
+		pending := d.pending[:0]
+		for _, p := range d.pending {
+			missing, missingSeq := 0, uint16(0)
+			for _, seq := range p.seqs {
+				if !d.has(seq) {
+					missing++
+					missingSeq = seq
+				}
+			}
+
+			switch {
+			case missing == 0:
+				continue
+			case missing > 1:
+				pending = append(pending, p)
+				continue
+			}
+
+			recovery := p.recovery.clone()
+			for _, seq := range p.seqs {
+				if seq != missingSeq {
+					recovery.add(d.history[seq%fecHistorySize].packet)
+				}
+			}
+
+			// FEC packets that are inconsistent with the media packets
+			// are dropped
+			packet, err := recovery.recover(missingSeq, d.ssrc)
+			if err != nil {
+				continue
+			}
+			d.store(missingSeq, packet, true)
+			recovered = append(recovered, packet)
+			progress = true
+		}
+		d.pending = pending
+
+		if !progress {
+			return recovered
+		}
+	}
+}
+
+func (d *fecDecoder) has(seq uint16) bool {
+	entry := &d.history[seq%fecHistorySize]
+	return entry.packet != nil && entry.seq == seq
+}
+
+func (d *fecDecoder) store(seq uint16, packet []byte, recovered bool) {
+	entry := &d.history[seq%fecHistorySize]
+	entry.seq = seq
+	entry.packet = packet
+	entry.recovered = recovered
+}
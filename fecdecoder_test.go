@@ -0,0 +1,138 @@
+// +build !js
+
+package webrtc
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+// fecTestStream protects the packets with mechanism and returns them as
+// they are received, the FEC packets are parsed
+func fecTestStream(t *testing.T, mechanism FECMechanism, overhead uint8, packets [][]byte) (media [][]byte, fec []*fecPacket) {
+	encoder := newFECEncoder(RTPFecParameters{
+		Mechanism:      mechanism,
+		SSRC:           6000,
+		PayloadType:    DefaultPayloadTypeULPFEC,
+		REDPayloadType: DefaultPayloadTypeRED,
+	}, overhead)
+
+	for _, raw := range packets {
+		packet := &rtp.Packet{}
+		assert.NoError(t, packet.Unmarshal(raw))
+
+		_, sent, err := encoder.encode(packet)
+		assert.NoError(t, err)
+		for _, p := range sent {
+			if mechanism == FECMechanismFlexFEC {
+				if p.SSRC == 6000 {
+					parsed, err := unmarshalFlexFEC(p.Payload)
+					assert.NoError(t, err)
+					fec = append(fec, parsed)
+				} else {
+					raw, err := p.Marshal()
+					assert.NoError(t, err)
+					media = append(media, raw)
+				}
+				continue
+			}
+
+			blockPayloadType, block, err := unmarshalRED(p.Payload)
+			assert.NoError(t, err)
+			if blockPayloadType == DefaultPayloadTypeULPFEC {
+				parsed, err := unmarshalULPFEC(block, p.SSRC)
+				assert.NoError(t, err)
+				fec = append(fec, parsed)
+				continue
+			}
+
+			p.PayloadType = blockPayloadType
+			p.Payload = block
+			raw, err := p.Marshal()
+			assert.NoError(t, err)
+			media = append(media, raw)
+		}
+	}
+	return media, fec
+}
+
+func TestFECDecoder(t *testing.T) {
+	for _, mechanism := range []FECMechanism{FECMechanismULPFEC, FECMechanismFlexFEC} {
+		// 8 FEC packets are sent for the 16 packets of a block, packets that
+		// are 8 apart are protected by the same one
+		media, fec := fecTestStream(t, mechanism, 50, fecTestPackets(t, 65530, 16))
+		assert.Equal(t, 16, len(media))
+		assert.Equal(t, 8, len(fec))
+
+		decoder := newFECDecoder(5000)
+		lost := map[int]bool{3: true, 4: true, 5: true, 14: true}
+		for i, packet := range media {
+			if !lost[i] {
+				isNew, recovered := decoder.addMedia(packet)
+				assert.True(t, isNew)
+				assert.Empty(t, recovered)
+			}
+		}
+
+		var recovered [][]byte
+		for _, p := range fec {
+			recovered = append(recovered, decoder.addFEC(p)...)
+		}
+		assert.Equal(t, [][]byte{media[3], media[4], media[5], media[14]}, recovered, mechanism)
+		assert.Empty(t, decoder.pending)
+
+		// Packets that arrive after they were recovered are dropped
+		isNew, _ := decoder.addMedia(media[4])
+		assert.False(t, isNew)
+		isNew, _ = decoder.addMedia(media[6])
+		assert.True(t, isNew)
+	}
+}
+
+func TestFECDecoder_MediaAfterFEC(t *testing.T) {
+	media, fec := fecTestStream(t, FECMechanismULPFEC, 10, fecTestPackets(t, 100, 4))
+	assert.Equal(t, 1, len(fec))
+
+	// The FEC packet waits until all but one of its packets have arrived
+	decoder := newFECDecoder(5000)
+	assert.Empty(t, decoder.addFEC(fec[0]))
+	_, recovered := decoder.addMedia(media[0])
+	assert.Empty(t, recovered)
+	_, recovered = decoder.addMedia(media[3])
+	assert.Empty(t, recovered)
+	_, recovered = decoder.addMedia(media[1])
+	assert.Equal(t, [][]byte{media[2]}, recovered)
+
+	// FEC packets for other streams are ignored
+	other := newFECDecoder(5001)
+	assert.Empty(t, other.addFEC(fec[0]))
+	assert.Empty(t, other.pending)
+}
+
+func TestFECEncoder_ULPFECSequenceNumbers(t *testing.T) {
+	encoder := newFECEncoder(RTPFecParameters{
+		Mechanism:      FECMechanismULPFEC,
+		PayloadType:    DefaultPayloadTypeULPFEC,
+		REDPayloadType: DefaultPayloadTypeRED,
+	}, 100)
+
+	// The ULPFEC packets take sequence numbers of the stream, the media
+	// packets after them are renumbered
+	seqs := []uint16{}
+	for _, raw := range append(fecTestPackets(t, 65534, 2), fecTestPackets(t, 0, 1)...) {
+		packet := &rtp.Packet{}
+		assert.NoError(t, packet.Unmarshal(raw))
+
+		media, sent, err := encoder.encode(packet)
+		assert.NoError(t, err)
+		assert.Equal(t, sent[0].SequenceNumber, media.SequenceNumber)
+		assert.Equal(t, uint8(DefaultPayloadTypeVP8), media.PayloadType)
+		for _, p := range sent {
+			assert.Equal(t, uint8(DefaultPayloadTypeRED), p.PayloadType)
+			seqs = append(seqs, p.SequenceNumber)
+		}
+	}
+	assert.Equal(t, []uint16{65534, 65535, 0, 1, 2, 3}, seqs)
+}
@@ -0,0 +1,125 @@
+// +build !js
+
+package webrtc
+
+import (
+	"math/rand"
+	"sync"
+
+	"github.com/pion/rtp"
+)
+
+// fecMaxBlockSize is how many media packets are protected together at most,
+// a block also ends with the last packet of a frame
+const fecMaxBlockSize = 16
+
+// fecEncoder protects the media packets of an RTP stream with FEC packets
+// that are sent along with them
+type fecEncoder struct {
+	lock sync.Mutex
+
+	parameters RTPFecParameters
+
+	// overhead is the number of FEC packets sent per 100 media packets
+	overhead uint8
+
+	// block holds the marshaled media packets not yet protected
+	block [][]byte
+
+	// sequenceOffset is added to the sequence numbers of the media packets
+	// for the ULPFEC packets sent in between them
+	sequenceOffset uint16
+
+	// sequenceNumber is the next sequence number of the FlexFEC stream
+	sequenceNumber uint16
+}
+
+func newFECEncoder(parameters RTPFecParameters, overhead uint8) *fecEncoder {
+	return &fecEncoder{
+		parameters:     parameters,
+		overhead:       overhead,
+		sequenceNumber: uint16(rand.Uint32()),
+	}
+}
+
+// encode returns the packets to send for the media packet, the first one is
+// the media packet itself. media is the media packet as it is numbered on
+// the stream, which is the packet that has to be retransmitted for it.
+func (e *fecEncoder) encode(packet *rtp.Packet) (media *rtp.Packet, packets []*rtp.Packet, err error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	media = &rtp.Packet{Header: packet.Header, Payload: packet.Payload}
+	media.SequenceNumber += e.sequenceOffset
+	raw, err := media.Marshal()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if e.parameters.Mechanism == FECMechanismULPFEC {
+		red := &rtp.Packet{Header: media.Header, Payload: marshalRED(media.PayloadType, media.Payload)}
+		red.PayloadType = e.parameters.REDPayloadType
+		packets = append(packets, red)
+	} else {
+		packets = append(packets, media)
+	}
+
+	e.block = append(e.block, raw)
+	if !media.Marker && len(e.block) < fecMaxBlockSize {
+		return media, packets, nil
+	}
+
+	for i, p := range e.protect(media.SSRC) {
+		header := rtp.Header{
+			Version:     2,
+			PayloadType: e.parameters.PayloadType,
+			Timestamp:   media.Timestamp,
+		}
+		if e.parameters.Mechanism == FECMechanismULPFEC {
+			// ULPFEC packets take the sequence numbers after the media
+			// packets they protect
+			// https://tools.ietf.org/html/rfc5109#section-14.1
+			e.sequenceOffset++
+			header.SSRC = media.SSRC
+			header.SequenceNumber = media.SequenceNumber + uint16(i+1)
+			header.PayloadType = e.parameters.REDPayloadType
+			packets = append(packets, &rtp.Packet{Header: header, Payload: marshalRED(e.parameters.PayloadType, marshalULPFEC(p))})
+		} else {
+			header.SSRC = e.parameters.SSRC
+			header.SequenceNumber = e.sequenceNumber
+			e.sequenceNumber++
+			packets = append(packets, &rtp.Packet{Header: header, Payload: marshalFlexFEC(p)})
+		}
+	}
+	return media, packets, nil
+}
+
+// protect returns the FEC packets for the current block and starts the next
+// one. The packets of the block are interleaved over the FEC packets, so
+// consecutive losses can be recovered too.
+func (e *fecEncoder) protect(ssrc uint32) []*fecPacket {
+	count := (len(e.block)*int(e.overhead) + 99) / 100
+	if count < 1 {
+		count = 1
+	} else if count > len(e.block) {
+		count = len(e.block)
+	}
+
+	fecPackets := make([]*fecPacket, count)
+	for i := range fecPackets {
+		fecPackets[i] = &fecPacket{ssrc: ssrc, recovery: &fecRecovery{}}
+	}
+	for i, raw := range e.block {
+		p := fecPackets[i%count]
+		p.seqs = append(p.seqs, rtpSequenceNumber(raw))
+		p.recovery.add(raw)
+	}
+
+	e.block = nil
+	return fecPackets
+}
+
+// rtpSequenceNumber returns the sequence number of a marshaled RTP packet
+func rtpSequenceNumber(raw []byte) uint16 {
+	return uint16(raw[2])<<8 | uint16(raw[3])
+}
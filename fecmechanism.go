@@ -0,0 +1,47 @@
+package webrtc
+
+// FECMechanism indicates how Forward Error Correction is sent for an RTP
+// stream.
+type FECMechanism int
+
+const (
+	// FECMechanismULPFEC indicates ULPFEC packets are sent in RED packets
+	// on the stream they protect.
+	// https://tools.ietf.org/html/rfc5109
+	FECMechanismULPFEC FECMechanism = iota + 1
+
+	// FECMechanismFlexFEC indicates FlexFEC packets are sent on a separate
+	// stream.
+	// https://tools.ietf.org/html/draft-ietf-payload-flexible-fec-scheme-03
+	FECMechanismFlexFEC
+)
+
+// This is done this way because of a linter.
+const (
+	fecMechanismULPFECStr  = "red+ulpfec"
+	fecMechanismFlexFECStr = "flexfec"
+)
+
+// NewFECMechanism defines a procedure for creating a new FECMechanism from
+// a raw string naming the mechanism.
+func NewFECMechanism(raw string) FECMechanism {
+	switch raw {
+	case fecMechanismULPFECStr:
+		return FECMechanismULPFEC
+	case fecMechanismFlexFECStr:
+		return FECMechanismFlexFEC
+	default:
+		return FECMechanism(Unknown)
+	}
+}
+
+func (t FECMechanism) String() string {
+	switch t {
+	case FECMechanismULPFEC:
+		return fecMechanismULPFECStr
+	case FECMechanismFlexFEC:
+		return fecMechanismFlexFECStr
+	default:
+		return ErrUnknownType.Error()
+	}
+}
@@ -0,0 +1,45 @@
+package webrtc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFECMechanism(t *testing.T) {
+	testCases := []struct {
+		mechanismString   string
+		expectedMechanism FECMechanism
+	}{
+		{unknownStr, FECMechanism(Unknown)},
+		{"red+ulpfec", FECMechanismULPFEC},
+		{"flexfec", FECMechanismFlexFEC},
+	}
+
+	for i, testCase := range testCases {
+		assert.Equal(t,
+			NewFECMechanism(testCase.mechanismString),
+			testCase.expectedMechanism,
+			"testCase: %d %v", i, testCase,
+		)
+	}
+}
+
+func TestFECMechanism_String(t *testing.T) {
+	testCases := []struct {
+		mechanism      FECMechanism
+		expectedString string
+	}{
+		{FECMechanism(Unknown), unknownStr},
+		{FECMechanismULPFEC, "red+ulpfec"},
+		{FECMechanismFlexFEC, "flexfec"},
+	}
+
+	for i, testCase := range testCases {
+		assert.Equal(t,
+			testCase.mechanism.String(),
+			testCase.expectedString,
+			"testCase: %d %v", i, testCase,
+		)
+	}
+}
@@ -5,14 +5,15 @@ go 1.12
 require (
 	github.com/pion/datachannel v1.4.1
 	github.com/pion/dtls v1.3.3
-	github.com/pion/ice v0.2.5
+	github.com/pion/ice v0.4.3
 	github.com/pion/logging v0.2.1
 	github.com/pion/quic v0.1.1
-	github.com/pion/rtcp v1.2.0
+	github.com/pion/rtcp v1.2.9
 	github.com/pion/rtp v1.1.1
 	github.com/pion/sctp v1.6.1
 	github.com/pion/sdp/v2 v2.1.1
 	github.com/pion/srtp v1.2.3
 	github.com/pion/transport v0.7.0
-	github.com/stretchr/testify v1.3.0
+	github.com/prometheus/client_golang v1.3.0
+	github.com/stretchr/testify v1.7.0 // required by github.com/pion/rtcp v1.2.9
 )
@@ -1,9 +1,7 @@
 package webrtc
 
 import (
-	"errors"
 	"fmt"
-	"net"
 
 	"github.com/pion/ice"
 	"github.com/pion/sdp/v2"
@@ -20,6 +18,11 @@ type ICECandidate struct {
 	Component      uint16           `json:"component"`
 	RelatedAddress string           `json:"relatedAddress"`
 	RelatedPort    uint16           `json:"relatedPort"`
+
+	// sdpMid and sdpMLineIndex identify the media section the candidate was
+	// gathered for, they are only known for local candidates
+	sdpMid        string
+	sdpMLineIndex uint16
 }
 
 // Conversion for package sdp
@@ -62,7 +65,7 @@ func (c ICECandidate) toSDP() sdp.ICECandidate {
 
 // Conversion for package ice
 
-func newICECandidatesFromICE(iceCandidates []*ice.Candidate) ([]ICECandidate, error) {
+func newICECandidatesFromICE(iceCandidates []ice.Candidate) ([]ICECandidate, error) {
 	candidates := []ICECandidate{}
 
 	for _, i := range iceCandidates {
@@ -76,12 +79,12 @@ func newICECandidatesFromICE(iceCandidates []*ice.Candidate) ([]ICECandidate, er
 	return candidates, nil
 }
 
-func newICECandidateFromICE(i *ice.Candidate) (ICECandidate, error) {
-	typ, err := convertTypeFromICE(i.Type)
+func newICECandidateFromICE(i ice.Candidate) (ICECandidate, error) {
+	typ, err := convertTypeFromICE(i.Type())
 	if err != nil {
 		return ICECandidate{}, err
 	}
-	protocol, err := newICEProtocol(i.NetworkType.NetworkShort())
+	protocol, err := newICEProtocol(i.NetworkType().NetworkShort())
 	if err != nil {
 		return ICECandidate{}, err
 	}
@@ -89,39 +92,61 @@ func newICECandidateFromICE(i *ice.Candidate) (ICECandidate, error) {
 	c := ICECandidate{
 		Foundation: "foundation",
 		Priority:   i.Priority(),
-		IP:         i.IP.String(),
+		IP:         i.Address(),
 		Protocol:   protocol,
-		Port:       uint16(i.Port),
-		Component:  i.Component,
+		Port:       uint16(i.Port()),
+		Component:  i.Component(),
 		Typ:        typ,
 	}
 
-	if i.RelatedAddress != nil {
-		c.RelatedAddress = i.RelatedAddress.Address
-		c.RelatedPort = uint16(i.RelatedAddress.Port)
+	if i.RelatedAddress() != nil {
+		c.RelatedAddress = i.RelatedAddress().Address
+		c.RelatedPort = uint16(i.RelatedAddress().Port)
 	}
 
 	return c, nil
 }
 
-func (c ICECandidate) toICE() (*ice.Candidate, error) {
-	ip := net.ParseIP(c.IP)
-	if ip == nil {
-		return nil, errors.New("failed to parse IP address")
-	}
-
+func (c ICECandidate) toICE() (ice.Candidate, error) {
 	switch c.Typ {
 	case ICECandidateTypeHost:
-		return ice.NewCandidateHost(c.Protocol.String(), ip, int(c.Port), c.Component)
+		config := ice.CandidateHostConfig{
+			Network:   c.Protocol.String(),
+			Address:   c.IP,
+			Port:      int(c.Port),
+			Component: c.Component,
+		}
+		return ice.NewCandidateHost(&config)
 	case ICECandidateTypeSrflx:
-		return ice.NewCandidateServerReflexive(c.Protocol.String(), ip, int(c.Port), c.Component,
-			c.RelatedAddress, int(c.RelatedPort))
+		config := ice.CandidateServerReflexiveConfig{
+			Network:   c.Protocol.String(),
+			Address:   c.IP,
+			Port:      int(c.Port),
+			Component: c.Component,
+			RelAddr:   c.RelatedAddress,
+			RelPort:   int(c.RelatedPort),
+		}
+		return ice.NewCandidateServerReflexive(&config)
 	case ICECandidateTypePrflx:
-		return ice.NewCandidatePeerReflexive(c.Protocol.String(), ip, int(c.Port), c.Component,
-			c.RelatedAddress, int(c.RelatedPort))
+		config := ice.CandidatePeerReflexiveConfig{
+			Network:   c.Protocol.String(),
+			Address:   c.IP,
+			Port:      int(c.Port),
+			Component: c.Component,
+			RelAddr:   c.RelatedAddress,
+			RelPort:   int(c.RelatedPort),
+		}
+		return ice.NewCandidatePeerReflexive(&config)
 	case ICECandidateTypeRelay:
-		return ice.NewCandidateRelay(c.Protocol.String(), ip, int(c.Port), c.Component,
-			c.RelatedAddress, int(c.RelatedPort))
+		config := ice.CandidateRelayConfig{
+			Network:   c.Protocol.String(),
+			Address:   c.IP,
+			Port:      int(c.Port),
+			Component: c.Component,
+			RelAddr:   c.RelatedAddress,
+			RelPort:   int(c.RelatedPort),
+		}
+		return ice.NewCandidateRelay(&config)
 	default:
 		return nil, fmt.Errorf("unknown candidate type: %s", c.Typ)
 	}
@@ -149,3 +174,20 @@ func (c ICECandidate) String() string {
 	}
 	return ic.String()
 }
+
+// ToJSON returns an ICECandidateInit
+// as indicated by the spec https://w3c.github.io/webrtc-pc/#dom-rtcicecandidate-tojson
+func (c ICECandidate) ToJSON() ICECandidateInit {
+	sdpMLineIndex := c.sdpMLineIndex
+	media := (&sdp.MediaDescription{}).WithICECandidate(c.toSDP())
+
+	candidateInit := ICECandidateInit{
+		Candidate:     fmt.Sprintf("candidate:%s", media.Attributes[0].Value),
+		SDPMLineIndex: &sdpMLineIndex,
+	}
+	if c.sdpMid != "" {
+		sdpMid := c.sdpMid
+		candidateInit.SDPMid = &sdpMid
+	}
+	return candidateInit
+}
@@ -1,7 +1,6 @@
 package webrtc
 
 import (
-	"net"
 	"testing"
 
 	"github.com/pion/ice"
@@ -9,10 +8,21 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// expectedICECandidate holds the properties of an ice.Candidate that are
+// derived from an ICECandidate
+type expectedICECandidate struct {
+	Address        string
+	NetworkType    ice.NetworkType
+	Port           int
+	Type           ice.CandidateType
+	Component      uint16
+	RelatedAddress *ice.CandidateRelatedAddress
+}
+
 func TestICECandidate_Convert(t *testing.T) {
 	testCases := []struct {
 		native ICECandidate
-		ice    *ice.Candidate
+		ice    expectedICECandidate
 		sdp    sdp.ICECandidate
 	}{
 		{
@@ -24,13 +34,12 @@ func TestICECandidate_Convert(t *testing.T) {
 				Port:       1234,
 				Typ:        ICECandidateTypeHost,
 				Component:  1,
-			}, &ice.Candidate{
-				IP:              net.ParseIP("1.0.0.1"),
-				NetworkType:     ice.NetworkTypeUDP4,
-				Port:            1234,
-				Type:            ice.CandidateTypeHost,
-				Component:       1,
-				LocalPreference: 65535,
+			}, expectedICECandidate{
+				Address:     "1.0.0.1",
+				NetworkType: ice.NetworkTypeUDP4,
+				Port:        1234,
+				Type:        ice.CandidateTypeHost,
+				Component:   1,
 			},
 			sdp.ICECandidate{
 				Foundation: "foundation",
@@ -53,13 +62,12 @@ func TestICECandidate_Convert(t *testing.T) {
 				Component:      1,
 				RelatedAddress: "1.0.0.1",
 				RelatedPort:    4321,
-			}, &ice.Candidate{
-				IP:              net.ParseIP("::1"),
-				NetworkType:     ice.NetworkTypeUDP6,
-				Port:            1234,
-				Type:            ice.CandidateTypeServerReflexive,
-				Component:       1,
-				LocalPreference: 65535,
+			}, expectedICECandidate{
+				Address:     "::1",
+				NetworkType: ice.NetworkTypeUDP6,
+				Port:        1234,
+				Type:        ice.CandidateTypeServerReflexive,
+				Component:   1,
 				RelatedAddress: &ice.CandidateRelatedAddress{
 					Address: "1.0.0.1",
 					Port:    4321,
@@ -88,13 +96,12 @@ func TestICECandidate_Convert(t *testing.T) {
 				Component:      1,
 				RelatedAddress: "1.0.0.1",
 				RelatedPort:    4321,
-			}, &ice.Candidate{
-				IP:              net.ParseIP("::1"),
-				NetworkType:     ice.NetworkTypeUDP6,
-				Port:            1234,
-				Type:            ice.CandidateTypePeerReflexive,
-				Component:       1,
-				LocalPreference: 65535,
+			}, expectedICECandidate{
+				Address:     "::1",
+				NetworkType: ice.NetworkTypeUDP6,
+				Port:        1234,
+				Type:        ice.CandidateTypePeerReflexive,
+				Component:   1,
 				RelatedAddress: &ice.CandidateRelatedAddress{
 					Address: "1.0.0.1",
 					Port:    4321,
@@ -125,7 +132,14 @@ func TestICECandidate_Convert(t *testing.T) {
 		assert.Nil(t, err)
 		assert.Equal(t,
 			testCase.ice,
-			actualICE,
+			expectedICECandidate{
+				Address:        actualICE.Address(),
+				NetworkType:    actualICE.NetworkType(),
+				Port:           actualICE.Port(),
+				Type:           actualICE.Type(),
+				Component:      actualICE.Component(),
+				RelatedAddress: actualICE.RelatedAddress(),
+			},
 			"testCase: %d ice not equal %v", i, actualSDP,
 		)
 	}
@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"github.com/pion/ice"
+	"github.com/pion/logging"
 )
 
 // The ICEGatherer gathers local host, server reflexive and relay
@@ -15,12 +16,21 @@ import (
 // exchanged in signaling.
 type ICEGatherer struct {
 	lock  sync.RWMutex
+	log   logging.LeveledLogger
 	state ICEGathererState
 
 	validatedServers []*ice.URL
 
 	agent *ice.Agent
 
+	// emitted holds the candidates that have been passed to
+	// onLocalCandidateHdlr, it is guarded by emitLock
+	emitted  map[string]bool
+	emitLock sync.Mutex
+
+	onLocalCandidateHdlr func(candidate *ICECandidate)
+	onStateChangeHdlr    func(state ICEGathererState)
+
 	api *API
 }
 
@@ -42,7 +52,9 @@ func (api *API) NewICEGatherer(opts ICEGatherOptions) (*ICEGatherer, error) {
 	return &ICEGatherer{
 		state:            ICEGathererStateNew,
 		validatedServers: validatedServers,
+		emitted:          map[string]bool{},
 		api:              api,
+		log:              api.settingEngine.LoggerFactory.NewLogger("ice"),
 	}, nil
 }
 
@@ -53,10 +65,46 @@ func (g *ICEGatherer) State() ICEGathererState {
 	return g.state
 }
 
-// Gather ICE candidates.
-func (g *ICEGatherer) Gather() error {
+// OnLocalCandidate sets an event handler which fires when a new local ICE
+// candidate is available. The handler is called with nil once gathering
+// is complete.
+func (g *ICEGatherer) OnLocalCandidate(f func(*ICECandidate)) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.onLocalCandidateHdlr = f
+}
+
+// OnStateChange sets an event handler which fires any time the
+// ICEGatherer changes its state.
+func (g *ICEGatherer) OnStateChange(f func(ICEGathererState)) {
 	g.lock.Lock()
 	defer g.lock.Unlock()
+	g.onStateChangeHdlr = f
+}
+
+func (g *ICEGatherer) setState(state ICEGathererState) {
+	g.lock.Lock()
+	g.state = state
+	hdlr := g.onStateChangeHdlr
+	g.lock.Unlock()
+
+	if hdlr != nil {
+		hdlr(state)
+	}
+}
+
+// createAgent creates the underlying ice.Agent without gathering any
+// candidates, this allows the local parameters to be signaled before
+// gathering has started.
+func (g *ICEGatherer) createAgent() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.agent != nil {
+		return nil
+	} else if g.state == ICEGathererStateClosed {
+		return errors.New("gatherer is closed")
+	}
 
 	config := &ice.AgentConfig{
 		Urls:              g.validatedServers,
@@ -65,6 +113,8 @@ func (g *ICEGatherer) Gather() error {
 		ConnectionTimeout: g.api.settingEngine.timeout.ICEConnection,
 		KeepaliveInterval: g.api.settingEngine.timeout.ICEKeepalive,
 		LoggerFactory:     g.api.settingEngine.LoggerFactory,
+		Trickle:           true,
+		MulticastDNSMode:  ice.MulticastDNSModeDisabled,
 	}
 
 	requestedNetworkTypes := g.api.settingEngine.candidates.ICENetworkTypes
@@ -82,16 +132,116 @@ func (g *ICEGatherer) Gather() error {
 	}
 
 	g.agent = agent
-	g.state = ICEGathererStateComplete
+	return nil
+}
+
+// restart replaces the ice.Agent with a new one that has fresh local
+// credentials and no candidates. The previous agent is left to the
+// ICETransport, which keeps using it until the new one is connected.
+func (g *ICEGatherer) restart() error {
+	g.lock.Lock()
+	if g.state == ICEGathererStateClosed {
+		g.lock.Unlock()
+		return errors.New("gatherer is closed")
+	}
+	g.agent = nil
+	g.lock.Unlock()
+
+	g.emitLock.Lock()
+	g.emitted = map[string]bool{}
+	g.emitLock.Unlock()
+
+	if err := g.createAgent(); err != nil {
+		return err
+	}
 
+	g.setState(ICEGathererStateNew)
 	return nil
 }
 
+// Gather ICE candidates. Gathering happens in the background, candidates
+// are delivered one by one through OnLocalCandidate.
+func (g *ICEGatherer) Gather() error {
+	if err := g.createAgent(); err != nil {
+		return err
+	}
+
+	g.lock.RLock()
+	agent := g.agent
+	state := g.state
+	g.lock.RUnlock()
+
+	if state != ICEGathererStateNew {
+		return errors.New("gather has already been called")
+	}
+
+	if err := agent.OnCandidate(func(candidate ice.Candidate) {
+		if candidate != nil {
+			g.emitCandidate(candidate)
+			return
+		}
+
+		// The agent doesn't order its callbacks, so make sure every gathered
+		// candidate has been emitted before signaling completion
+		candidates, err := agent.GetLocalCandidates()
+		if err != nil {
+			g.log.Warnf("Failed to get local candidates: %s", err)
+		}
+		for _, c := range candidates {
+			g.emitCandidate(c)
+		}
+
+		g.emitLock.Lock()
+		defer g.emitLock.Unlock()
+		if g.State() == ICEGathererStateGathering {
+			g.setState(ICEGathererStateComplete)
+		}
+
+		g.lock.RLock()
+		hdlr := g.onLocalCandidateHdlr
+		g.lock.RUnlock()
+		if hdlr != nil {
+			hdlr(nil)
+		}
+	}); err != nil {
+		return err
+	}
+
+	g.setState(ICEGathererStateGathering)
+	return agent.GatherCandidates()
+}
+
+// emitCandidate passes a candidate to the OnLocalCandidate handler, every
+// candidate is only emitted once
+func (g *ICEGatherer) emitCandidate(candidate ice.Candidate) {
+	g.emitLock.Lock()
+	defer g.emitLock.Unlock()
+
+	if g.emitted[candidate.String()] || g.State() != ICEGathererStateGathering {
+		return
+	}
+	g.emitted[candidate.String()] = true
+
+	c, err := newICECandidateFromICE(candidate)
+	if err != nil {
+		g.log.Warnf("Failed to convert ice.Candidate: %s", err)
+		return
+	}
+
+	g.lock.RLock()
+	hdlr := g.onLocalCandidateHdlr
+	g.lock.RUnlock()
+	if hdlr != nil {
+		hdlr(&c)
+	}
+}
+
 // Close prunes all local candidates, and closes the ports.
 func (g *ICEGatherer) Close() error {
 	g.lock.Lock()
 	defer g.lock.Unlock()
 
+	g.state = ICEGathererStateClosed
 	if g.agent == nil {
 		return nil
 	}
@@ -105,13 +255,20 @@ func (g *ICEGatherer) Close() error {
 	return nil
 }
 
+func (g *ICEGatherer) getAgent() *ice.Agent {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	return g.agent
+}
+
 // GetLocalParameters returns the ICE parameters of the ICEGatherer.
 func (g *ICEGatherer) GetLocalParameters() (ICEParameters, error) {
+	if err := g.createAgent(); err != nil {
+		return ICEParameters{}, err
+	}
+
 	g.lock.RLock()
 	defer g.lock.RUnlock()
-	if g.agent == nil {
-		return ICEParameters{}, errors.New("gatherer not started")
-	}
 
 	frag, pwd := g.agent.GetLocalUserCredentials()
 
@@ -124,13 +281,13 @@ func (g *ICEGatherer) GetLocalParameters() (ICEParameters, error) {
 
 // GetLocalCandidates returns the sequence of valid local candidates associated with the ICEGatherer.
 func (g *ICEGatherer) GetLocalCandidates() ([]ICECandidate, error) {
+	if err := g.createAgent(); err != nil {
+		return nil, err
+	}
+
 	g.lock.RLock()
 	defer g.lock.RUnlock()
 
-	if g.agent == nil {
-		return nil, errors.New("gatherer not started")
-	}
-
 	iceCandidates, err := g.agent.GetLocalCandidates()
 	if err != nil {
 		return nil, err
@@ -32,11 +32,20 @@ func TestNewICEGatherer_Success(t *testing.T) {
 		t.Fatalf("Expected gathering state new")
 	}
 
+	gatherFinished := make(chan struct{})
+	gatherer.OnLocalCandidate(func(i *ICECandidate) {
+		if i == nil {
+			close(gatherFinished)
+		}
+	})
+
 	err = gatherer.Gather()
 	if err != nil {
 		t.Error(err)
 	}
 
+	<-gatherFinished
+
 	params, err := gatherer.GetLocalParameters()
 	if err != nil {
 		t.Error(err)
@@ -5,7 +5,11 @@ package webrtc
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/pion/ice"
 	"github.com/pion/logging"
@@ -26,9 +30,13 @@ type ICETransport struct {
 	onSelectedCandidatePairChangeHdlr func(*ICECandidatePair)
 
 	gatherer *ICEGatherer
-	conn     *ice.Conn
+	agent    *ice.Agent
+	conn     *restartableConn
 	mux      *mux.Mux
 
+	// remoteParameters are the credentials agent connects with
+	remoteParameters ICEParameters
+
 	api *API
 
 	log logging.LeveledLogger
@@ -78,20 +86,8 @@ func (t *ICETransport) Start(gatherer *ICEGatherer, params ICEParameters, role *
 		return err
 	}
 
-	agent := t.gatherer.agent
-	if err := agent.OnConnectionStateChange(func(iceState ice.ConnectionState) {
-		t.onConnectionStateChange(newICETransportStateFromICE(iceState))
-	}); err != nil {
-		return err
-	}
-	if err := agent.OnSelectedCandidatePairChange(func(local, remote *ice.Candidate) {
-		candidates, err := newICECandidatesFromICE([]*ice.Candidate{local, remote})
-		if err != nil {
-			t.log.Warnf("Unable to convert ICE candidates to ICECandidates: %s", err)
-			return
-		}
-		t.onSelectedCandidatePairChange(NewICECandidatePair(&candidates[0], &candidates[1]))
-	}); err != nil {
+	agent := t.gatherer.getAgent()
+	if err := t.bindAgent(agent); err != nil {
 		return err
 	}
 
@@ -100,35 +96,30 @@ func (t *ICETransport) Start(gatherer *ICEGatherer, params ICEParameters, role *
 		role = &controlled
 	}
 	t.role = *role
+	t.remoteParameters = params
 
 	// Drop the lock here to allow trickle-ICE candidates to be
 	// added so that the agent can complete a connection
 	t.lock.Unlock()
 
-	var iceConn *ice.Conn
-	var err error
-	switch *role {
-	case ICERoleControlling:
-		iceConn, err = agent.Dial(context.TODO(),
-			params.UsernameFragment,
-			params.Password)
-
-	case ICERoleControlled:
-		iceConn, err = agent.Accept(context.TODO(),
-			params.UsernameFragment,
-			params.Password)
-
-	default:
-		err = errors.New("unknown ICE Role")
-	}
+	iceConn, err := t.connect(agent, *role, params)
 
 	// Reacquire the lock to set the connection/mux
 	t.lock.Lock()
+
+	// A restart replaced the agent before it could connect, continue
+	// with the replacement
+	for err != nil && agent != t.agent {
+		agent, params = t.agent, t.remoteParameters
+		t.lock.Unlock()
+		iceConn, err = t.connect(agent, *role, params)
+		t.lock.Lock()
+	}
 	if err != nil {
 		return err
 	}
 
-	t.conn = iceConn
+	t.conn = &restartableConn{conn: iceConn}
 
 	config := mux.Config{
 		Conn:          t.conn,
@@ -140,6 +131,132 @@ func (t *ICETransport) Start(gatherer *ICEGatherer, params ICEParameters, role *
 	return nil
 }
 
+// restart starts connectivity checks on the agent of a restarted
+// ICEGatherer. It returns once the checks are started, packets keep
+// flowing over the previous agent until the new one is connected, so the
+// transports on top of the Mux are not interrupted.
+// https://tools.ietf.org/html/rfc8445#section-2.4
+func (t *ICETransport) restart(params ICEParameters) error {
+	switch {
+	case params.UsernameFragment == "":
+		return ice.ErrRemoteUfragEmpty
+	case params.Password == "":
+		return ice.ErrRemotePwdEmpty
+	}
+
+	t.lock.Lock()
+	agent := t.gatherer.getAgent()
+	if agent == nil {
+		t.lock.Unlock()
+		return errors.New("gatherer not started")
+	}
+
+	prev := t.agent
+	if err := t.bindAgent(agent); err != nil {
+		t.lock.Unlock()
+		return err
+	}
+	t.remoteParameters = params
+	role := t.role
+
+	// Start is still waiting for the previous agent to connect, closing
+	// it hands the connectivity checks over to the new agent
+	if t.conn == nil {
+		t.lock.Unlock()
+		if prev == nil {
+			return nil
+		}
+		return prev.Close()
+	}
+	t.lock.Unlock()
+
+	// Connecting blocks until the remote agent has restarted as well, which
+	// may depend on the description that is being applied
+	go func() {
+		if err := t.swapConn(agent, role, params); err != nil {
+			t.log.Warnf("Failed to restart ICE: %s", err)
+		}
+	}()
+	return nil
+}
+
+// swapConn connects agent and moves the transports on top of the Mux
+// over to it
+func (t *ICETransport) swapConn(agent *ice.Agent, role ICERole, params ICEParameters) error {
+	iceConn, err := t.connect(agent, role, params)
+	if err != nil {
+		return err
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// The agent was replaced by another restart in the meantime
+	if agent != t.agent {
+		return iceConn.Close()
+	}
+	return t.conn.swap(iceConn)
+}
+
+// hasPendingRestart reports whether the ICEGatherer has been restarted
+// without connectivity checks having been started for its new agent
+func (t *ICETransport) hasPendingRestart() bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.agent != nil && t.gatherer.getAgent() != t.agent
+}
+
+// bindAgent makes agent the source of the state and candidate pair
+// events of the ICETransport, events of previous agents are dropped.
+func (t *ICETransport) bindAgent(agent *ice.Agent) error {
+	t.agent = agent
+
+	if err := agent.OnConnectionStateChange(func(iceState ice.ConnectionState) {
+		if t.isCurrentAgent(agent) {
+			t.onConnectionStateChange(newICETransportStateFromICE(iceState))
+		}
+	}); err != nil {
+		return err
+	}
+
+	return agent.OnSelectedCandidatePairChange(func(local, remote ice.Candidate) {
+		if !t.isCurrentAgent(agent) {
+			return
+		}
+		candidates, err := newICECandidatesFromICE([]ice.Candidate{local, remote})
+		if err != nil {
+			t.log.Warnf("Unable to convert ICE candidates to ICECandidates: %s", err)
+			return
+		}
+		t.onSelectedCandidatePairChange(NewICECandidatePair(&candidates[0], &candidates[1]))
+	})
+}
+
+func (t *ICETransport) isCurrentAgent(agent *ice.Agent) bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.agent == agent
+}
+
+// connect blocks until agent has connected to the remote agent
+func (t *ICETransport) connect(agent *ice.Agent, role ICERole, params ICEParameters) (*ice.Conn, error) {
+	switch role {
+	case ICERoleControlling:
+		return agent.Dial(context.TODO(),
+			params.UsernameFragment,
+			params.Password)
+
+	case ICERoleControlled:
+		return agent.Accept(context.TODO(),
+			params.UsernameFragment,
+			params.Password)
+
+	default:
+		return nil, errors.New("unknown ICE Role")
+	}
+}
+
 // Stop irreversibly stops the ICETransport.
 func (t *ICETransport) Stop() error {
 	// Close the Mux. This closes the Mux and the underlying ICE conn.
@@ -147,8 +264,18 @@ func (t *ICETransport) Stop() error {
 	defer t.lock.Unlock()
 
 	if t.mux != nil {
-		return t.mux.Close()
-	} else if t.gatherer != nil {
+		if err := t.mux.Close(); err != nil {
+			return err
+		}
+
+		// The agent of a restart that never connected is still owned
+		// by the gatherer
+		if t.gatherer == nil || t.gatherer.getAgent() == t.agent {
+			return nil
+		}
+	}
+
+	if t.gatherer != nil {
 		return t.gatherer.Close()
 	}
 
@@ -211,7 +338,7 @@ func (t *ICETransport) SetRemoteCandidates(remoteCandidates []ICECandidate) erro
 		if err != nil {
 			return err
 		}
-		err = t.gatherer.agent.AddRemoteCandidate(i)
+		err = t.gatherer.getAgent().AddRemoteCandidate(i)
 		if err != nil {
 			return err
 		}
@@ -233,7 +360,7 @@ func (t *ICETransport) AddRemoteCandidate(remoteCandidate ICECandidate) error {
 	if err != nil {
 		return err
 	}
-	err = t.gatherer.agent.AddRemoteCandidate(c)
+	err = t.gatherer.getAgent().AddRemoteCandidate(c)
 	if err != nil {
 		return err
 	}
@@ -241,11 +368,230 @@ func (t *ICETransport) AddRemoteCandidate(remoteCandidate ICECandidate) error {
 	return nil
 }
 
+// collectStats adds the stats of the candidates and candidate pairs of the
+// current agent to report and returns the ID of the selected candidate pair.
+// The traffic of the transport is accounted to the selected pair.
+func (t *ICETransport) collectStats(report StatsReport, transportID string) string {
+	t.lock.RLock()
+	agent := t.agent
+	conn := t.conn
+	t.lock.RUnlock()
+
+	if agent == nil && t.gatherer != nil {
+		agent = t.gatherer.getAgent()
+	}
+	if agent == nil {
+		return ""
+	}
+
+	for _, candidateStats := range agent.GetLocalCandidatesStats() {
+		stats, err := newICECandidateStatsFromICE(candidateStats, StatsTypeLocalCandidate, transportID)
+		if err != nil {
+			t.log.Warnf("Unable to convert ICE candidate stats: %s", err)
+			continue
+		}
+		report[stats.ID] = stats
+	}
+	for _, candidateStats := range agent.GetRemoteCandidatesStats() {
+		stats, err := newICECandidateStatsFromICE(candidateStats, StatsTypeRemoteCandidate, transportID)
+		if err != nil {
+			t.log.Warnf("Unable to convert ICE candidate stats: %s", err)
+			continue
+		}
+		report[stats.ID] = stats
+	}
+
+	selectedPairID := ""
+	for _, pairStats := range agent.GetCandidatePairsStats() {
+		stats := newICECandidatePairStatsFromICE(pairStats, transportID)
+
+		if stats.Nominated && stats.State == StatsICECandidatePairStateSucceeded {
+			selectedPairID = stats.ID
+			if conn != nil {
+				stats.PacketsSent = atomic.LoadUint32(&conn.packetsSent)
+				stats.PacketsReceived = atomic.LoadUint32(&conn.packetsReceived)
+				stats.BytesSent = atomic.LoadUint64(&conn.bytesSent)
+				stats.BytesReceived = atomic.LoadUint64(&conn.bytesReceived)
+			}
+		}
+		report[stats.ID] = stats
+	}
+
+	return selectedPairID
+}
+
+// collectTransportStats fills the packet and byte counters of stats
+func (t *ICETransport) collectTransportStats(stats *TransportStats) {
+	t.lock.RLock()
+	conn := t.conn
+	t.lock.RUnlock()
+
+	stats.ICERole = t.Role()
+	if conn == nil {
+		return
+	}
+	stats.PacketsSent = atomic.LoadUint32(&conn.packetsSent)
+	stats.PacketsReceived = atomic.LoadUint32(&conn.packetsReceived)
+	stats.BytesSent = atomic.LoadUint64(&conn.bytesSent)
+	stats.BytesReceived = atomic.LoadUint64(&conn.bytesReceived)
+}
+
+func newICECandidateStatsFromICE(s ice.CandidateStats, statsType StatsType, transportID string) (ICECandidateStats, error) {
+	networkType, err := newNetworkType(s.NetworkType.String())
+	if err != nil {
+		return ICECandidateStats{}, err
+	}
+	candidateType, err := convertTypeFromICE(s.CandidateType)
+	if err != nil {
+		return ICECandidateStats{}, err
+	}
+
+	return ICECandidateStats{
+		Timestamp:     statsTimestampFrom(s.Timestamp),
+		Type:          statsType,
+		ID:            s.ID,
+		TransportID:   transportID,
+		NetworkType:   networkType,
+		IP:            s.IP,
+		Port:          int32(s.Port),
+		Protocol:      "udp",
+		CandidateType: candidateType,
+		Priority:      int32(s.Priority),
+		URL:           s.URL,
+		RelayProtocol: s.RelayProtocol,
+		Deleted:       s.Deleted,
+	}, nil
+}
+
+func newICECandidatePairStatsFromICE(s ice.CandidatePairStats, transportID string) ICECandidatePairStats {
+	return ICECandidatePairStats{
+		Timestamp:            statsTimestampFrom(s.Timestamp),
+		Type:                 StatsTypeCandidatePair,
+		ID:                   fmt.Sprintf("%s-%s", s.LocalCandidateID, s.RemoteCandidateID),
+		TransportID:          transportID,
+		LocalCandidateID:     s.LocalCandidateID,
+		RemoteCandidateID:    s.RemoteCandidateID,
+		State:                newStatsICECandidatePairStateFromICE(s.State),
+		Nominated:            s.Nominated,
+		TotalRoundTripTime:   s.TotalRoundTripTime,
+		CurrentRoundTripTime: s.CurrentRoundTripTime,
+	}
+}
+
+func newStatsICECandidatePairStateFromICE(state ice.CandidatePairState) StatsICECandidatePairState {
+	switch state {
+	case ice.CandidatePairStateWaiting:
+		return StatsICECandidatePairStateWaiting
+	case ice.CandidatePairStateInProgress:
+		return StatsICECandidatePairStateInProgress
+	case ice.CandidatePairStateFailed:
+		return StatsICECandidatePairStateFailed
+	case ice.CandidatePairStateSucceeded:
+		return StatsICECandidatePairStateSucceeded
+	default:
+		return StatsICECandidatePairStateFrozen
+	}
+}
+
 func (t *ICETransport) ensureGatherer() error {
-	if t.gatherer == nil ||
-		t.gatherer.agent == nil {
+	if t.gatherer == nil {
 		return errors.New("gatherer not started")
+	} else if t.gatherer.State() == ICEGathererStateClosed {
+		return errors.New("gatherer is closed")
+	} else if t.gatherer.getAgent() == nil {
+		// Connectivity checks can start before gathering, candidates are
+		// picked up by the agent as they are gathered
+		return t.gatherer.createAgent()
 	}
 
 	return nil
 }
+
+// restartableConn is the net.Conn the Mux reads from. It allows the
+// underlying ice.Conn to be replaced after an ICE restart.
+type restartableConn struct {
+	// The counters are updated atomically, the 64 bit ones come first to
+	// keep them aligned
+	bytesSent       uint64
+	bytesReceived   uint64
+	packetsSent     uint32
+	packetsReceived uint32
+
+	lock   sync.RWMutex
+	conn   *ice.Conn
+	closed bool
+}
+
+func (c *restartableConn) current() *ice.Conn {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.conn
+}
+
+// swap replaces the underlying ice.Conn and closes the previous one
+func (c *restartableConn) swap(conn *ice.Conn) error {
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		return conn.Close()
+	}
+	prev := c.conn
+	c.conn = conn
+	c.lock.Unlock()
+
+	return prev.Close()
+}
+
+func (c *restartableConn) Read(p []byte) (int, error) {
+	for {
+		conn := c.current()
+		n, err := conn.Read(p)
+		if err != nil && conn != c.current() {
+			// The conn was closed by swap, continue with its replacement
+			continue
+		}
+		if err == nil {
+			atomic.AddUint32(&c.packetsReceived, 1)
+			atomic.AddUint64(&c.bytesReceived, uint64(n))
+		}
+		return n, err
+	}
+}
+
+func (c *restartableConn) Write(p []byte) (int, error) {
+	n, err := c.current().Write(p)
+	if err == nil {
+		atomic.AddUint32(&c.packetsSent, 1)
+		atomic.AddUint64(&c.bytesSent, uint64(n))
+	}
+	return n, err
+}
+
+func (c *restartableConn) Close() error {
+	c.lock.Lock()
+	c.closed = true
+	conn := c.conn
+	c.lock.Unlock()
+
+	return conn.Close()
+}
+
+func (c *restartableConn) LocalAddr() net.Addr {
+	return c.current().LocalAddr()
+}
+
+func (c *restartableConn) RemoteAddr() net.Addr {
+	return c.current().RemoteAddr()
+}
+
+func (c *restartableConn) SetDeadline(t time.Time) error {
+	return c.current().SetDeadline(t)
+}
+
+func (c *restartableConn) SetReadDeadline(t time.Time) error {
+	return c.current().SetReadDeadline(t)
+}
+
+func (c *restartableConn) SetWriteDeadline(t time.Time) error {
+	return c.current().SetWriteDeadline(t)
+}
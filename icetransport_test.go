@@ -8,7 +8,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pion/ice"
 	"github.com/pion/transport/test"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestICETransport_OnSelectedCandidatePairChange(t *testing.T) {
@@ -72,3 +74,29 @@ func TestICETransport_OnSelectedCandidatePairChange(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestNewICECandidatePairStatsFromICE(t *testing.T) {
+	now := time.Now()
+	stats := newICECandidatePairStatsFromICE(ice.CandidatePairStats{
+		Timestamp:            now,
+		LocalCandidateID:     "local",
+		RemoteCandidateID:    "remote",
+		State:                ice.CandidatePairStateSucceeded,
+		Nominated:            true,
+		TotalRoundTripTime:   0.3,
+		CurrentRoundTripTime: 0.1,
+	}, "transport")
+
+	assert.Equal(t, ICECandidatePairStats{
+		Timestamp:            statsTimestampFrom(now),
+		Type:                 StatsTypeCandidatePair,
+		ID:                   "local-remote",
+		TransportID:          "transport",
+		LocalCandidateID:     "local",
+		RemoteCandidateID:    "remote",
+		State:                StatsICECandidatePairStateSucceeded,
+		Nominated:            true,
+		TotalRoundTripTime:   0.3,
+		CurrentRoundTripTime: 0.1,
+	}, stats)
+}
@@ -0,0 +1,71 @@
+// +build !js
+
+package webrtc
+
+import (
+	"github.com/pion/srtp"
+	"github.com/pion/webrtc/v2/pkg/interceptor"
+)
+
+// newInterceptorStreamInfo describes the stream with ssrc to the
+// interceptors, its codec is the one of codecs with payloadType or fallback
+// if none matches
+func newInterceptorStreamInfo(id string, ssrc uint32, payloadType uint8, codecs []RTPCodecParameters, headerExtensions []RTPHeaderExtensionParameters, fallback *RTPCodec) *interceptor.StreamInfo {
+	info := &interceptor.StreamInfo{
+		ID:          id,
+		Attributes:  interceptor.Attributes{},
+		SSRC:        ssrc,
+		PayloadType: payloadType,
+	}
+	for _, e := range headerExtensions {
+		info.RTPHeaderExtensions = append(info.RTPHeaderExtensions, interceptor.RTPHeaderExtension{URI: e.URI, ID: e.ID})
+	}
+
+	var codec *RTPCodecParameters
+	for i := range codecs {
+		if codecs[i].PayloadType == payloadType {
+			codec = &codecs[i]
+			break
+		}
+	}
+	if codec == nil && fallback != nil {
+		codec = &RTPCodecParameters{
+			MimeType:     fallback.MimeType,
+			PayloadType:  fallback.PayloadType,
+			ClockRate:    fallback.ClockRate,
+			Channels:     fallback.Channels,
+			SDPFmtpLine:  fallback.SDPFmtpLine,
+			RTCPFeedback: fallback.RTCPFeedback,
+		}
+	}
+	if codec == nil {
+		return info
+	}
+
+	info.PayloadType = codec.PayloadType
+	info.MimeType = codec.MimeType
+	info.ClockRate = codec.ClockRate
+	info.Channels = codec.Channels
+	info.SDPFmtpLine = codec.SDPFmtpLine
+	for _, f := range codec.RTCPFeedback {
+		info.RTCPFeedback = append(info.RTCPFeedback, interceptor.RTCPFeedback{Type: f.Type, Parameter: f.Parameter})
+	}
+	return info
+}
+
+// srtpReader is the last reader of the interceptors for a stream, it reads
+// from the SRTP read stream
+func srtpReader(stream *srtp.ReadStreamSRTP) interceptor.RTPReader {
+	return interceptor.RTPReaderFunc(func(b []byte, attributes interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, err := stream.Read(b)
+		return n, attributes, err
+	})
+}
+
+// srtcpReader is the last RTCP reader of the interceptors for a stream
+func srtcpReader(stream *srtp.ReadStreamSRTCP) interceptor.RTCPReader {
+	return interceptor.RTCPReaderFunc(func(b []byte, attributes interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, err := stream.Read(b)
+		return n, attributes, err
+	})
+}
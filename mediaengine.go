@@ -13,16 +13,34 @@ import (
 
 // PayloadTypes for the default codecs
 const (
+	DefaultPayloadTypeCN   = 13
 	DefaultPayloadTypeG722 = 9
 	DefaultPayloadTypeOpus = 111
 	DefaultPayloadTypeVP8  = 96
 	DefaultPayloadTypeVP9  = 98
 	DefaultPayloadTypeH264 = 102
+
+	DefaultPayloadTypeVP8RTX  = 97
+	DefaultPayloadTypeVP9RTX  = 99
+	DefaultPayloadTypeH264RTX = 103
+
+	DefaultPayloadTypeRED     = 116
+	DefaultPayloadTypeULPFEC  = 117
+	DefaultPayloadTypeFlexFEC = 118
 )
 
 // MediaEngine defines the codecs supported by a PeerConnection
 type MediaEngine struct {
-	codecs []*RTPCodec
+	codecs           []*RTPCodec
+	headerExtensions []mediaEngineHeaderExtension
+}
+
+// mediaEngineHeaderExtension is a header extension registered for a kind
+// of media with the ID it is offered with
+type mediaEngineHeaderExtension struct {
+	RTPHeaderExtensionCapability
+	kind RTPCodecType
+	id   int
 }
 
 // RegisterCodec registers a codec to a media engine
@@ -32,13 +50,146 @@ func (m *MediaEngine) RegisterCodec(codec *RTPCodec) uint8 {
 	return codec.PayloadType
 }
 
-// RegisterDefaultCodecs is a helper that registers the default codecs supported by pion-webrtc
+// RegisterHeaderExtension registers an RTP header extension for a kind of
+// media and returns the ID it is offered with. An extension registered for
+// both kinds of media has the same ID. The extensions are sent in the
+// one-byte header form, ErrNoHeaderExtensionID is returned once its 14 IDs
+// are in use.
+// https://tools.ietf.org/html/rfc8285#section-5
+func (m *MediaEngine) RegisterHeaderExtension(uri string, kind RTPCodecType) (int, error) {
+	id := 0
+	for _, extension := range m.headerExtensions {
+		if extension.URI != uri {
+			continue
+		}
+		if extension.kind == kind {
+			return extension.id, nil
+		}
+		id = extension.id
+	}
+
+	if id == 0 {
+		if id = m.nextHeaderExtensionID(); id == 0 {
+			return 0, ErrNoHeaderExtensionID
+		}
+	}
+	m.headerExtensions = append(m.headerExtensions, mediaEngineHeaderExtension{
+		RTPHeaderExtensionCapability: RTPHeaderExtensionCapability{URI: uri},
+		kind:                         kind,
+		id:                           id,
+	})
+	return id, nil
+}
+
+// nextHeaderExtensionID returns the lowest ID of the one-byte header form no
+// extension is registered with, it is 0 if all are in use
+// https://tools.ietf.org/html/rfc8285#section-4.2
+func (m *MediaEngine) nextHeaderExtensionID() int {
+	used := map[int]bool{}
+	for _, extension := range m.headerExtensions {
+		used[extension.id] = true
+	}
+	for id := 1; id <= 14; id++ {
+		if !used[id] {
+			return id
+		}
+	}
+	return 0
+}
+
+// getHeaderExtensions returns the header extensions registered for kind
+// with the IDs they are offered with
+func (m *MediaEngine) getHeaderExtensions(kind RTPCodecType) []RTPHeaderExtensionParameters {
+	extensions := []RTPHeaderExtensionParameters{}
+	for _, extension := range m.headerExtensions {
+		if extension.kind == kind {
+			extensions = append(extensions, RTPHeaderExtensionParameters{URI: extension.URI, ID: extension.id})
+		}
+	}
+	return extensions
+}
+
+// RegisterDefaultCodecs is a helper that registers the default codecs and header extensions supported by pion-webrtc
 func (m *MediaEngine) RegisterDefaultCodecs() {
 	m.RegisterCodec(NewRTPOpusCodec(DefaultPayloadTypeOpus, 48000))
 	m.RegisterCodec(NewRTPG722Codec(DefaultPayloadTypeG722, 8000))
+
 	m.RegisterCodec(NewRTPVP8Codec(DefaultPayloadTypeVP8, 90000))
 	m.RegisterCodec(NewRTPH264Codec(DefaultPayloadTypeH264, 90000))
 	m.RegisterCodec(NewRTPVP9Codec(DefaultPayloadTypeVP9, 90000))
+
+	// Streams that aren't signaled with SSRCs, like the layers of
+	// simulcast, are identified by the mid and RID in their packets
+	// The IDs of the default extensions are only in use if the application
+	// registered many of its own before
+	_, _ = m.RegisterHeaderExtension(SDESMidURI, RTPCodecTypeAudio)
+	_, _ = m.RegisterHeaderExtension(SDESMidURI, RTPCodecTypeVideo)
+	_, _ = m.RegisterHeaderExtension(SDESRTPStreamIDURI, RTPCodecTypeVideo)
+	_, _ = m.RegisterHeaderExtension(RepairedRTPStreamIDURI, RTPCodecTypeVideo)
+}
+
+// RegisterDefaultCongestionControl enables transport-cc and goog-remb
+// feedback for the default video codecs registered with
+// RegisterDefaultCodecs and registers the abs-send-time and transport-wide-cc
+// header extensions. The arrival of the packets or the bitrate estimated from
+// it is then fed back and the target bitrate of the senders follows it, see
+// PeerConnection.OnTargetBitrateChange.
+// https://tools.ietf.org/html/draft-holmer-rmcat-transport-wide-cc-extensions-01
+func (m *MediaEngine) RegisterDefaultCongestionControl() {
+	for _, codec := range m.getCodecsByKind(RTPCodecTypeVideo) {
+		switch codec.PayloadType {
+		case DefaultPayloadTypeVP8, DefaultPayloadTypeH264, DefaultPayloadTypeVP9:
+			codec.RTCPFeedback = addRTCPFeedback(codec.RTCPFeedback, RTCPFeedback{Type: TypeRTCPFBTransportCC})
+			codec.RTCPFeedback = addRTCPFeedback(codec.RTCPFeedback, RTCPFeedback{Type: TypeRTCPFBGoogREMB})
+		}
+	}
+
+	for _, kind := range []RTPCodecType{RTPCodecTypeAudio, RTPCodecTypeVideo} {
+		_, _ = m.RegisterHeaderExtension(AbsSendTimeURI, kind)
+		_, _ = m.RegisterHeaderExtension(TransportCCURI, kind)
+	}
+}
+
+// RegisterDefaultRetransmission enables NACKs for the default video codecs
+// registered with RegisterDefaultCodecs and registers an RTX codec for each
+// of them. Lost video packets are then requested again with NACKs and can be
+// retransmitted on an RTX stream, see RTPSender.EnableRetransmission.
+// https://tools.ietf.org/html/rfc4588
+func (m *MediaEngine) RegisterDefaultRetransmission() {
+	rtxPayloadTypes := map[uint8]uint8{
+		DefaultPayloadTypeVP8:  DefaultPayloadTypeVP8RTX,
+		DefaultPayloadTypeH264: DefaultPayloadTypeH264RTX,
+		DefaultPayloadTypeVP9:  DefaultPayloadTypeVP9RTX,
+	}
+	for _, codec := range m.getCodecsByKind(RTPCodecTypeVideo) {
+		rtxPayloadType, ok := rtxPayloadTypes[codec.PayloadType]
+		if !ok {
+			continue
+		}
+
+		codec.RTCPFeedback = addRTCPFeedback(codec.RTCPFeedback, RTCPFeedback{Type: TypeRTCPFBNACK})
+		m.registerDefaultCodec(NewRTPRTXCodec(rtxPayloadType, codec.ClockRate, codec.PayloadType))
+	}
+}
+
+// RegisterDefaultFEC registers the RED, ULPFEC and FlexFEC codecs for video.
+// Lost video packets are then recovered from FEC packets when the sender
+// protects them, see RTPSender.EnableFEC.
+// https://tools.ietf.org/html/rfc5109
+func (m *MediaEngine) RegisterDefaultFEC() {
+	m.registerDefaultCodec(NewRTPREDCodec(DefaultPayloadTypeRED, 90000))
+	m.registerDefaultCodec(NewRTPULPFECCodec(DefaultPayloadTypeULPFEC, 90000))
+	m.registerDefaultCodec(NewRTPFlexFECCodec(DefaultPayloadTypeFlexFEC, 90000))
+}
+
+// registerDefaultCodec registers codec unless its payload type is taken
+// already, so registering defaults more than once doesn't signal codecs
+// twice
+func (m *MediaEngine) registerDefaultCodec(codec *RTPCodec) {
+	if _, err := m.getCodec(codec.PayloadType); err == nil {
+		return
+	}
+	m.RegisterCodec(codec)
 }
 
 func (m *MediaEngine) getCodec(payloadType uint8) (*RTPCodec, error) {
@@ -63,6 +214,19 @@ func (m *MediaEngine) getCodecSDP(sdpCodec sdp.Codec) (*RTPCodec, error) {
 	return nil, ErrCodecNotFound
 }
 
+func (m *MediaEngine) getCodecByCapability(kind RTPCodecType, capability RTPCodecCapability) (*RTPCodec, error) {
+	for _, codec := range m.codecs {
+		if codec.Type == kind &&
+			strings.EqualFold(codec.MimeType, capability.MimeType) &&
+			codec.ClockRate == capability.ClockRate &&
+			codec.Channels == capability.Channels &&
+			codec.SDPFmtpLine == capability.SDPFmtpLine {
+			return codec, nil
+		}
+	}
+	return nil, ErrCodecNotFound
+}
+
 func (m *MediaEngine) getCodecsByKind(kind RTPCodecType) []*RTPCodec {
 	var codecs []*RTPCodec
 	for _, codec := range m.codecs {
@@ -75,13 +239,34 @@ func (m *MediaEngine) getCodecsByKind(kind RTPCodecType) []*RTPCodec {
 
 // Names for the default codecs supported by pion-webrtc
 const (
+	CN   = "CN"
 	G722 = "G722"
 	Opus = "opus"
 	VP8  = "VP8"
 	VP9  = "VP9"
 	H264 = "H264"
+	RTX  = "rtx"
+
+	RED     = "red"
+	ULPFEC  = "ulpfec"
+	FlexFEC = "flexfec-03"
 )
 
+// NewRTPCNCodec is a helper to create a comfort noise codec, it is only
+// signaled when voice activity detection is enabled. It isn't part of
+// RegisterDefaultCodecs and has to be registered to be offered
+// https://tools.ietf.org/html/rfc3389
+func NewRTPCNCodec(payloadType uint8, clockrate uint32) *RTPCodec {
+	c := NewRTPCodec(RTPCodecTypeAudio,
+		CN,
+		clockrate,
+		0,
+		"",
+		payloadType,
+		nil)
+	return c
+}
+
 // NewRTPG722Codec is a helper to create a G722 codec
 func NewRTPG722Codec(payloadType uint8, clockrate uint32) *RTPCodec {
 	c := NewRTPCodec(RTPCodecTypeAudio,
@@ -142,6 +327,81 @@ func NewRTPH264Codec(payloadType uint8, clockrate uint32) *RTPCodec {
 	return c
 }
 
+// NewRTPRTXCodec is a helper to create an RTX codec, retransmissions of
+// packets with the associatedPayloadType are sent with it on a separate
+// stream
+// https://tools.ietf.org/html/rfc4588#section-8.6
+func NewRTPRTXCodec(payloadType uint8, clockrate uint32, associatedPayloadType uint8) *RTPCodec {
+	c := NewRTPCodec(RTPCodecTypeVideo,
+		RTX,
+		clockrate,
+		0,
+		"apt="+strconv.Itoa(int(associatedPayloadType)),
+		payloadType,
+		nil)
+	return c
+}
+
+// NewRTPREDCodec is a helper to create a RED codec, media and ULPFEC
+// packets are sent in its packets
+// https://tools.ietf.org/html/rfc2198
+func NewRTPREDCodec(payloadType uint8, clockrate uint32) *RTPCodec {
+	c := NewRTPCodec(RTPCodecTypeVideo,
+		RED,
+		clockrate,
+		0,
+		"",
+		payloadType,
+		nil)
+	return c
+}
+
+// NewRTPULPFECCodec is a helper to create an ULPFEC codec, its packets are
+// sent in RED packets
+// https://tools.ietf.org/html/rfc5109#section-14.1
+func NewRTPULPFECCodec(payloadType uint8, clockrate uint32) *RTPCodec {
+	c := NewRTPCodec(RTPCodecTypeVideo,
+		ULPFEC,
+		clockrate,
+		0,
+		"",
+		payloadType,
+		nil)
+	return c
+}
+
+// NewRTPFlexFECCodec is a helper to create a FlexFEC codec, its packets
+// are sent on a separate stream
+// https://tools.ietf.org/html/draft-ietf-payload-flexible-fec-scheme-03#section-5.1.2
+func NewRTPFlexFECCodec(payloadType uint8, clockrate uint32) *RTPCodec {
+	c := NewRTPCodec(RTPCodecTypeVideo,
+		FlexFEC,
+		clockrate,
+		0,
+		"repair-window=10000000",
+		payloadType,
+		nil)
+	return c
+}
+
+// rtxAssociatedPayloadType returns the payload type the RTX codec with
+// fmtp retransmits, it is false if fmtp has no apt parameter
+func rtxAssociatedPayloadType(fmtp string) (uint8, bool) {
+	for _, parameter := range strings.Split(fmtp, ";") {
+		parts := strings.SplitN(strings.TrimSpace(parameter), "=", 2)
+		if len(parts) != 2 || parts[0] != "apt" {
+			continue
+		}
+
+		payloadType, err := strconv.ParseUint(parts[1], 10, 8)
+		if err != nil {
+			return 0, false
+		}
+		return uint8(payloadType), true
+	}
+	return 0, false
+}
+
 // RTPCodecType determines the type of a codec
 type RTPCodecType int
 
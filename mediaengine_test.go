@@ -3,6 +3,8 @@
 package webrtc
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/pion/sdp/v2"
@@ -24,6 +26,8 @@ func TestCodecRegistration(t *testing.T) {
 		{DefaultPayloadTypeVP8, nil},
 		{DefaultPayloadTypeVP9, nil},
 		{DefaultPayloadTypeH264, nil},
+		{DefaultPayloadTypeVP8RTX, ErrCodecNotFound},
+		{DefaultPayloadTypeRED, ErrCodecNotFound},
 		{invalidPT, ErrCodecNotFound},
 	}
 
@@ -35,3 +39,162 @@ func TestCodecRegistration(t *testing.T) {
 	_, err := api.mediaEngine.getCodecSDP(sdp.Codec{PayloadType: invalidPT})
 	assert.Equal(t, err, ErrCodecNotFound)
 }
+
+func TestMediaEngine_RegisterDefaultRetransmission(t *testing.T) {
+	m := MediaEngine{}
+	m.RegisterDefaultCodecs()
+	m.RegisterDefaultRetransmission()
+
+	for payloadType, rtxPayloadType := range map[uint8]uint8{
+		DefaultPayloadTypeVP8:  DefaultPayloadTypeVP8RTX,
+		DefaultPayloadTypeH264: DefaultPayloadTypeH264RTX,
+		DefaultPayloadTypeVP9:  DefaultPayloadTypeVP9RTX,
+	} {
+		codec, err := m.getCodec(payloadType)
+		assert.NoError(t, err)
+		assert.True(t, hasRTCPFeedback(codec.RTCPFeedback, RTCPFeedback{Type: TypeRTCPFBNACK}))
+
+		rtx, err := m.getCodec(rtxPayloadType)
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("apt=%d", payloadType), rtx.SDPFmtpLine)
+	}
+
+	// Audio is left as is
+	opus, err := m.getCodec(DefaultPayloadTypeOpus)
+	assert.NoError(t, err)
+	assert.Empty(t, opus.RTCPFeedback)
+}
+
+func TestMediaEngine_RegisterDefaultFEC(t *testing.T) {
+	m := MediaEngine{}
+	m.RegisterDefaultCodecs()
+	m.RegisterDefaultFEC()
+
+	for _, payloadType := range []uint8{DefaultPayloadTypeRED, DefaultPayloadTypeULPFEC, DefaultPayloadTypeFlexFEC} {
+		_, err := m.getCodec(payloadType)
+		assert.NoError(t, err)
+	}
+}
+
+func TestMediaEngine_RegisterDefaultCongestionControl(t *testing.T) {
+	hasHeaderExtension := func(m *MediaEngine, uri string, kind RTPCodecType) bool {
+		for _, extension := range m.headerExtensions {
+			if extension.URI == uri && extension.kind == kind {
+				return true
+			}
+		}
+		return false
+	}
+
+	m := MediaEngine{}
+	m.RegisterDefaultCodecs()
+
+	// The default codecs don't ask for congestion control feedback
+	for _, payloadType := range []uint8{DefaultPayloadTypeVP8, DefaultPayloadTypeH264, DefaultPayloadTypeVP9} {
+		codec, err := m.getCodec(payloadType)
+		assert.NoError(t, err)
+		assert.Empty(t, codec.RTCPFeedback)
+	}
+	assert.False(t, hasHeaderExtension(&m, TransportCCURI, RTPCodecTypeVideo))
+	assert.False(t, hasHeaderExtension(&m, AbsSendTimeURI, RTPCodecTypeVideo))
+
+	m.RegisterDefaultCongestionControl()
+	for _, payloadType := range []uint8{DefaultPayloadTypeVP8, DefaultPayloadTypeH264, DefaultPayloadTypeVP9} {
+		codec, err := m.getCodec(payloadType)
+		assert.NoError(t, err)
+		assert.True(t, hasRTCPFeedback(codec.RTCPFeedback, RTCPFeedback{Type: TypeRTCPFBTransportCC}))
+		assert.True(t, hasRTCPFeedback(codec.RTCPFeedback, RTCPFeedback{Type: TypeRTCPFBGoogREMB}))
+	}
+	for _, kind := range []RTPCodecType{RTPCodecTypeAudio, RTPCodecTypeVideo} {
+		assert.True(t, hasHeaderExtension(&m, TransportCCURI, kind))
+		assert.True(t, hasHeaderExtension(&m, AbsSendTimeURI, kind))
+	}
+}
+
+func TestMediaEngine_RegisterDefaultsTwice(t *testing.T) {
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+	for i := 0; i < 2; i++ {
+		api.mediaEngine.RegisterDefaultRetransmission()
+		api.mediaEngine.RegisterDefaultFEC()
+		api.mediaEngine.RegisterDefaultCongestionControl()
+	}
+
+	pc, err := api.NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+	_, err = pc.AddTransceiverFromKind(RTPCodecTypeVideo)
+	assert.NoError(t, err)
+
+	offer, err := pc.CreateOffer(nil)
+	assert.NoError(t, err)
+
+	// No codec or feedback is signaled twice
+	lines := map[string]bool{}
+	for _, line := range strings.Split(offer.SDP, "\r\n") {
+		if !strings.HasPrefix(line, "a=rtpmap:") && !strings.HasPrefix(line, "a=rtcp-fb:") && !strings.HasPrefix(line, "a=extmap:") {
+			continue
+		}
+		assert.False(t, lines[line], line)
+		lines[line] = true
+	}
+	assert.True(t, lines[fmt.Sprintf("a=rtcp-fb:%d nack", DefaultPayloadTypeVP8)])
+	assert.True(t, lines[fmt.Sprintf("a=rtcp-fb:%d transport-cc", DefaultPayloadTypeVP8)])
+	assert.True(t, lines[fmt.Sprintf("a=rtpmap:%d rtx/90000", DefaultPayloadTypeVP8RTX)])
+	assert.True(t, lines[fmt.Sprintf("a=rtpmap:%d flexfec-03/90000", DefaultPayloadTypeFlexFEC)])
+
+	assert.NoError(t, pc.Close())
+}
+
+func TestRTXAssociatedPayloadType(t *testing.T) {
+	rtx := NewRTPRTXCodec(DefaultPayloadTypeVP8RTX, 90000, DefaultPayloadTypeVP8)
+	assert.Equal(t, "video/rtx", rtx.MimeType)
+
+	apt, ok := rtxAssociatedPayloadType(rtx.SDPFmtpLine)
+	assert.True(t, ok)
+	assert.Equal(t, uint8(DefaultPayloadTypeVP8), apt)
+
+	apt, ok = rtxAssociatedPayloadType("rtx-time=3000; apt=100")
+	assert.True(t, ok)
+	assert.Equal(t, uint8(100), apt)
+
+	_, ok = rtxAssociatedPayloadType("apt=300")
+	assert.False(t, ok)
+	_, ok = rtxAssociatedPayloadType("")
+	assert.False(t, ok)
+
+	// Only RTX codecs are mapped to the payload types they retransmit
+	assert.Equal(t, map[uint8]uint8{DefaultPayloadTypeVP8RTX: DefaultPayloadTypeVP8, DefaultPayloadTypeH264RTX: 102}, rtxAssociatedPayloadTypes([]RTPCodecParameters{
+		{MimeType: "video/VP8", PayloadType: DefaultPayloadTypeVP8},
+		{MimeType: "video/rtx", PayloadType: DefaultPayloadTypeVP8RTX, SDPFmtpLine: "apt=96"},
+		{MimeType: "video/RTX", PayloadType: DefaultPayloadTypeH264RTX, SDPFmtpLine: "apt=102"},
+		{MimeType: "video/rtx", PayloadType: 120},
+	}))
+}
+
+func TestMediaEngine_RegisterHeaderExtension(t *testing.T) {
+	register := func(m *MediaEngine, uri string, kind RTPCodecType) int {
+		id, err := m.RegisterHeaderExtension(uri, kind)
+		assert.NoError(t, err)
+		return id
+	}
+
+	m := MediaEngine{}
+	assert.Equal(t, 1, register(&m, SDESMidURI, RTPCodecTypeAudio))
+	assert.Equal(t, 2, register(&m, AudioLevelURI, RTPCodecTypeAudio))
+
+	// An extension has the same ID for both kinds of media
+	assert.Equal(t, 1, register(&m, SDESMidURI, RTPCodecTypeVideo))
+	assert.Equal(t, 1, register(&m, SDESMidURI, RTPCodecTypeAudio))
+	assert.Equal(t, []RTPHeaderExtensionParameters{{URI: SDESMidURI, ID: 1}}, m.getHeaderExtensions(RTPCodecTypeVideo))
+
+	// Only the IDs of the one-byte header are handed out, 15 is reserved
+	for id := 3; id <= 14; id++ {
+		assert.Equal(t, id, register(&m, fmt.Sprintf("urn:example:%d", id), RTPCodecTypeVideo))
+	}
+	_, err := m.RegisterHeaderExtension(VideoOrientationURI, RTPCodecTypeVideo)
+	assert.Equal(t, ErrNoHeaderExtensionID, err)
+	assert.Equal(t, 13, len(m.getHeaderExtensions(RTPCodecTypeVideo)))
+
+	// Extensions that have an ID can still be registered for another kind
+	assert.Equal(t, 14, register(&m, "urn:example:14", RTPCodecTypeAudio))
+}
@@ -0,0 +1,120 @@
+// +build !js
+
+package webrtc
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+)
+
+const (
+	// nackInterval is how often missing packets are checked for NACKs
+	nackInterval = 20 * time.Millisecond
+
+	// nackRetryInterval is how long a missing packet isn't NACKed again
+	nackRetryInterval = 100 * time.Millisecond
+
+	// nackMaxRetries is how often a missing packet is NACKed at most
+	nackMaxRetries = 5
+
+	// nackMaxAge is how long after its detection a missing packet is given
+	// up on, it is too late to be played out after that
+	nackMaxAge = time.Second
+
+	// nackMaxMissing is the largest gap that is NACKed, bigger gaps are
+	// treated as a restart of the stream
+	nackMaxMissing = 500
+)
+
+// missingPacket is a packet that was skipped by the stream
+type missingPacket struct {
+	detected time.Time
+	lastNACK time.Time
+	nacks    int
+}
+
+// nackGenerator detects sequence number gaps in a received RTP stream and
+// creates Generic NACKs for the missing packets
+// https://tools.ietf.org/html/rfc4585#section-6.2.1
+type nackGenerator struct {
+	lock    sync.Mutex
+	started bool
+	maxSeq  uint16
+	missing map[uint16]*missingPacket
+}
+
+func newNACKGenerator() *nackGenerator {
+	return &nackGenerator{missing: map[uint16]*missingPacket{}}
+}
+
+// received records the arrival of the packet with sequence number seq
+func (g *nackGenerator) received(seq uint16, now time.Time) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if !g.started {
+		g.started = true
+		g.maxSeq = seq
+		return
+	}
+
+	delta := seq - g.maxSeq
+	switch {
+	case delta == 0:
+	case delta >= 1<<15:
+		// A retransmitted or reordered packet
+		delete(g.missing, seq)
+	case delta > nackMaxMissing:
+		g.missing = map[uint16]*missingPacket{}
+		g.maxSeq = seq
+	default:
+		for missing := g.maxSeq + 1; missing != seq; missing++ {
+			g.missing[missing] = &missingPacket{detected: now}
+		}
+		g.maxSeq = seq
+	}
+}
+
+// nackPairs returns the missing packets that are due for a NACK at now,
+// packets that exceeded their age or number of NACKs are given up on
+func (g *nackGenerator) nackPairs(now time.Time) []rtcp.NackPair {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	seqs := []uint16{}
+	for seq, p := range g.missing {
+		if now.Sub(p.detected) > nackMaxAge || p.nacks >= nackMaxRetries {
+			delete(g.missing, seq)
+			continue
+		}
+		if !p.lastNACK.IsZero() && now.Sub(p.lastNACK) < nackRetryInterval {
+			continue
+		}
+
+		p.lastNACK = now
+		p.nacks++
+		seqs = append(seqs, seq)
+	}
+
+	// Oldest first, so consecutive packets share a NackPair across the
+	// wrap around
+	sort.Slice(seqs, func(i, j int) bool {
+		return g.maxSeq-seqs[i] > g.maxSeq-seqs[j]
+	})
+
+	pairs := []rtcp.NackPair{}
+	for _, seq := range seqs {
+		if len(pairs) != 0 {
+			last := &pairs[len(pairs)-1]
+			if distance := seq - last.PacketID; distance <= 16 {
+				last.LostPackets |= 1 << (distance - 1)
+				continue
+			}
+		}
+		pairs = append(pairs, rtcp.NackPair{PacketID: seq})
+	}
+	return pairs
+}
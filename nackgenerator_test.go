@@ -0,0 +1,61 @@
+// +build !js
+
+package webrtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNACKGenerator(t *testing.T) {
+	g := newNACKGenerator()
+	now := time.Now()
+
+	// 65534 and 1 to 17 are missing
+	for _, seq := range []uint16{65533, 65535, 0, 18} {
+		g.received(seq, now)
+	}
+
+	pairs := g.nackPairs(now)
+	assert.Equal(t, []rtcp.NackPair{
+		{PacketID: 65534, LostPackets: 0xfffc},
+		{PacketID: 15, LostPackets: 0x0003},
+	}, pairs)
+
+	// Retransmitted packets are not NACKed again
+	g.received(65534, now)
+	for seq := uint16(1); seq < 17; seq++ {
+		g.received(seq, now)
+	}
+
+	// Packets are NACKed once per retry interval
+	assert.Empty(t, g.nackPairs(now.Add(nackRetryInterval/2)))
+	assert.Equal(t, []rtcp.NackPair{{PacketID: 17}}, g.nackPairs(now.Add(nackRetryInterval)))
+
+	// Packets are given up on after too many NACKs
+	for i := 2; i < nackMaxRetries; i++ {
+		assert.Len(t, g.nackPairs(now.Add(time.Duration(i)*nackRetryInterval)), 1)
+	}
+	assert.Empty(t, g.nackPairs(now.Add(nackMaxRetries*nackRetryInterval)))
+}
+
+func TestNACKGenerator_MaxAge(t *testing.T) {
+	g := newNACKGenerator()
+	now := time.Now()
+
+	g.received(1, now)
+	g.received(3, now)
+	assert.Empty(t, g.nackPairs(now.Add(nackMaxAge+time.Millisecond)))
+}
+
+func TestNACKGenerator_Restart(t *testing.T) {
+	g := newNACKGenerator()
+	now := time.Now()
+
+	g.received(1, now)
+	g.received(1+nackMaxMissing+1, now)
+	assert.Empty(t, g.nackPairs(now))
+}
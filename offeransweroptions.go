@@ -5,7 +5,10 @@ package webrtc
 type OfferAnswerOptions struct {
 	// VoiceActivityDetection allows the application to provide information
 	// about whether it wishes voice detection feature to be enabled or disabled.
-	VoiceActivityDetection bool
+	// It is enabled when VoiceActivityDetection is nil. The comfort noise codec
+	// is only signaled while it is enabled, it has to be registered with the
+	// MediaEngine, see NewRTPCNCodec.
+	VoiceActivityDetection *bool
 }
 
 // AnswerOptions structure describes the options used to control the answer
@@ -21,8 +21,10 @@ import (
 	"github.com/pion/ice"
 	"github.com/pion/logging"
 	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
 	"github.com/pion/sdp/v2"
 	"github.com/pion/webrtc/v2/internal/util"
+	"github.com/pion/webrtc/v2/pkg/interceptor"
 	"github.com/pion/webrtc/v2/pkg/rtcerr"
 )
 
@@ -52,25 +54,46 @@ type PeerConnection struct {
 	isClosed          bool
 	negotiationNeeded bool
 
+	// iceRestartNeeded is set by RestartICE until a local description
+	// replacing the ICE credentials with iceRestartUfrag is applied
+	// https://w3c.github.io/webrtc-pc/#dfn-localicecredentialstoreplace
+	iceRestartNeeded bool
+	iceRestartUfrag  string
+
 	lastOffer  string
 	lastAnswer string
 
+	// remoteICEParameters are the ICE credentials of the remote peer, they
+	// only change when ICE is restarted
+	remoteICEParameters ICEParameters
+
+	// mediaStarted is set once the transports are up and the first set of
+	// senders and receivers has been started, it is guarded by mediaLock
+	mediaStarted bool
+	mediaLock    sync.Mutex
+
+	// iceGatheringComplete is closed once all local candidates are gathered
+	iceGatheringComplete chan struct{}
+
 	rtpTransceivers []*RTPTransceiver
 
 	// DataChannels
-	dataChannels map[uint16]*DataChannel
+	dataChannels          map[uint16]*DataChannel
+	dataChannelsRequested uint32
+	dataChannelsAccepted  uint32
 
-	// OnNegotiationNeeded        func() // FIXME NOT-USED
-	// OnICECandidateError        func() // FIXME NOT-USED
+	statsID string
 
-	// OnConnectionStateChange    func() // FIXME NOT-USED
+	// OnICECandidateError        func() // FIXME NOT-USED
 
 	onSignalingStateChangeHandler     func(SignalingState)
 	onICEConnectionStateChangeHandler func(ICEConnectionState)
+	onConnectionStateChangeHandler    func(PeerConnectionState)
 	onTrackHandler                    func(*Track, *RTPReceiver)
 	onDataChannelHandler              func(*DataChannel)
 	onICECandidateHandler             func(*ICECandidate)
 	onICEGatheringStateChangeHandler  func()
+	onNegotiationNeededHandler        func()
 
 	iceGatherer   *ICEGatherer
 	iceTransport  *ICETransport
@@ -114,6 +137,9 @@ func (api *API) NewPeerConnection(configuration Configuration) (*PeerConnection,
 		iceGatheringState:  ICEGatheringStateNew,
 		connectionState:    PeerConnectionStateNew,
 		dataChannels:       make(map[uint16]*DataChannel),
+		statsID:            newStatsID("PeerConnection"),
+
+		iceGatheringComplete: make(chan struct{}),
 
 		api: api,
 		log: api.settingEngine.LoggerFactory.NewLogger("pc"),
@@ -124,17 +150,18 @@ func (api *API) NewPeerConnection(configuration Configuration) (*PeerConnection,
 		return nil, err
 	}
 
-	// For now we eagerly allocate and start the gatherer
 	gatherer, err := pc.createICEGatherer()
 	if err != nil {
 		return nil, err
 	}
 	pc.iceGatherer = gatherer
 
-	err = pc.gather()
-
-	if err != nil {
-		return nil, err
+	// Without trickle ICE every candidate has to be part of the SDP, so they
+	// are gathered up front. Otherwise gathering starts with SetLocalDescription
+	if !pc.api.settingEngine.candidates.ICETrickle {
+		if err = pc.gather(); err != nil {
+			return nil, err
+		}
 	}
 
 	// Create the ice transport
@@ -148,6 +175,14 @@ func (api *API) NewPeerConnection(configuration Configuration) (*PeerConnection,
 	}
 	pc.dtlsTransport = dtlsTransport
 
+	dtlsTransport.OnStateChange(func(state DTLSTransportState) {
+		// Connected is only reported once media has been started, see startTransports
+		if state == DTLSTransportStateConnected {
+			return
+		}
+		pc.updateConnectionState(pc.ICEConnectionState(), state)
+	})
+
 	return pc, nil
 }
 
@@ -242,6 +277,132 @@ func (pc *PeerConnection) onSignalingStateChange(newState SignalingState) (done
 	return
 }
 
+// OnNegotiationNeeded sets an event handler which is invoked when
+// a change has occurred which requires session negotiation. The event is
+// coalesced, it only fires again once the signaling state returned to stable
+// and negotiation is still needed.
+// https://w3c.github.io/webrtc-pc/#event-negotiation
+func (pc *PeerConnection) OnNegotiationNeeded(f func()) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.onNegotiationNeededHandler = f
+}
+
+// updateNegotiationNeededFlag runs the update the negotiation-needed flag
+// algorithm and fires OnNegotiationNeeded if the flag got set.
+// https://w3c.github.io/webrtc-pc/#updating-the-negotiation-needed-flag
+func (pc *PeerConnection) updateNegotiationNeededFlag() {
+	// Step 2.1, 2.3
+	if pc.closed() || pc.SignalingState() != SignalingStateStable {
+		return
+	}
+
+	// Step 2.4
+	if !pc.checkNegotiationNeeded() {
+		pc.mu.Lock()
+		pc.negotiationNeeded = false
+		pc.mu.Unlock()
+		return
+	}
+
+	// Step 2.5, 2.6
+	pc.mu.Lock()
+	if pc.negotiationNeeded {
+		pc.mu.Unlock()
+		return
+	}
+	pc.negotiationNeeded = true
+	hdlr := pc.onNegotiationNeededHandler
+	pc.mu.Unlock()
+
+	// Step 2.7
+	if hdlr != nil {
+		go hdlr()
+	}
+}
+
+// checkNegotiationNeeded compares the transceivers and data channels to the
+// current local description to determine if a new offer is required.
+// https://w3c.github.io/webrtc-pc/#dfn-check-if-negotiation-is-needed
+func (pc *PeerConnection) checkNegotiationNeeded() bool {
+	localDesc := pc.currentLocalDescription
+
+	pc.mu.RLock()
+	haveDataChannels := len(pc.dataChannels) != 0
+	iceRestartNeeded := pc.iceRestartNeeded
+	pc.mu.RUnlock()
+
+	// Step 3, the local ICE credentials are to be replaced
+	if iceRestartNeeded {
+		return true
+	}
+
+	// Step 4, no local description means anything added must be negotiated
+	if localDesc == nil || localDesc.parsed == nil {
+		return haveDataChannels || len(pc.GetTransceivers()) != 0
+	}
+
+	isPlanB := pc.descriptionIsPlanB(localDesc)
+	findMedia := func(t *RTPTransceiver) *sdp.MediaDescription {
+		for _, media := range localDesc.parsed.MediaDescriptions {
+			if isPlanB && media.MediaName.Media == t.kind.String() {
+				return media
+			} else if !isPlanB && t.Mid != "" && pc.getMidValue(media) == t.Mid {
+				return media
+			}
+		}
+		return nil
+	}
+
+	// Step 5, data channels were created but never negotiated
+	if haveDataChannels {
+		haveApplication := false
+		for _, media := range localDesc.parsed.MediaDescriptions {
+			if media.MediaName.Media == "application" {
+				haveApplication = true
+			}
+		}
+		if !haveApplication {
+			return true
+		}
+	}
+
+	for _, t := range pc.GetTransceivers() {
+		if t.stopped {
+			continue
+		}
+
+		// Step 6.1, the transceiver isn't associated with a media section yet
+		media := findMedia(t)
+		if media == nil {
+			return true
+		}
+
+		// Step 6.2, the track that is sent isn't signaled yet
+		if t.Sender != nil && t.Sender.Track() != nil && t.Direction.hasSend() {
+			ssrc := strconv.FormatUint(uint64(t.Sender.Track().SSRC()), 10)
+			signaled := false
+			for _, attr := range media.Attributes {
+				if attr.Key == sdp.AttrKeySSRC && strings.Split(attr.Value, " ")[0] == ssrc {
+					signaled = true
+					break
+				}
+			}
+			if !signaled {
+				return true
+			}
+		}
+
+		// Step 6.3, the direction changed since the last negotiation. Plan-B
+		// sections carry many transceivers so the direction isn't compared
+		if !isPlanB && pc.getPeerDirection(media) != t.Direction {
+			return true
+		}
+	}
+
+	return false
+}
+
 // OnDataChannel sets an event handler which is invoked when a data
 // channel message arrives from a remote peer.
 func (pc *PeerConnection) OnDataChannel(f func(*DataChannel)) {
@@ -251,9 +412,10 @@ func (pc *PeerConnection) OnDataChannel(f func(*DataChannel)) {
 }
 
 // OnICECandidate sets an event handler which is invoked when a new ICE
-// candidate is found.
-// BUG: trickle ICE is not supported so this event is triggered immediately when
-// SetLocalDescription is called. Typically, you only need to use this method
+// candidate is found. The handler is called with nil once gathering is complete.
+// Unless trickle ICE is enabled via SettingEngine.SetTrickle all candidates are
+// gathered up front and this event is triggered immediately when
+// SetLocalDescription is called. In that case you only need to use this method
 // if you want API compatibility with the JavaScript/Wasm bindings.
 func (pc *PeerConnection) OnICECandidate(f func(*ICECandidate)) {
 	pc.mu.Lock()
@@ -263,19 +425,70 @@ func (pc *PeerConnection) OnICECandidate(f func(*ICECandidate)) {
 
 // OnICEGatheringStateChange sets an event handler which is invoked when the
 // ICE candidate gathering state has changed.
-// BUG: trickle ICE is not supported so this event is triggered immediately when
-// SetLocalDescription is called. Typically, you only need to use this method
-// if you want API compatibility with the JavaScript/Wasm bindings.
+// Unless trickle ICE is enabled via SettingEngine.SetTrickle gathering completes
+// before SetLocalDescription is called, the handler is then triggered when
+// SetLocalDescription is called for API compatibility with the JavaScript/Wasm
+// bindings.
 func (pc *PeerConnection) OnICEGatheringStateChange(f func()) {
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
 	pc.onICEGatheringStateChangeHandler = f
 }
 
+func (pc *PeerConnection) onICECandidate(c *ICECandidate) {
+	pc.mu.RLock()
+	hdlr := pc.onICECandidateHandler
+	if c != nil {
+		c.sdpMid, c.sdpMLineIndex = pc.localCandidateMid()
+	}
+	pc.mu.RUnlock()
+
+	// Without trickle ICE the candidates are signaled in
+	// signalICECandidateGatheringComplete instead
+	if hdlr != nil && pc.api.settingEngine.candidates.ICETrickle {
+		hdlr(c)
+	}
+}
+
+func (pc *PeerConnection) onICEGathererStateChange(state ICEGathererState) {
+	pc.mu.Lock()
+	switch state {
+	case ICEGathererStateNew:
+		// The gatherer was restarted, its candidates are gathered again
+		pc.iceGatheringState = ICEGatheringStateNew
+		pc.iceGatheringComplete = make(chan struct{})
+	case ICEGathererStateGathering:
+		pc.iceGatheringState = ICEGatheringStateGathering
+	case ICEGathererStateComplete:
+		pc.iceGatheringState = ICEGatheringStateComplete
+		// Gathering may complete again without passing through New when the
+		// gatherer is restarted, the channel is only closed once
+		select {
+		case <-pc.iceGatheringComplete:
+		default:
+			close(pc.iceGatheringComplete)
+		}
+	default:
+		pc.mu.Unlock()
+		return
+	}
+	hdlr := pc.onICEGatheringStateChangeHandler
+	pc.mu.Unlock()
+
+	pc.log.Infof("ICE gathering state changed: %s", pc.ICEGatheringState())
+	if hdlr != nil && pc.api.settingEngine.candidates.ICETrickle {
+		go hdlr()
+	}
+}
+
 // signalICECandidateGatheringComplete should be called after ICE candidate
-// gathering is complete. It triggers the appropriate event handlers in order to
-// emulate a trickle ICE process.
+// gathering is complete when trickle ICE is disabled. It triggers the
+// appropriate event handlers in order to emulate a trickle ICE process.
 func (pc *PeerConnection) signalICECandidateGatheringComplete() error {
+	if pc.api.settingEngine.candidates.ICETrickle {
+		return nil
+	}
+
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
 
@@ -285,7 +498,9 @@ func (pc *PeerConnection) signalICECandidateGatheringComplete() error {
 		if err != nil {
 			return err
 		}
+		sdpMid, sdpMLineIndex := pc.localCandidateMid()
 		for i := range candidates {
+			candidates[i].sdpMid, candidates[i].sdpMLineIndex = sdpMid, sdpMLineIndex
 			go pc.onICECandidateHandler(&candidates[i])
 		}
 		// Call the handler one last time with nil. This is a signal that candidate
@@ -293,8 +508,6 @@ func (pc *PeerConnection) signalICECandidateGatheringComplete() error {
 		go pc.onICECandidateHandler(nil)
 	}
 
-	pc.iceGatheringState = ICEGatheringStateComplete
-
 	// Also trigger the onICEGatheringStateChangeHandler
 	if pc.onICEGatheringStateChangeHandler != nil {
 		// Note: Gathering is already done at this point, but some clients might
@@ -333,6 +546,78 @@ func (pc *PeerConnection) onTrack(t *Track, r *RTPReceiver) (done chan struct{})
 	return
 }
 
+// OnTargetBitrateChange sets an event handler which is called with the
+// bitrate in bits per second that the media sent to the remote peer should
+// not exceed when it changes. It is estimated from the transport-cc
+// feedback of the remote peer or taken from its REMB feedback, the handler
+// is called from the goroutine that reads the feedback and should not
+// block. The feedback is only negotiated when it is registered with the
+// MediaEngine, see MediaEngine.RegisterDefaultCongestionControl.
+func (pc *PeerConnection) OnTargetBitrateChange(f func(bitrate uint64)) {
+	pc.dtlsTransport.OnTargetBitrateChange(f)
+}
+
+// OnConnectionStateChange sets an event handler which is called
+// when the PeerConnectionState has changed
+func (pc *PeerConnection) OnConnectionStateChange(f func(PeerConnectionState)) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.onConnectionStateChangeHandler = f
+}
+
+func (pc *PeerConnection) onConnectionStateChange(cs PeerConnectionState) (done chan struct{}) {
+	pc.mu.RLock()
+	hdlr := pc.onConnectionStateChangeHandler
+	pc.mu.RUnlock()
+
+	pc.log.Infof("peer connection state changed: %s", cs)
+	done = make(chan struct{})
+	if hdlr == nil {
+		close(done)
+		return
+	}
+
+	go func() {
+		hdlr(cs)
+		close(done)
+	}()
+
+	return
+}
+
+// updateConnectionState aggregates the states of the ICE and DTLS transports
+// https://w3c.github.io/webrtc-pc/#rtcpeerconnectionstate-enum
+func (pc *PeerConnection) updateConnectionState(iceConnectionState ICEConnectionState, dtlsTransportState DTLSTransportState) {
+	pc.mu.Lock()
+
+	connectionState := PeerConnectionStateNew
+	switch {
+	case pc.isClosed:
+		connectionState = PeerConnectionStateClosed
+	case iceConnectionState == ICEConnectionStateFailed || dtlsTransportState == DTLSTransportStateFailed:
+		connectionState = PeerConnectionStateFailed
+	case iceConnectionState == ICEConnectionStateDisconnected:
+		connectionState = PeerConnectionStateDisconnected
+	case (iceConnectionState == ICEConnectionStateNew || iceConnectionState == ICEConnectionStateClosed) &&
+		(dtlsTransportState == DTLSTransportStateNew || dtlsTransportState == DTLSTransportStateClosed):
+		connectionState = PeerConnectionStateNew
+	case (iceConnectionState == ICEConnectionStateConnected || iceConnectionState == ICEConnectionStateCompleted) &&
+		(dtlsTransportState == DTLSTransportStateConnected || dtlsTransportState == DTLSTransportStateClosed):
+		connectionState = PeerConnectionStateConnected
+	default:
+		connectionState = PeerConnectionStateConnecting
+	}
+
+	if pc.connectionState == connectionState {
+		pc.mu.Unlock()
+		return
+	}
+	pc.connectionState = connectionState
+	pc.mu.Unlock()
+
+	pc.onConnectionStateChange(connectionState)
+}
+
 // OnICEConnectionStateChange sets an event handler which is called
 // when an ICE connection state is changed.
 func (pc *PeerConnection) OnICEConnectionStateChange(f func(ICEConnectionState)) {
@@ -364,7 +649,7 @@ func (pc *PeerConnection) onICEConnectionStateChange(cs ICEConnectionState) (don
 // SetConfiguration updates the configuration of this PeerConnection object.
 func (pc *PeerConnection) SetConfiguration(configuration Configuration) error {
 	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-setconfiguration (step #2)
-	if pc.isClosed {
+	if pc.closed() {
 		return &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
 	}
 
@@ -446,14 +731,33 @@ func (pc *PeerConnection) GetConfiguration() Configuration {
 func (pc *PeerConnection) CreateOffer(options *OfferOptions) (SessionDescription, error) {
 	useIdentity := pc.idpLoginURL != nil
 	switch {
-	case options != nil:
-		return SessionDescription{}, fmt.Errorf("TODO handle options")
 	case useIdentity:
 		return SessionDescription{}, fmt.Errorf("TODO handle identity provider")
-	case pc.isClosed:
+	case pc.closed():
 		return SessionDescription{}, &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
 	}
 
+	pc.mu.RLock()
+	iceRestart := pc.iceRestartNeeded
+	pc.mu.RUnlock()
+
+	voiceActivityDetection := true
+	if options != nil {
+		if options.VoiceActivityDetection != nil {
+			voiceActivityDetection = *options.VoiceActivityDetection
+		}
+		iceRestart = iceRestart || options.ICERestart
+	}
+
+	// Credentials are only replaced once per restart, offers created until
+	// the restart has been negotiated reuse them
+	// https://tools.ietf.org/html/draft-ietf-rtcweb-jsep-26#section-5.2.3.1
+	if iceRestart && pc.currentRemoteDescription != nil && !pc.iceTransport.hasPendingRestart() {
+		if err := pc.restartICE(); err != nil {
+			return SessionDescription{}, err
+		}
+	}
+
 	d := sdp.NewJSEPSessionDescription(useIdentity)
 	if err := pc.addFingerprint(d); err != nil {
 		return SessionDescription{}, err
@@ -470,10 +774,8 @@ func (pc *PeerConnection) CreateOffer(options *OfferOptions) (SessionDescription
 	}
 
 	bundleValue := "BUNDLE"
-	bundleCount := 0
 	appendBundle := func(midValue string) {
 		bundleValue += " " + midValue
-		bundleCount++
 	}
 
 	if pc.configuration.SDPSemantics == SDPSemanticsPlanB {
@@ -488,34 +790,98 @@ func (pc *PeerConnection) CreateOffer(options *OfferOptions) (SessionDescription
 			}
 		}
 
+		for _, t := range video {
+			t.Mid = "video"
+		}
+		for _, t := range audio {
+			t.Mid = "audio"
+		}
+
 		if len(video) > 0 {
-			if err = pc.addTransceiverSDP(d, "video", iceParams, candidates, sdp.ConnectionRoleActpass, video...); err != nil {
+			var accepted bool
+			if accepted, err = pc.addTransceiverSDP(d, "video", iceParams, candidates, sdp.ConnectionRoleActpass, voiceActivityDetection, nil, video...); err != nil {
 				return SessionDescription{}, err
 			}
-			appendBundle("video")
+			if accepted {
+				appendBundle("video")
+			}
 		}
 		if len(audio) > 0 {
-			if err = pc.addTransceiverSDP(d, "audio", iceParams, candidates, sdp.ConnectionRoleActpass, audio...); err != nil {
+			var accepted bool
+			if accepted, err = pc.addTransceiverSDP(d, "audio", iceParams, candidates, sdp.ConnectionRoleActpass, voiceActivityDetection, nil, audio...); err != nil {
 				return SessionDescription{}, err
 			}
-			appendBundle("audio")
+			if accepted {
+				appendBundle("audio")
+			}
 		}
+		pc.addDataMediaSection(d, "data", iceParams, candidates, sdp.ConnectionRoleActive)
+		appendBundle("data")
 	} else {
+		// Media sections that were negotiated before keep their mid and
+		// position, new transceivers are appended to the end
+		// https://tools.ietf.org/html/draft-ietf-rtcweb-jsep-26#section-5.2.2
+		usedMids := pc.usedMids()
+		offered := map[*RTPTransceiver]bool{}
+		haveData := false
+		if pc.currentLocalDescription != nil && pc.currentLocalDescription.parsed != nil {
+			for i, media := range pc.currentLocalDescription.parsed.MediaDescriptions {
+				midValue := pc.getMidValue(media)
+				if media.MediaName.Media == "application" {
+					pc.addDataMediaSection(d, midValue, iceParams, candidates, sdp.ConnectionRoleActive)
+					appendBundle(midValue)
+					haveData = true
+					continue
+				}
+
+				// A rejected section has to stay in the offer, it is
+				// offered with port 0 again and isn't bundled
+				// https://tools.ietf.org/html/draft-ietf-rtcweb-jsep-26#section-5.2.2
+				var t *RTPTransceiver
+				if media.MediaName.Port.Value != 0 {
+					t = pc.transceiverForMid(midValue)
+				}
+				if t == nil || pc.remoteMediaRejected(i) {
+					if t != nil {
+						offered[t] = true
+					}
+					addRejectedMediaSection(d, media.MediaName.Media, midValue)
+					continue
+				}
+
+				var accepted bool
+				if accepted, err = pc.addTransceiverSDP(d, midValue, iceParams, candidates, sdp.ConnectionRoleActpass, voiceActivityDetection, nil, t); err != nil {
+					return SessionDescription{}, err
+				}
+				offered[t] = true
+				if accepted {
+					appendBundle(midValue)
+				}
+			}
+		}
+
 		for _, t := range pc.GetTransceivers() {
-			midValue := strconv.Itoa(bundleCount)
-			if err = pc.addTransceiverSDP(d, midValue, iceParams, candidates, sdp.ConnectionRoleActpass, t); err != nil {
+			if offered[t] {
+				continue
+			}
+			if t.Mid == "" {
+				t.Mid = generateMid(usedMids)
+			}
+			var accepted bool
+			if accepted, err = pc.addTransceiverSDP(d, t.Mid, iceParams, candidates, sdp.ConnectionRoleActpass, voiceActivityDetection, nil, t); err != nil {
 				return SessionDescription{}, err
 			}
-			appendBundle(midValue)
+			if accepted {
+				appendBundle(t.Mid)
+			}
 		}
-	}
 
-	midValue := strconv.Itoa(bundleCount)
-	if pc.configuration.SDPSemantics == SDPSemanticsPlanB {
-		midValue = "data"
+		if !haveData {
+			midValue := generateMid(usedMids)
+			pc.addDataMediaSection(d, midValue, iceParams, candidates, sdp.ConnectionRoleActive)
+			appendBundle(midValue)
+		}
 	}
-	pc.addDataMediaSection(d, midValue, iceParams, candidates, sdp.ConnectionRoleActive)
-	appendBundle(midValue)
 
 	d = d.WithValueAttribute(sdp.AttrKeyGroup, bundleValue)
 
@@ -545,11 +911,41 @@ func (pc *PeerConnection) createICEGatherer() (*ICEGatherer, error) {
 		return nil, err
 	}
 
+	g.OnLocalCandidate(pc.onICECandidate)
+	g.OnStateChange(pc.onICEGathererStateChange)
+
 	return g, nil
 }
 
+// gather starts gathering ICE candidates. Without trickle ICE it blocks
+// until gathering is complete.
 func (pc *PeerConnection) gather() error {
-	return pc.iceGatherer.Gather()
+	pc.mu.RLock()
+	gatheringComplete := pc.iceGatheringComplete
+	pc.mu.RUnlock()
+
+	if err := pc.iceGatherer.Gather(); err != nil {
+		return err
+	}
+
+	if !pc.api.settingEngine.candidates.ICETrickle {
+		<-gatheringComplete
+	}
+	return nil
+}
+
+// restartICE replaces the local ICE credentials and candidates, the
+// connectivity checks are restarted once the remote credentials are known.
+// Without trickle ICE the new candidates are gathered right away.
+func (pc *PeerConnection) restartICE() error {
+	if err := pc.iceGatherer.restart(); err != nil {
+		return err
+	}
+
+	if pc.api.settingEngine.candidates.ICETrickle {
+		return nil
+	}
+	return pc.gather()
 }
 
 func (pc *PeerConnection) createICETransport() *ICETransport {
@@ -634,7 +1030,7 @@ func satisfyTypeAndDirection(remoteKind RTPCodecType, remoteDirection RTPTransce
 	}, localTransceivers
 }
 
-func (pc *PeerConnection) addAnswerMediaTransceivers(d *sdp.SessionDescription) (*sdp.SessionDescription, error) {
+func (pc *PeerConnection) addAnswerMediaTransceivers(d *sdp.SessionDescription, voiceActivityDetection bool) (*sdp.SessionDescription, error) {
 	iceParams, err := pc.iceGatherer.GetLocalParameters()
 	if err != nil {
 		return nil, err
@@ -650,6 +1046,14 @@ func (pc *PeerConnection) addAnswerMediaTransceivers(d *sdp.SessionDescription)
 		bundleValue += " " + midValue
 	}
 
+	// Once DTLS is established the roles can't change anymore, so subsequent
+	// answers have to reflect the role that was negotiated initially
+	// https://tools.ietf.org/html/rfc5763#section-5
+	connectionRole := sdp.ConnectionRoleActive
+	if pc.currentLocalDescription != nil && !pc.dtlsTransport.isClient() {
+		connectionRole = sdp.ConnectionRolePassive
+	}
+
 	var t *RTPTransceiver
 	localTransceivers := append([]*RTPTransceiver{}, pc.GetTransceivers()...)
 	detectedPlanB := pc.descriptionIsPlanB(pc.RemoteDescription())
@@ -661,18 +1065,34 @@ func (pc *PeerConnection) addAnswerMediaTransceivers(d *sdp.SessionDescription)
 		}
 
 		if media.MediaName.Media == "application" {
-			pc.addDataMediaSection(d, midValue, iceParams, candidates, sdp.ConnectionRoleActive)
+			pc.addDataMediaSection(d, midValue, iceParams, candidates, connectionRole)
 			appendBundle(midValue)
 			continue
 		}
 
+		// A section the offerer rejected is rejected in the answer too
+		// https://tools.ietf.org/html/draft-ietf-rtcweb-jsep-26#section-5.3.1
+		if media.MediaName.Port.Value == 0 {
+			addRejectedMediaSection(d, media.MediaName.Media, midValue)
+			continue
+		}
+
 		kind := NewRTPCodecType(media.MediaName.Media)
 		direction := pc.getPeerDirection(media)
 		if kind == 0 || direction == RTPTransceiverDirection(Unknown) {
 			continue
 		}
 
-		t, localTransceivers = satisfyTypeAndDirection(kind, direction, localTransceivers)
+		if detectedPlanB {
+			t, localTransceivers = satisfyTypeAndDirection(kind, direction, localTransceivers)
+		} else if t = pc.transceiverForMid(midValue); t == nil {
+			// The section could not be associated in SetRemoteDescription, reject it
+			t = &RTPTransceiver{
+				Mid:       newTransceiverMid,
+				kind:      kind,
+				Direction: RTPTransceiverDirectionInactive,
+			}
+		}
 		mediaTransceivers := []*RTPTransceiver{t}
 		switch pc.configuration.SDPSemantics {
 		case SDPSemanticsUnifiedPlanWithFallback:
@@ -701,10 +1121,13 @@ func (pc *PeerConnection) addAnswerMediaTransceivers(d *sdp.SessionDescription)
 				return nil, &rtcerr.TypeError{Err: ErrIncorrectSDPSemantics}
 			}
 		}
-		if err := pc.addTransceiverSDP(d, midValue, iceParams, candidates, sdp.ConnectionRoleActive, mediaTransceivers...); err != nil {
+		var accepted bool
+		if accepted, err = pc.addTransceiverSDP(d, midValue, iceParams, candidates, connectionRole, voiceActivityDetection, media, mediaTransceivers...); err != nil {
 			return nil, err
 		}
-		appendBundle(midValue)
+		if accepted {
+			appendBundle(midValue)
+		}
 	}
 
 	if pc.configuration.SDPSemantics == SDPSemanticsUnifiedPlanWithFallback && detectedPlanB {
@@ -718,13 +1141,11 @@ func (pc *PeerConnection) addAnswerMediaTransceivers(d *sdp.SessionDescription)
 func (pc *PeerConnection) CreateAnswer(options *AnswerOptions) (SessionDescription, error) {
 	useIdentity := pc.idpLoginURL != nil
 	switch {
-	case options != nil:
-		return SessionDescription{}, fmt.Errorf("TODO handle options")
 	case pc.RemoteDescription() == nil:
 		return SessionDescription{}, &rtcerr.InvalidStateError{Err: ErrNoRemoteDescription}
 	case useIdentity:
 		return SessionDescription{}, fmt.Errorf("TODO handle identity provider")
-	case pc.isClosed:
+	case pc.closed():
 		return SessionDescription{}, &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
 	}
 
@@ -733,7 +1154,12 @@ func (pc *PeerConnection) CreateAnswer(options *AnswerOptions) (SessionDescripti
 		return SessionDescription{}, err
 	}
 
-	d, err := pc.addAnswerMediaTransceivers(d)
+	voiceActivityDetection := true
+	if options != nil && options.VoiceActivityDetection != nil {
+		voiceActivityDetection = *options.VoiceActivityDetection
+	}
+
+	d, err := pc.addAnswerMediaTransceivers(d, voiceActivityDetection)
 	if err != nil {
 		return SessionDescription{}, err
 	}
@@ -754,7 +1180,7 @@ func (pc *PeerConnection) CreateAnswer(options *AnswerOptions) (SessionDescripti
 
 // 4.4.1.6 Set the SessionDescription
 func (pc *PeerConnection) setDescription(sd *SessionDescription, op stateChangeOp) error {
-	if pc.isClosed {
+	if pc.closed() {
 		return &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
 	}
 
@@ -847,13 +1273,22 @@ func (pc *PeerConnection) setDescription(sd *SessionDescription, op stateChangeO
 	if err == nil {
 		pc.signalingState = nextState
 		pc.onSignalingStateChange(nextState)
+
+		// Changes that happened during the negotiation are picked up once
+		// the signaling state returned to stable
+		if nextState == SignalingStateStable {
+			pc.mu.Lock()
+			pc.negotiationNeeded = false
+			pc.mu.Unlock()
+			pc.updateNegotiationNeededFlag()
+		}
 	}
 	return err
 }
 
 // SetLocalDescription sets the SessionDescription of the local peer
 func (pc *PeerConnection) SetLocalDescription(desc SessionDescription) error {
-	if pc.isClosed {
+	if pc.closed() {
 		return &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
 	}
 
@@ -871,7 +1306,7 @@ func (pc *PeerConnection) SetLocalDescription(desc SessionDescription) error {
 		}
 	}
 
-	// TODO: Initiate ICE candidate gathering?
+	haveRemoteDescription := pc.currentRemoteDescription != nil
 
 	desc.parsed = &sdp.SessionDescription{}
 	if err := desc.parsed.Unmarshal([]byte(desc.SDP)); err != nil {
@@ -881,6 +1316,26 @@ func (pc *PeerConnection) SetLocalDescription(desc SessionDescription) error {
 		return err
 	}
 
+	// The restart requested by RestartICE is done once the credentials it
+	// replaces are no longer used
+	pc.mu.Lock()
+	if pc.iceRestartNeeded && descriptionICEUfrag(desc.parsed) != pc.iceRestartUfrag {
+		pc.iceRestartNeeded = false
+	}
+	pc.mu.Unlock()
+
+	// Applying a local answer completes a renegotiation started by the remote
+	if haveRemoteDescription && desc.Type == SDPTypeAnswer {
+		pc.startRenegotiation()
+	}
+
+	// https://w3c.github.io/webrtc-pc/#set-the-rtcsessiondescription (step #4.1.7)
+	if pc.iceGatherer.State() == ICEGathererStateNew {
+		if err := pc.gather(); err != nil {
+			return err
+		}
+	}
+
 	// Call the appropriate event handlers to signal that ICE candidate gathering
 	// is complete. In reality it completed a while ago, but triggering these
 	// events helps maintain API compatibility with the JavaScript/Wasm bindings.
@@ -897,21 +1352,87 @@ func (pc *PeerConnection) SetLocalDescription(desc SessionDescription) error {
 // https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-localdescription
 func (pc *PeerConnection) LocalDescription() *SessionDescription {
 	if pc.pendingLocalDescription != nil {
-		return pc.pendingLocalDescription
+		return pc.populateLocalCandidates(pc.pendingLocalDescription)
+	}
+	return pc.populateLocalCandidates(pc.currentLocalDescription)
+}
+
+// populateLocalCandidates returns desc with the local candidates that have
+// been gathered so far. With trickle ICE they are gathered after the
+// description was created, so they are missing from the stored one
+func (pc *PeerConnection) populateLocalCandidates(desc *SessionDescription) *SessionDescription {
+	if desc == nil || desc.parsed == nil || !pc.api.settingEngine.candidates.ICETrickle {
+		return desc
+	}
+
+	candidates, err := pc.iceGatherer.GetLocalCandidates()
+	if err != nil || len(candidates) == 0 {
+		return desc
+	}
+	complete := pc.ICEGatheringState() == ICEGatheringStateComplete
+
+	parsed := *desc.parsed
+	parsed.MediaDescriptions = make([]*sdp.MediaDescription, 0, len(desc.parsed.MediaDescriptions))
+	for _, media := range desc.parsed.MediaDescriptions {
+		populated := *media
+		populated.Attributes = make([]sdp.Attribute, 0, len(media.Attributes))
+		for _, attr := range media.Attributes {
+			if attr.Key != "candidate" && attr.Key != "end-of-candidates" {
+				populated.Attributes = append(populated.Attributes, attr)
+			}
+		}
+
+		// Rejected sections have no transport
+		if populated.MediaName.Port.Value != 0 {
+			addICECandidates(&populated, candidates, complete)
+		}
+		parsed.MediaDescriptions = append(parsed.MediaDescriptions, &populated)
+	}
+
+	sdpBytes, err := parsed.Marshal()
+	if err != nil {
+		pc.log.Warnf("Failed to add local candidates to description: %s", err)
+		return desc
+	}
+	return &SessionDescription{
+		Type:   desc.Type,
+		SDP:    string(sdpBytes),
+		parsed: &parsed,
+	}
+}
+
+// localCandidateMid returns the MID and index of the media section the local
+// candidates are gathered for. Every section is bundled, so this is the first
+// one that isn't rejected
+func (pc *PeerConnection) localCandidateMid() (string, uint16) {
+	desc := pc.pendingLocalDescription
+	if desc == nil {
+		desc = pc.currentLocalDescription
+	}
+	if desc == nil || desc.parsed == nil {
+		return "", 0
+	}
+
+	for i, media := range desc.parsed.MediaDescriptions {
+		if media.MediaName.Port.Value == 0 {
+			continue
+		}
+		mid, _ := media.Attribute(sdp.AttrKeyMID)
+		return mid, uint16(i)
 	}
-	return pc.currentLocalDescription
+	return "", 0
 }
 
 // SetRemoteDescription sets the SessionDescription of the remote peer
 func (pc *PeerConnection) SetRemoteDescription(desc SessionDescription) error {
-	// FIXME: Remove this when renegotiation is supported
-	if pc.currentRemoteDescription != nil {
-		return fmt.Errorf("remoteDescription is already defined, SetRemoteDescription can only be called once")
-	}
-	if pc.isClosed {
+	if pc.closed() {
 		return &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
 	}
 
+	// Transports are only started for the first remote description, every
+	// following description is a renegotiation on top of the running transports
+	haveRemoteDescription := pc.currentRemoteDescription != nil
+
 	desc.parsed = &sdp.SessionDescription{}
 	if err := desc.parsed.Unmarshal([]byte(desc.SDP)); err != nil {
 		return err
@@ -927,6 +1448,7 @@ func (pc *PeerConnection) SetRemoteDescription(desc SessionDescription) error {
 		weOffer = false
 	}
 
+	var candidates []ICECandidate
 	for _, m := range pc.RemoteDescription().parsed.MediaDescriptions {
 		for _, a := range m.Attributes {
 			switch {
@@ -940,10 +1462,7 @@ func (pc *PeerConnection) SetRemoteDescription(desc SessionDescription) error {
 				if err != nil {
 					return err
 				}
-
-				if err = pc.iceTransport.AddRemoteCandidate(candidate); err != nil {
-					return err
-				}
+				candidates = append(candidates, candidate)
 			case strings.HasPrefix(*a.String(), "ice-ufrag"):
 				remoteUfrag = (*a.String())[len("ice-ufrag:"):]
 			case strings.HasPrefix(*a.String(), "ice-pwd"):
@@ -952,9 +1471,59 @@ func (pc *PeerConnection) SetRemoteDescription(desc SessionDescription) error {
 		}
 	}
 
-	fingerprint, ok := desc.parsed.Attribute("fingerprint")
-	if !ok {
-		fingerprint, ok = desc.parsed.MediaDescriptions[0].Attribute("fingerprint")
+	// Changed credentials restart ICE, an offer restarting ICE has to be
+	// answered with new local credentials too
+	// https://tools.ietf.org/html/rfc8445#section-9
+	iceRestart := haveRemoteDescription &&
+		(remoteUfrag != pc.remoteICEParameters.UsernameFragment || remotePwd != pc.remoteICEParameters.Password)
+	if iceRestart && desc.Type == SDPTypeOffer && !pc.iceTransport.hasPendingRestart() {
+		if err := pc.restartICE(); err != nil {
+			return err
+		}
+	}
+
+	// Candidates belong to the agent of the current credentials
+	for _, candidate := range candidates {
+		if err := pc.iceTransport.AddRemoteCandidate(candidate); err != nil {
+			return err
+		}
+	}
+
+	if desc.Type == SDPTypeOffer {
+		if err := pc.associateRemoteMediaSections(); err != nil {
+			return err
+		}
+	}
+
+	if haveRemoteDescription {
+		// A restart we offered is completed by the answer
+		if iceRestart || (desc.Type == SDPTypeAnswer && pc.iceTransport.hasPendingRestart()) {
+			pc.remoteICEParameters = ICEParameters{
+				UsernameFragment: remoteUfrag,
+				Password:         remotePwd,
+				ICELite:          false,
+			}
+			if err := pc.iceTransport.restart(pc.remoteICEParameters); err != nil {
+				return err
+			}
+		}
+
+		// An answer completes the renegotiation, apply the media changes now.
+		// For an offer this happens once the local answer is set.
+		if pc.signalingState == SignalingStateStable {
+			pc.startRenegotiation()
+		}
+		return nil
+	}
+	pc.remoteICEParameters = ICEParameters{
+		UsernameFragment: remoteUfrag,
+		Password:         remotePwd,
+		ICELite:          false,
+	}
+
+	fingerprint, ok := desc.parsed.Attribute("fingerprint")
+	if !ok {
+		fingerprint, ok = desc.parsed.MediaDescriptions[0].Attribute("fingerprint")
 		if !ok {
 			return fmt.Errorf("could not find fingerprint")
 		}
@@ -973,9 +1542,13 @@ func (pc *PeerConnection) SetRemoteDescription(desc SessionDescription) error {
 
 	// Wire up the on datachannel handler
 	sctp.OnDataChannel(func(d *DataChannel) {
-		pc.mu.RLock()
+		pc.mu.Lock()
+		if id := d.ID(); id != nil {
+			pc.dataChannels[*id] = d
+		}
+		pc.dataChannelsAccepted++
 		hdlr := pc.onDataChannelHandler
-		pc.mu.RUnlock()
+		pc.mu.Unlock()
 		if hdlr != nil {
 			hdlr(d)
 		}
@@ -992,11 +1565,7 @@ func (pc *PeerConnection) SetRemoteDescription(desc SessionDescription) error {
 		}
 		err := pc.iceTransport.Start(
 			pc.iceGatherer,
-			ICEParameters{
-				UsernameFragment: remoteUfrag,
-				Password:         remotePwd,
-				ICELite:          false,
-			},
+			pc.remoteICEParameters,
 			&iceRole,
 		)
 
@@ -1017,23 +1586,13 @@ func (pc *PeerConnection) SetRemoteDescription(desc SessionDescription) error {
 			return
 		}
 
+		pc.mediaLock.Lock()
 		pc.openSRTP()
+		pc.startRTPSenders()
+		pc.mediaStarted = true
+		pc.mediaLock.Unlock()
 
-		for _, tranceiver := range pc.GetTransceivers() {
-			if tranceiver.Sender != nil {
-				err = tranceiver.Sender.Send(RTPSendParameters{
-					Encodings: RTPEncodingParameters{
-						RTPCodingParameters{
-							SSRC:        tranceiver.Sender.track.SSRC(),
-							PayloadType: tranceiver.Sender.track.PayloadType(),
-						},
-					}})
-
-				if err != nil {
-					pc.log.Warnf("Failed to start Sender: %s", err)
-				}
-			}
-		}
+		pc.updateConnectionState(pc.ICEConnectionState(), pc.dtlsTransport.State())
 
 		go pc.drainSRTP()
 
@@ -1054,6 +1613,207 @@ func (pc *PeerConnection) SetRemoteDescription(desc SessionDescription) error {
 	return nil
 }
 
+// startRenegotiation applies the media changes of a subsequent offer/answer
+// exchange to the running transports. Receivers are started for newly
+// announced media and stopped for removed media, senders that were added
+// since the last negotiation are started.
+func (pc *PeerConnection) startRenegotiation() {
+	pc.mediaLock.Lock()
+	defer pc.mediaLock.Unlock()
+
+	// Media is only started once DTLS is up, if the initial negotiation is
+	// still in progress it will pick up the changes by itself
+	if !pc.mediaStarted {
+		return
+	}
+
+	pc.openSRTP()
+	pc.startRTPSenders()
+}
+
+// startRTPSenders starts all senders which have not been started yet
+func (pc *PeerConnection) startRTPSenders() {
+	for _, transceiver := range pc.GetTransceivers() {
+		if transceiver.Sender == nil || transceiver.Sender.hasSent() || transceiver.Sender.hasStopped() || !transceiver.Direction.hasSend() {
+			continue
+		}
+
+		track := transceiver.Sender.Track()
+		if track == nil {
+			continue
+		}
+
+		sender := transceiver.Sender
+		sender.mu.Lock()
+		sender.mid = transceiver.Mid
+		encodings := append([]*trackEncoding{}, sender.encodings...)
+		sender.mu.Unlock()
+
+		// Only the first encoding is sent unless the remote accepted the
+		// layers of simulcast
+		rids := map[string]bool{}
+		if len(encodings) > 1 {
+			for _, rid := range pc.negotiatedSimulcastRIDs(transceiver.Mid) {
+				rids[rid] = true
+			}
+		}
+
+		parameters := RTPSendParameters{
+			Codecs:           pc.negotiatedCodecParameters(transceiver.Mid),
+			HeaderExtensions: pc.negotiatedHeaderExtensions(transceiver.Mid),
+		}
+		for i, e := range encodings {
+			if (len(rids) == 0 && i != 0) || (len(rids) != 0 && !rids[e.track.RID()]) {
+				continue
+			}
+
+			// The packets are sent with the payload type the remote peer
+			// negotiated for the codec of the track
+			payloadType := e.track.PayloadType()
+			if codec := e.track.Codec(); codec != nil {
+				if negotiated, ok := pc.negotiatedTrackPayloadType(transceiver.Mid, codec); ok {
+					payloadType = negotiated
+				}
+			}
+
+			encoding := RTPEncodingParameters{
+				RTPCodingParameters{
+					SSRC:        e.track.SSRC(),
+					PayloadType: payloadType,
+				},
+			}
+			if len(rids) != 0 {
+				encoding.RID = e.track.RID()
+			}
+			if sender.retransmissionEnabled() {
+				if rtxPayloadType, ok := pc.negotiatedRTXPayloadType(transceiver.Mid, payloadType); ok {
+					encoding.RTX = RTPRtxParameters{
+						SSRC:        e.rtxSSRC,
+						PayloadType: rtxPayloadType,
+					}
+				}
+			}
+
+			// The FlexFEC streams of the layers of simulcast aren't signaled
+			fecSSRC := e.fecSSRC
+			if len(rids) != 0 {
+				fecSSRC = 0
+			}
+			encoding.FEC = pc.sendFECParameters(transceiver.Mid, sender, fecSSRC)
+			parameters.Encodings = append(parameters.Encodings, encoding)
+		}
+
+		err := transceiver.Sender.Send(parameters)
+		if err != nil {
+			pc.log.Warnf("Failed to start Sender: %s", err)
+		}
+	}
+}
+
+// associateRemoteMediaSections binds the media sections of a Unified Plan
+// remote offer to local transceivers by their mid. Sections that were seen
+// in a previous negotiation keep their transceiver, new sections are matched
+// against the unassociated transceivers.
+// https://tools.ietf.org/html/draft-ietf-rtcweb-jsep-26#section-5.10
+func (pc *PeerConnection) associateRemoteMediaSections() error {
+	if pc.descriptionIsPlanB(pc.RemoteDescription()) {
+		return nil
+	}
+
+	remoteMids := map[string]bool{}
+	for _, media := range pc.RemoteDescription().parsed.MediaDescriptions {
+		remoteMids[pc.getMidValue(media)] = true
+	}
+
+	// Transceivers that were negotiated before stay with their mid
+	localMids := map[string]bool{}
+	if pc.currentLocalDescription != nil && pc.currentLocalDescription.parsed != nil {
+		for _, media := range pc.currentLocalDescription.parsed.MediaDescriptions {
+			localMids[pc.getMidValue(media)] = true
+		}
+	}
+
+	unassociated := []*RTPTransceiver{}
+	for _, t := range pc.GetTransceivers() {
+		if !remoteMids[t.Mid] && !localMids[t.Mid] {
+			unassociated = append(unassociated, t)
+		}
+	}
+
+	for _, media := range pc.RemoteDescription().parsed.MediaDescriptions {
+		midValue := pc.getMidValue(media)
+		if midValue == "" {
+			return fmt.Errorf("RemoteDescription contained media section without mid value")
+		}
+
+		kind := NewRTPCodecType(media.MediaName.Media)
+		direction := pc.getPeerDirection(media)
+		if kind == 0 || direction == RTPTransceiverDirection(Unknown) || media.MediaName.Port.Value == 0 || pc.transceiverForMid(midValue) != nil {
+			continue
+		}
+
+		var t *RTPTransceiver
+		t, unassociated = satisfyTypeAndDirection(kind, direction, unassociated)
+		if t.Mid != newTransceiverMid {
+			t.Mid = midValue
+		}
+	}
+
+	return nil
+}
+
+// usedMids returns the mids of the local transceivers and of the current
+// descriptions, a new mid must not collide with any of them
+func (pc *PeerConnection) usedMids() map[string]bool {
+	mids := map[string]bool{}
+	for _, t := range pc.GetTransceivers() {
+		if t.Mid != "" {
+			mids[t.Mid] = true
+		}
+	}
+	for _, desc := range []*SessionDescription{pc.currentLocalDescription, pc.currentRemoteDescription} {
+		if desc == nil || desc.parsed == nil {
+			continue
+		}
+		for _, media := range desc.parsed.MediaDescriptions {
+			mids[pc.getMidValue(media)] = true
+		}
+	}
+	return mids
+}
+
+// generateMid returns the lowest numeric mid that isn't in use yet and
+// marks it as used
+func generateMid(usedMids map[string]bool) string {
+	for i := 0; ; i++ {
+		if mid := strconv.Itoa(i); !usedMids[mid] {
+			usedMids[mid] = true
+			return mid
+		}
+	}
+}
+
+// transceiverForMid returns the transceiver associated with the given mid
+func (pc *PeerConnection) transceiverForMid(mid string) *RTPTransceiver {
+	for _, t := range pc.GetTransceivers() {
+		if t.Mid == mid {
+			return t
+		}
+	}
+	return nil
+}
+
+// remoteMediaRejected tells if the media section at index of the current
+// remote description has port 0
+func (pc *PeerConnection) remoteMediaRejected(index int) bool {
+	if pc.currentRemoteDescription == nil || pc.currentRemoteDescription.parsed == nil {
+		return false
+	}
+
+	medias := pc.currentRemoteDescription.parsed.MediaDescriptions
+	return index < len(medias) && medias[index].MediaName.Port.Value == 0
+}
+
 // openDataChannels opens the existing data channels
 func (pc *PeerConnection) openDataChannels() {
 	pc.mu.Lock()
@@ -1087,9 +1847,34 @@ func (pc *PeerConnection) descriptionIsPlanB(desc *SessionDescription) bool {
 	return false
 }
 
+// rtcpFeedbackNegotiated tells if both the local and the remote description
+// signal feedback for payloadType in the media section with mid
+func (pc *PeerConnection) rtcpFeedbackNegotiated(mid string, payloadType uint8, feedback RTCPFeedback) bool {
+	for _, desc := range []*SessionDescription{pc.currentLocalDescription, pc.currentRemoteDescription} {
+		if desc == nil || desc.parsed == nil {
+			return false
+		}
+
+		signaled := false
+		for _, media := range desc.parsed.MediaDescriptions {
+			if pc.getMidValue(media) == mid && mediaHasRTCPFeedback(media, payloadType, feedback) {
+				signaled = true
+				break
+			}
+		}
+		if !signaled {
+			return false
+		}
+	}
+	return true
+}
+
 // openSRTP opens knows inbound SRTP streams from the RemoteDescription
 func (pc *PeerConnection) openSRTP() {
 	incomingSSRCes := map[uint32]RTPCodecType{}
+	incomingMids := map[uint32]string{}
+	incomingRTXSSRCes := map[uint32]uint32{}
+	incomingFECSSRCes := map[uint32]uint32{}
 
 	remoteIsPlanB := false
 	switch pc.configuration.SDPSemantics {
@@ -1100,78 +1885,219 @@ func (pc *PeerConnection) openSRTP() {
 	}
 
 	for _, media := range pc.RemoteDescription().parsed.MediaDescriptions {
+		codecType := NewRTPCodecType(media.MediaName.Media)
+		if codecType == 0 {
+			continue
+		}
+
+		// The RTX and FlexFEC streams of an SSRC are received with it, the
+		// groups can follow the SSRCs they group
+		// https://tools.ietf.org/html/rfc4588#section-8.7
+		// https://tools.ietf.org/html/draft-ietf-payload-flexible-fec-scheme-03#section-5.1.2
+		groupedSSRCes := map[uint32]bool{}
 		for _, attr := range media.Attributes {
+			if attr.Key != sdp.AttrKeySSRCGroup {
+				continue
+			}
 
-			codecType := NewRTPCodecType(media.MediaName.Media)
-			if codecType == 0 {
+			group := strings.Fields(attr.Value)
+			if len(group) != 3 || (group[0] != "FID" && group[0] != "FEC-FR") {
 				continue
 			}
 
-			if attr.Key == sdp.AttrKeySSRC {
-				ssrc, err := strconv.ParseUint(strings.Split(attr.Value, " ")[0], 10, 32)
-				if err != nil {
-					pc.log.Warnf("Failed to parse SSRC: %v", err)
-					continue
-				}
+			ssrc, err := strconv.ParseUint(group[1], 10, 32)
+			if err != nil {
+				pc.log.Warnf("Failed to parse SSRC: %v", err)
+				continue
+			}
+			groupSSRC, err := strconv.ParseUint(group[2], 10, 32)
+			if err != nil {
+				pc.log.Warnf("Failed to parse SSRC: %v", err)
+				continue
+			}
+			if group[0] == "FID" {
+				incomingRTXSSRCes[uint32(ssrc)] = uint32(groupSSRC)
+			} else {
+				incomingFECSSRCes[uint32(ssrc)] = uint32(groupSSRC)
+			}
+			groupedSSRCes[uint32(groupSSRC)] = true
+		}
 
-				incomingSSRCes[uint32(ssrc)] = codecType
-				break
+		for _, attr := range media.Attributes {
+			if attr.Key != sdp.AttrKeySSRC {
+				continue
+			}
+
+			ssrc, err := strconv.ParseUint(strings.Split(attr.Value, " ")[0], 10, 32)
+			if err != nil {
+				pc.log.Warnf("Failed to parse SSRC: %v", err)
+				continue
+			}
+
+			// The RTX and FlexFEC streams aren't tracks of their own
+			if groupedSSRCes[uint32(ssrc)] {
+				continue
+			}
+
+			incomingSSRCes[uint32(ssrc)] = codecType
+			incomingMids[uint32(ssrc)] = pc.getMidValue(media)
+			break
+		}
+	}
+
+	// Media sections that negotiated simulcast streams without SSRCs are
+	// received by the RIDs of the streams
+	// https://tools.ietf.org/html/rfc8853#section-5.2
+	incomingRIDs := map[string][]string{}
+	incomingRIDKinds := map[string]RTPCodecType{}
+	if !remoteIsPlanB {
+		pc.mu.RLock()
+		for _, media := range pc.RemoteDescription().parsed.MediaDescriptions {
+			mid := pc.getMidValue(media)
+			local, _ := pc.negotiatedMedia(mid)
+			if local == nil || mediaHasSSRC(media) {
+				continue
+			}
+			if rids := getMediaSimulcastRIDs(local, ridDirectionRecv); len(rids) != 0 {
+				incomingRIDs[mid] = rids
+				incomingRIDKinds[mid] = NewRTPCodecType(media.MediaName.Media)
 			}
 		}
+		pc.mu.RUnlock()
 	}
 
 	startReceiver := func(ssrc uint32, receiver *RTPReceiver) {
+		pc.mu.RLock()
+		fec := pc.receiveFECParameters(incomingMids[ssrc], incomingFECSSRCes[ssrc])
+		codecs := pc.negotiatedCodecParameters(incomingMids[ssrc])
+		headerExtensions := pc.negotiatedHeaderExtensions(incomingMids[ssrc])
+		pc.mu.RUnlock()
+
 		err := receiver.Receive(RTPReceiveParameters{
-			Encodings: RTPDecodingParameters{
-				RTPCodingParameters{SSRC: ssrc},
-			}})
+			Encodings: []RTPDecodingParameters{
+				{
+					RTPCodingParameters{
+						SSRC: ssrc,
+						RTX:  RTPRtxParameters{SSRC: incomingRTXSSRCes[ssrc]},
+						FEC:  fec,
+					},
+				},
+			},
+			Codecs:           codecs,
+			HeaderExtensions: headerExtensions,
+		})
 		if err != nil {
 			pc.log.Warnf("RTPReceiver Receive failed %s", err)
 			return
 		}
 
-		if err = receiver.Track().determinePayloadType(); err != nil {
-			pc.log.Warnf("Could not determine PayloadType for SSRC %d", receiver.Track().SSRC())
-			return
-		}
+		pc.startTrack(incomingMids[ssrc], receiver.Track(), receiver)
+	}
 
+	// The tracks of the simulcast streams start once drainSRTP found the
+	// SSRC of their RID, so their RIDs are registered before openSRTP
+	// returns and drainSRTP can read their first packets
+	startRIDReceiver := func(mid string, rids []string, receiver *RTPReceiver) {
 		pc.mu.RLock()
-		defer pc.mu.RUnlock()
+		fec := pc.receiveFECParameters(mid, 0)
+		codecs := pc.negotiatedCodecParameters(mid)
+		headerExtensions := pc.negotiatedHeaderExtensions(mid)
+		pc.mu.RUnlock()
 
-		sdpCodec, err := pc.currentLocalDescription.parsed.GetCodecForPayloadType(receiver.Track().PayloadType())
-		if err != nil {
-			pc.log.Warnf("no codec could be found in RemoteDescription for payloadType %d", receiver.Track().PayloadType())
-			return
+		encodings := []RTPDecodingParameters{}
+		for _, rid := range rids {
+			encodings = append(encodings, RTPDecodingParameters{
+				RTPCodingParameters{RID: rid, FEC: fec},
+			})
 		}
 
-		codec, err := pc.api.mediaEngine.getCodecSDP(sdpCodec)
+		err := receiver.Receive(RTPReceiveParameters{
+			Encodings:        encodings,
+			Codecs:           codecs,
+			HeaderExtensions: headerExtensions,
+		})
 		if err != nil {
-			pc.log.Warnf("codec %s in not registered", sdpCodec)
-			return
+			pc.log.Warnf("RTPReceiver Receive failed %s", err)
 		}
+	}
 
-		receiver.Track().mu.Lock()
-		receiver.Track().kind = codec.Type
-		receiver.Track().codec = codec
-		receiver.Track().mu.Unlock()
+	// Streams that are already being received are left untouched, receivers
+	// for streams the remote stopped announcing are stopped
+	for _, t := range pc.GetTransceivers() {
+		if t.Receiver == nil || !t.Receiver.hasReceived() || t.Receiver.hasStopped() {
+			continue
+		}
 
-		if pc.onTrackHandler != nil {
-			pc.onTrack(receiver.Track(), receiver)
-		} else {
-			pc.log.Warnf("OnTrack unset, unable to handle incoming media streams")
+		ssrc := t.Receiver.Track().SSRC()
+		if t.Receiver.Track().RID() != "" {
+			if _, ok := incomingRIDs[t.Mid]; ok {
+				delete(incomingRIDs, t.Mid)
+				continue
+			}
+		} else if _, ok := incomingSSRCes[ssrc]; ok {
+			delete(incomingSSRCes, ssrc)
+			continue
+		}
+
+		if err := t.Receiver.Stop(); err != nil {
+			pc.log.Warnf("Failed to stop RTPReceiver for SSRC %d: %s", ssrc, err)
+		}
+
+		// Receive can only be called once, so the transceiver gets a new
+		// receiver in case media is announced again in a later negotiation
+		receiver, err := pc.api.NewRTPReceiver(t.kind, pc.dtlsTransport)
+		if err != nil {
+			pc.log.Warnf("Failed to replace RTPReceiver for SSRC %d: %s", ssrc, err)
+			continue
+		}
+		t.Receiver = receiver
+	}
+
+	canReceive := func(t *RTPTransceiver, kind RTPCodecType) bool {
+		switch {
+		case t == nil || t.Receiver == nil || t.Receiver.hasReceived():
+			return false
+		case kind != t.kind:
+			return false
+		case t.Direction != RTPTransceiverDirectionRecvonly && t.Direction != RTPTransceiverDirectionSendrecv:
+			return false
 		}
+		return true
 	}
 
 	localTransceivers := append([]*RTPTransceiver{}, pc.GetTransceivers()...)
+	for mid, rids := range incomingRIDs {
+		t := pc.transceiverForMid(mid)
+		if !canReceive(t, incomingRIDKinds[mid]) {
+			continue
+		}
+		for i := range localTransceivers {
+			if localTransceivers[i] == t {
+				localTransceivers = append(localTransceivers[:i], localTransceivers[i+1:]...)
+				break
+			}
+		}
+		startRIDReceiver(mid, rids, t.Receiver)
+	}
+
 	for ssrc := range incomingSSRCes {
+		// Prefer the transceiver that was associated with the media section
+		if t := pc.transceiverForMid(incomingMids[ssrc]); !remoteIsPlanB && canReceive(t, incomingSSRCes[ssrc]) {
+			for i := range localTransceivers {
+				if localTransceivers[i] == t {
+					localTransceivers = append(localTransceivers[:i], localTransceivers[i+1:]...)
+					break
+				}
+			}
+
+			delete(incomingSSRCes, ssrc)
+			go startReceiver(ssrc, t.Receiver)
+			continue
+		}
+
 		for i := range localTransceivers {
 			t := localTransceivers[i]
-			switch {
-			case incomingSSRCes[ssrc] != t.kind:
-				continue
-			case t.Direction != RTPTransceiverDirectionRecvonly && t.Direction != RTPTransceiverDirectionSendrecv:
-				continue
-			case t.Receiver == nil:
+			if !canReceive(t, incomingSSRCes[ssrc]) {
 				continue
 			}
 
@@ -1184,7 +2110,7 @@ func (pc *PeerConnection) openSRTP() {
 
 	if remoteIsPlanB {
 		for ssrc, kind := range incomingSSRCes {
-			t, err := pc.AddTransceiver(kind, RtpTransceiverInit{
+			t, err := pc.newTransceiver(kind, RtpTransceiverInit{
 				Direction: RTPTransceiverDirectionSendrecv,
 			})
 			if err != nil {
@@ -1196,33 +2122,145 @@ func (pc *PeerConnection) openSRTP() {
 	}
 }
 
-// drainSRTP pulls and discards RTP/RTCP packets that don't match any SRTP
-// These could be sent to the user, but right now we don't provide an API
-// to distribute orphaned RTCP messages. This is needed to make sure we don't block
-// and provides useful debugging messages
-func (pc *PeerConnection) drainSRTP() {
-	go func() {
-		for {
-			srtpSession, err := pc.dtlsTransport.getSRTPSession()
-			if err != nil {
-				pc.log.Warnf("drainSRTP failed to open SrtpSession: %v", err)
-				return
-			}
+// startTrack determines the codec of a track received in the media section
+// with mid from its first packet and hands it to the application
+func (pc *PeerConnection) startTrack(mid string, track *Track, receiver *RTPReceiver) {
+	if err := track.determinePayloadType(); err != nil {
+		pc.log.Warnf("Could not determine PayloadType for SSRC %d", track.SSRC())
+		return
+	}
 
-			r, ssrc, err := srtpSession.AcceptStream()
-			if err != nil {
-				pc.log.Warnf("Failed to accept RTP %v \n", err)
-				return
-			}
+	// The lock is only held while reading the negotiated state, the
+	// receiver and onTrack take locks of their own
+	pc.mu.RLock()
+	sdpCodec, err := pc.currentLocalDescription.parsed.GetCodecForPayloadType(track.PayloadType())
+	if err != nil {
+		pc.mu.RUnlock()
+		pc.log.Warnf("no codec could be found in RemoteDescription for payloadType %d", track.PayloadType())
+		return
+	}
 
-			go func() {
-				rtpBuf := make([]byte, receiveMTU)
+	codec, err := pc.api.mediaEngine.getCodecSDP(sdpCodec)
+	if err != nil {
+		pc.mu.RUnlock()
+		pc.log.Warnf("codec %s in not registered", sdpCodec)
+		return
+	}
+
+	nackNegotiated := pc.rtcpFeedbackNegotiated(mid, codec.PayloadType, RTCPFeedback{Type: TypeRTCPFBNACK})
+
+	// The remote peer estimates the bandwidth itself from transport-cc
+	// feedback, REMB is only sent to peers that don't support it
+	rembNegotiated := !pc.rtcpFeedbackNegotiated(mid, codec.PayloadType, RTCPFeedback{Type: TypeRTCPFBTransportCC}) &&
+		pc.rtcpFeedbackNegotiated(mid, codec.PayloadType, RTCPFeedback{Type: TypeRTCPFBGoogREMB})
+
+	hasOnTrackHandler := pc.onTrackHandler != nil
+	pc.mu.RUnlock()
+
+	track.mu.Lock()
+	track.kind = codec.Type
+	track.codec = codec
+	track.mu.Unlock()
+
+	if nackNegotiated {
+		receiver.enableNACK(track)
+	}
+
+	if rembNegotiated {
+		receiver.enableREMB()
+	}
+
+	if hasOnTrackHandler {
+		pc.onTrack(track, receiver)
+	} else {
+		pc.log.Warnf("OnTrack unset, unable to handle incoming media streams")
+	}
+}
+
+// receiveUndeclaredSSRC receives a stream that wasn't signaled with the
+// simulcast stream that has the RID in its packet b, the mid in the packet
+// selects the transceiver. It is true when the stream is received, the
+// packet is read from it again.
+// https://tools.ietf.org/html/rfc8852#section-4
+func (pc *PeerConnection) receiveUndeclaredSSRC(ssrc uint32, b []byte, header *rtp.Header) bool {
+	for _, t := range pc.GetTransceivers() {
+		receiver := t.Receiver
+		if receiver == nil {
+			continue
+		}
+		if mid, ok := receiver.GetHeaderExtension(header, SDESMidURI); ok && string(mid) != t.Mid {
+			continue
+		}
+
+		if rid, ok := receiver.GetHeaderExtension(header, SDESRTPStreamIDURI); ok {
+			track, received, err := receiver.receiveForRID(string(rid), ssrc, append([]byte{}, b...))
+			if err != nil {
+				pc.log.Warnf("Failed to receive SSRC %d for RID %s: %s", ssrc, rid, err)
+				return false
+			}
+			if received {
+				go pc.startTrack(t.Mid, track, receiver)
+				return true
+			}
+		}
+
+		if rid, ok := receiver.GetHeaderExtension(header, RepairedRTPStreamIDURI); ok {
+			received, err := receiver.receiveRTXForRID(string(rid), ssrc, append([]byte{}, b...))
+			if err != nil {
+				pc.log.Warnf("Failed to receive SSRC %d for RID %s: %s", ssrc, rid, err)
+				return false
+			}
+			if received {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// receivesSSRC tells if a receiver reads the stream with ssrc
+func (pc *PeerConnection) receivesSSRC(ssrc uint32) bool {
+	for _, t := range pc.GetTransceivers() {
+		if t.Receiver != nil && t.Receiver.receivesSSRC(ssrc) {
+			return true
+		}
+	}
+	return false
+}
+
+// drainSRTP pulls and discards RTP/RTCP packets that don't match any SRTP
+// These could be sent to the user, but right now we don't provide an API
+// to distribute orphaned RTCP messages. This is needed to make sure we don't block
+// and provides useful debugging messages
+func (pc *PeerConnection) drainSRTP() {
+	go func() {
+		for {
+			srtpSession, err := pc.dtlsTransport.getSRTPSession()
+			if err != nil {
+				pc.log.Warnf("drainSRTP failed to open SrtpSession: %v", err)
+				return
+			}
+
+			r, ssrc, err := srtpSession.AcceptStream()
+			if err != nil {
+				pc.log.Warnf("Failed to accept RTP %v \n", err)
+				return
+			}
+
+			// Streams that weren't signaled are received once the RID
+			// of a simulcast stream arrives, draining stops when a
+			// receiver reads the stream
+			go func() {
+				rtpBuf := make([]byte, receiveMTU)
 				for {
-					_, header, err := r.ReadRTP(rtpBuf)
+					n, header, err := r.ReadRTP(rtpBuf)
 					if err != nil {
 						pc.log.Warnf("Failed to read, drainSRTP done for: %v %d \n", err, ssrc)
 						return
 					}
+					if pc.receivesSSRC(ssrc) || pc.receiveUndeclaredSSRC(ssrc, rtpBuf[:n], header) {
+						return
+					}
 
 					pc.log.Debugf("got RTP: %+v", header)
 				}
@@ -1251,6 +2289,9 @@ func (pc *PeerConnection) drainSRTP() {
 					pc.log.Warnf("Failed to read, drainSRTCP done for: %v %d \n", err, ssrc)
 					return
 				}
+				if pc.receivesSSRC(ssrc) {
+					return
+				}
 				pc.log.Debugf("got RTCP: %+v", header)
 			}
 		}()
@@ -1275,6 +2316,11 @@ func (pc *PeerConnection) AddICECandidate(candidate ICECandidateInit) error {
 		return &rtcerr.InvalidStateError{Err: ErrNoRemoteDescription}
 	}
 
+	// An empty candidate signals the end of remote candidates
+	if candidate.Candidate == "" {
+		return nil
+	}
+
 	candidateValue := strings.TrimPrefix(candidate.Candidate, "candidate:")
 	attribute := sdp.NewAttribute("candidate", candidateValue)
 	sdpCandidate, err := attribute.ToICECandidate()
@@ -1341,22 +2387,28 @@ func (pc *PeerConnection) GetTransceivers() []*RTPTransceiver {
 
 // AddTrack adds a Track to the PeerConnection
 func (pc *PeerConnection) AddTrack(track *Track) (*RTPSender, error) {
-	if pc.isClosed {
+	if pc.closed() {
 		return nil, &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
 	}
+	// Transceivers created without a track are reused
+	// https://w3c.github.io/webrtc-pc/#dom-rtcpeerconnection-addtrack (step #7)
 	var transceiver *RTPTransceiver
 	for _, t := range pc.GetTransceivers() {
 		if !t.stopped &&
+			t.kind == track.Kind() &&
 			t.Sender != nil &&
-			!t.Sender.hasSent() &&
-			t.Receiver != nil &&
-			t.Receiver.Track() != nil &&
-			t.Receiver.Track().Kind() == track.Kind() {
+			t.Sender.Track() == nil {
 			transceiver = t
 			break
 		}
 	}
 	if transceiver != nil {
+		// A sender whose track was removed can't be started anymore
+		if transceiver.Sender.hasSent() || transceiver.Sender.hasStopped() {
+			sender := pc.api.newRTPSender(nil, pc.dtlsTransport)
+			sender.streamIDs = transceiver.Sender.streamIDs
+			transceiver.Sender = sender
+		}
 		if err := transceiver.setSendingTrack(track); err != nil {
 			return nil, err
 		}
@@ -1373,17 +2425,108 @@ func (pc *PeerConnection) AddTrack(track *Track) (*RTPSender, error) {
 		)
 	}
 
-	transceiver.Mid = track.Kind().String() // TODO: Mid generation
-
+	pc.updateNegotiationNeededFlag()
 	return transceiver.Sender, nil
 }
 
-// func (pc *PeerConnection) RemoveTrack() {
-// 	panic("not implemented yet") // FIXME NOT-IMPLEMENTED nolint
-// }
+// RemoveTrack stops sending the Track of an RTPSender, the direction of the
+// owning RTPTransceiver no longer includes sending. The change has to be
+// negotiated, OnNegotiationNeeded fires to signal it.
+// https://w3c.github.io/webrtc-pc/#dom-rtcpeerconnection-removetrack
+func (pc *PeerConnection) RemoveTrack(sender *RTPSender) error {
+	if pc.closed() {
+		return &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
+	}
+
+	var transceiver *RTPTransceiver
+	for _, t := range pc.GetTransceivers() {
+		if t.Sender == sender {
+			transceiver = t
+			break
+		}
+	}
+	if transceiver == nil {
+		return &rtcerr.InvalidAccessError{Err: ErrSenderNotCreatedByConnection}
+	}
+
+	// The track has been removed already
+	sender.mu.RLock()
+	track := sender.track
+	sender.mu.RUnlock()
+	if track == nil {
+		return nil
+	}
+
+	if err := sender.Stop(); err != nil {
+		return err
+	}
+	if err := transceiver.setSendingTrack(nil); err != nil {
+		return err
+	}
+
+	pc.updateNegotiationNeededFlag()
+	return nil
+}
 
 // AddTransceiver Create a new RTCRtpTransceiver and add it to the set of transceivers.
+// A sendrecv transceiver sends a placeholder track with the default codec of
+// its kind and a recvonly transceiver has no sender, so AddTrack doesn't
+// reuse it. Other directions are created like AddTransceiverFromKind does.
+//
+// Deprecated: Use AddTransceiverFromKind or AddTransceiverFromTrack
 func (pc *PeerConnection) AddTransceiver(trackOrKind RTPCodecType, init ...RtpTransceiverInit) (*RTPTransceiver, error) {
+	if pc.closed() {
+		return nil, &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
+	}
+
+	t, err := pc.newTransceiver(trackOrKind, init...)
+	if err != nil {
+		return nil, err
+	}
+
+	pc.updateNegotiationNeededFlag()
+	return t, nil
+}
+
+// AddTransceiverFromKind creates a new RTPTransceiver of the given kind and
+// adds it to the set of transceivers. The sender has no track until one is
+// added with AddTrack, which reuses the transceiver.
+// https://w3c.github.io/webrtc-pc/#dom-rtcpeerconnection-addtransceiver
+func (pc *PeerConnection) AddTransceiverFromKind(kind RTPCodecType, init ...RtpTransceiverInit) (*RTPTransceiver, error) {
+	if pc.closed() {
+		return nil, &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
+	}
+
+	t, err := pc.newTransceiverFromKind(kind, init...)
+	if err != nil {
+		return nil, err
+	}
+
+	pc.updateNegotiationNeededFlag()
+	return t, nil
+}
+
+// AddTransceiverFromTrack creates a new RTPTransceiver that sends track and
+// adds it to the set of transceivers.
+// https://w3c.github.io/webrtc-pc/#dom-rtcpeerconnection-addtransceiver
+func (pc *PeerConnection) AddTransceiverFromTrack(track *Track, init ...RtpTransceiverInit) (*RTPTransceiver, error) {
+	if pc.closed() {
+		return nil, &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
+	} else if track == nil {
+		return nil, &rtcerr.TypeError{Err: fmt.Errorf("track must not be nil")}
+	}
+
+	t, err := pc.newTransceiverFromTrack(track, init...)
+	if err != nil {
+		return nil, err
+	}
+
+	pc.updateNegotiationNeededFlag()
+	return t, nil
+}
+
+// newTransceiver creates the transceivers of the deprecated AddTransceiver
+func (pc *PeerConnection) newTransceiver(kind RTPCodecType, init ...RtpTransceiverInit) (*RTPTransceiver, error) {
 	direction := RTPTransceiverDirectionSendrecv
 	if len(init) > 1 {
 		return nil, fmt.Errorf("AddTransceiver only accepts one RtpTransceiverInit")
@@ -1393,13 +2536,8 @@ func (pc *PeerConnection) AddTransceiver(trackOrKind RTPCodecType, init ...RtpTr
 
 	switch direction {
 	case RTPTransceiverDirectionSendrecv:
-		receiver, err := pc.api.NewRTPReceiver(trackOrKind, pc.dtlsTransport)
-		if err != nil {
-			return nil, err
-		}
-
 		payloadType := DefaultPayloadTypeOpus
-		if trackOrKind == RTPCodecTypeVideo {
+		if kind == RTPCodecTypeVideo {
 			payloadType = DefaultPayloadTypeVP8
 		}
 
@@ -1408,20 +2546,10 @@ func (pc *PeerConnection) AddTransceiver(trackOrKind RTPCodecType, init ...RtpTr
 			return nil, err
 		}
 
-		sender, err := pc.api.NewRTPSender(track, pc.dtlsTransport)
-		if err != nil {
-			return nil, err
-		}
-
-		return pc.newRTPTransceiver(
-			receiver,
-			sender,
-			RTPTransceiverDirectionSendrecv,
-			trackOrKind,
-		), nil
+		return pc.newTransceiverFromTrack(track, init...)
 
 	case RTPTransceiverDirectionRecvonly:
-		receiver, err := pc.api.NewRTPReceiver(trackOrKind, pc.dtlsTransport)
+		receiver, err := pc.api.NewRTPReceiver(kind, pc.dtlsTransport)
 		if err != nil {
 			return nil, err
 		}
@@ -1430,10 +2558,98 @@ func (pc *PeerConnection) AddTransceiver(trackOrKind RTPCodecType, init ...RtpTr
 			receiver,
 			nil,
 			RTPTransceiverDirectionRecvonly,
-			trackOrKind,
+			kind,
 		), nil
+
 	default:
-		return nil, fmt.Errorf("AddTransceiver currently only suports recvonly and sendrecv")
+		return pc.newTransceiverFromKind(kind, init...)
+	}
+}
+
+func (pc *PeerConnection) newTransceiverFromTrack(track *Track, init ...RtpTransceiverInit) (*RTPTransceiver, error) {
+	direction, streamIDs, err := transceiverInit(init)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every transceiver has a receiver, the direction may include receiving
+	// later on
+	receiver, err := pc.api.NewRTPReceiver(track.Kind(), pc.dtlsTransport)
+	if err != nil {
+		return nil, err
+	}
+
+	sender, err := pc.api.NewRTPSender(track, pc.dtlsTransport)
+	if err != nil {
+		return nil, err
+	}
+	sender.streamIDs = streamIDs
+
+	// Several encodings are sent as the layers of simulcast
+	if len(init) == 1 && len(init[0].SendEncodings) > 1 {
+		if err := sender.setSimulcastEncodings(init[0].SendEncodings); err != nil {
+			return nil, err
+		}
+	}
+
+	return pc.newRTPTransceiver(receiver, sender, direction, track.Kind()), nil
+}
+
+func (pc *PeerConnection) newTransceiverFromKind(kind RTPCodecType, init ...RtpTransceiverInit) (*RTPTransceiver, error) {
+	if kind != RTPCodecTypeAudio && kind != RTPCodecTypeVideo {
+		return nil, &rtcerr.TypeError{Err: ErrUnknownType}
+	}
+
+	direction, streamIDs, err := transceiverInit(init)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every transceiver has a receiver, the direction may include receiving
+	// later on
+	receiver, err := pc.api.NewRTPReceiver(kind, pc.dtlsTransport)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every transceiver has a sender, a track can be added to it later on
+	sender := pc.api.newRTPSender(nil, pc.dtlsTransport)
+	sender.streamIDs = streamIDs
+
+	return pc.newRTPTransceiver(receiver, sender, direction, kind), nil
+}
+
+// transceiverInit returns the direction and stream ids requested by the
+// optional RtpTransceiverInit of AddTransceiverFromKind and AddTransceiverFromTrack
+func transceiverInit(init []RtpTransceiverInit) (RTPTransceiverDirection, []string, error) {
+	switch {
+	case len(init) > 1:
+		return RTPTransceiverDirection(Unknown), nil, fmt.Errorf("AddTransceiver only accepts one RtpTransceiverInit")
+	case len(init) == 0:
+		return RTPTransceiverDirectionSendrecv, nil, nil
+	}
+
+	// The layers of simulcast are identified by their RIDs
+	// https://w3c.github.io/webrtc-pc/#dom-rtcpeerconnection-addtransceiver (step #8)
+	if encodings := init[0].SendEncodings; len(encodings) > 1 {
+		rids := map[string]bool{}
+		for _, encoding := range encodings {
+			if !validRID(encoding.RID) {
+				return RTPTransceiverDirection(Unknown), nil, &rtcerr.TypeError{Err: fmt.Errorf("invalid RID %q", encoding.RID)}
+			} else if rids[encoding.RID] {
+				return RTPTransceiverDirection(Unknown), nil, &rtcerr.TypeError{Err: fmt.Errorf("RID %s is used by more than one encoding", encoding.RID)}
+			}
+			rids[encoding.RID] = true
+		}
+	}
+
+	switch init[0].Direction {
+	case RTPTransceiverDirection(Unknown):
+		return RTPTransceiverDirectionSendrecv, init[0].StreamIDs, nil
+	case RTPTransceiverDirectionSendrecv, RTPTransceiverDirectionSendonly, RTPTransceiverDirectionRecvonly, RTPTransceiverDirectionInactive:
+		return init[0].Direction, init[0].StreamIDs, nil
+	default:
+		return RTPTransceiverDirection(Unknown), nil, &rtcerr.TypeError{Err: ErrUnknownType}
 	}
 }
 
@@ -1512,11 +2728,18 @@ func (pc *PeerConnection) CreateDataChannel(label string, options *DataChannelIn
 
 	// Remember datachannel
 	pc.dataChannels[params.ID] = d
+	pc.dataChannelsRequested++
+	isFirstDataChannel := len(pc.dataChannels) == 1
 
 	sctpReady := pc.sctpTransport != nil && pc.sctpTransport.association != nil
 
 	pc.mu.Unlock()
 
+	// https://w3c.github.io/webrtc-pc/#peer-to-peer-data-api (Step #18)
+	if isFirstDataChannel {
+		pc.updateNegotiationNeededFlag()
+	}
+
 	// Open if networking already started
 	if sctpReady {
 		err = d.open(pc.sctpTransport)
@@ -1556,22 +2779,15 @@ func (pc *PeerConnection) SetIdentityProvider(provider string) error {
 // WriteRTCP sends a user provided RTCP packet to the connected peer
 // If no peer is connected the packet is discarded
 func (pc *PeerConnection) WriteRTCP(pkts []rtcp.Packet) error {
-	raw, err := rtcp.Marshal(pkts)
-	if err != nil {
+	if _, err := rtcp.Marshal(pkts); err != nil {
 		return err
 	}
 
-	srtcpSession, err := pc.dtlsTransport.getSRTCPSession()
-	if err != nil {
+	if _, err := pc.dtlsTransport.getSRTCPSession(); err != nil {
 		return nil // TODO WriteRTCP before would gracefully discard packets until ready
 	}
 
-	writeStream, err := srtcpSession.OpenWriteStream()
-	if err != nil {
-		return fmt.Errorf("WriteRTCP failed to open WriteStream: %v", err)
-	}
-
-	if _, err := writeStream.Write(raw); err != nil {
+	if _, err := pc.dtlsTransport.rtcpWriter.Write(pkts, interceptor.Attributes{}); err != nil {
 		if err == ice.ErrNoCandidatePairs {
 			return nil
 		} else if err == ice.ErrClosed {
@@ -1580,18 +2796,27 @@ func (pc *PeerConnection) WriteRTCP(pkts []rtcp.Packet) error {
 
 		return fmt.Errorf("WriteRTCP failed to write: %v", err)
 	}
+
+	for _, t := range pc.GetTransceivers() {
+		if t.Receiver != nil {
+			t.Receiver.countFeedback(pkts)
+		}
+	}
 	return nil
 }
 
 // Close ends the PeerConnection
 func (pc *PeerConnection) Close() error {
 	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-close (step #2)
+	pc.mu.Lock()
 	if pc.isClosed {
+		pc.mu.Unlock()
 		return nil
 	}
 
 	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-close (step #3)
 	pc.isClosed = true
+	pc.mu.Unlock()
 
 	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-close (step #4)
 	pc.signalingState = SignalingStateClosed
@@ -1601,7 +2826,7 @@ func (pc *PeerConnection) Close() error {
 	pc.iceStateChange(ice.ConnectionStateClosed) // FIXME REMOVE
 
 	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-close (step #12)
-	pc.connectionState = PeerConnectionStateClosed
+	pc.updateConnectionState(pc.ICEConnectionState(), pc.dtlsTransport.State())
 
 	// Try closing everything and collect the errors
 	var closeErrs []error
@@ -1645,6 +2870,54 @@ func (pc *PeerConnection) iceStateChange(newState ICEConnectionState) {
 	pc.mu.Unlock()
 
 	pc.onICEConnectionStateChange(newState)
+
+	// DTLS is only reported as connected once media has been started
+	pc.mediaLock.Lock()
+	mediaStarted := pc.mediaStarted
+	pc.mediaLock.Unlock()
+	dtlsTransportState := pc.dtlsTransport.State()
+	if dtlsTransportState == DTLSTransportStateConnected && !mediaStarted {
+		dtlsTransportState = DTLSTransportStateConnecting
+	}
+	pc.updateConnectionState(newState, dtlsTransportState)
+
+	// Only the controlling agent restarts to avoid both peers sending offers
+	if pc.api.settingEngine.iceRestart.Automatic &&
+		(newState == ICEConnectionStateDisconnected || newState == ICEConnectionStateFailed) &&
+		pc.iceTransport.Role() == ICERoleControlling {
+		pc.log.Infof("ICE connection state %s, restarting ICE", newState)
+		pc.RestartICE()
+	}
+}
+
+// RestartICE requests an ICE restart, the next offer created with CreateOffer
+// has new ICE credentials as if OfferOptions.ICERestart was set. The restart
+// is signaled through OnNegotiationNeeded.
+// https://w3c.github.io/webrtc-pc/#dom-rtcpeerconnection-restartice
+func (pc *PeerConnection) RestartICE() {
+	ufrag := ""
+	if desc := pc.currentLocalDescription; desc != nil && desc.parsed != nil {
+		ufrag = descriptionICEUfrag(desc.parsed)
+	}
+
+	pc.mu.Lock()
+	pc.iceRestartNeeded = true
+	pc.iceRestartUfrag = ufrag
+	pc.mu.Unlock()
+
+	pc.updateNegotiationNeededFlag()
+}
+
+// descriptionICEUfrag returns the ICE username fragment of a session
+// description, it is signaled in every media section or for the session
+func descriptionICEUfrag(desc *sdp.SessionDescription) string {
+	for _, media := range desc.MediaDescriptions {
+		if ufrag, ok := media.Attribute("ice-ufrag"); ok {
+			return ufrag
+		}
+	}
+	ufrag, _ := desc.Attribute("ice-ufrag")
+	return ufrag
 }
 
 func (pc *PeerConnection) addFingerprint(d *sdp.SessionDescription) error {
@@ -1659,9 +2932,31 @@ func (pc *PeerConnection) addFingerprint(d *sdp.SessionDescription) error {
 	return nil
 }
 
-func (pc *PeerConnection) addTransceiverSDP(d *sdp.SessionDescription, midValue string, iceParams ICEParameters, candidates []ICECandidate, dtlsRole sdp.ConnectionRole, transceivers ...*RTPTransceiver) error {
+// addRejectedMediaSection adds a media section of kind with port 0 to d, the
+// mid is kept so the section can still be matched with the other description
+// https://tools.ietf.org/html/draft-ietf-rtcweb-jsep-26#section-5.3.1
+func addRejectedMediaSection(d *sdp.SessionDescription, kind, midValue string) {
+	media := &sdp.MediaDescription{
+		MediaName: sdp.MediaName{
+			Media:   kind,
+			Port:    sdp.RangedPort{Value: 0},
+			Protos:  []string{"UDP", "TLS", "RTP", "SAVPF"},
+			Formats: []string{"0"},
+		},
+	}
+	if midValue != "" {
+		media = media.WithValueAttribute(sdp.AttrKeyMID, midValue)
+	}
+	d.WithMedia(media)
+}
+
+// addTransceiverSDP adds the media section of transceivers to d, it returns
+// false if the section is rejected because no codec could be negotiated.
+// Rejected sections are not part of a BUNDLE group.
+// https://tools.ietf.org/html/draft-ietf-mmusic-sdp-bundle-negotiation-54#section-7.3.3
+func (pc *PeerConnection) addTransceiverSDP(d *sdp.SessionDescription, midValue string, iceParams ICEParameters, candidates []ICECandidate, dtlsRole sdp.ConnectionRole, voiceActivityDetection bool, remoteMedia *sdp.MediaDescription, transceivers ...*RTPTransceiver) (bool, error) {
 	if len(transceivers) < 1 {
-		return fmt.Errorf("addTransceiverSDP() called with 0 transceivers")
+		return false, fmt.Errorf("addTransceiverSDP() called with 0 transceivers")
 	}
 	// Use the first transceiver to generate the section attributes
 	t := transceivers[0]
@@ -1672,54 +2967,575 @@ func (pc *PeerConnection) addTransceiverSDP(d *sdp.SessionDescription, midValue
 		WithPropertyAttribute(sdp.AttrKeyRTCPMux). // TODO: support RTCP fallback
 		WithPropertyAttribute(sdp.AttrKeyRTCPRsize)
 
-	codecs := pc.api.mediaEngine.getCodecsByKind(t.kind)
+	retransmission := false
+	for _, mt := range transceivers {
+		if mt.Sender != nil && mt.Sender.retransmissionEnabled() {
+			retransmission = true
+		}
+	}
+
+	codecs := pc.negotiationCodecs(t, remoteMedia)
 	for _, codec := range codecs {
+		// Comfort noise is only signaled when voice activity detection is wanted
+		// https://tools.ietf.org/html/draft-ietf-rtcweb-jsep-26#section-5.2.3.2
+		if codec.Name == CN && !voiceActivityDetection {
+			continue
+		}
+
 		media.WithCodec(codec.PayloadType, codec.Name, codec.ClockRate, codec.Channels, codec.SDPFmtpLine)
 
-		for _, feedback := range codec.RTPCodecCapability.RTCPFeedback {
-			media.WithValueAttribute("rtcp-fb", fmt.Sprintf("%d %s %s", codec.PayloadType, feedback.Type, feedback.Parameter))
+		feedbacks := codec.RTPCodecCapability.RTCPFeedback
+		if retransmission && !hasRTCPFeedback(feedbacks, RTCPFeedback{Type: TypeRTCPFBNACK}) &&
+			(remoteMedia == nil || mediaHasRTCPFeedback(remoteMedia, codec.PayloadType, RTCPFeedback{Type: TypeRTCPFBNACK})) {
+			feedbacks = append(append([]RTCPFeedback{}, feedbacks...), RTCPFeedback{Type: TypeRTCPFBNACK})
+		}
+
+		for _, feedback := range feedbacks {
+			media.WithValueAttribute("rtcp-fb", strings.TrimSpace(fmt.Sprintf("%d %s %s", codec.PayloadType, feedback.Type, feedback.Parameter)))
 		}
 	}
 	if len(codecs) == 0 {
 		// Explicitly reject track if we don't have the codec
-		d.WithMedia(&sdp.MediaDescription{
-			MediaName: sdp.MediaName{
-				Media:   t.kind.String(),
-				Port:    sdp.RangedPort{Value: 0},
-				Protos:  []string{"UDP", "TLS", "RTP", "SAVPF"},
-				Formats: []string{"0"},
-			},
-		})
-		return nil
+		addRejectedMediaSection(d, t.kind.String(), midValue)
+		return false, nil
+	}
+
+	extensions, err := pc.headerExtensionsSDP(t.kind, midValue, remoteMedia)
+	if err != nil {
+		return false, err
+	}
+	for _, extension := range extensions {
+		media.WithValueAttribute("extmap", fmt.Sprintf("%d %s", extension.ID, extension.URI))
+	}
+
+	// The simulcast streams offered by the remote are received when their
+	// RID can be read from the packets
+	// https://tools.ietf.org/html/rfc8853#section-5.3
+	if _, ok := headerExtensionID(extensions, SDESRTPStreamIDURI); ok && remoteMedia != nil &&
+		(t.Direction == RTPTransceiverDirectionRecvonly || t.Direction == RTPTransceiverDirectionSendrecv) {
+		if rids := getMediaSimulcastRIDs(remoteMedia, ridDirectionSend); len(rids) != 0 {
+			media = addMediaSimulcast(media, ridDirectionRecv, rids)
+		}
 	}
 
 	for _, mt := range transceivers {
-		if mt.Sender != nil && mt.Sender.track != nil {
-			track := mt.Sender.track
-			media = media.WithMediaSource(track.SSRC(), track.Label() /* cname */, track.Label() /* streamLabel */, track.ID())
-			if pc.configuration.SDPSemantics == SDPSemanticsUnifiedPlan {
-				media = media.WithPropertyAttribute("msid:" + track.Label() + " " + track.ID())
-				break
+		if mt.Sender == nil || mt.Sender.Track() == nil {
+			continue
+		}
+
+		track := mt.Sender.Track()
+		streamIDs := mt.Sender.streamIDs
+		if len(streamIDs) == 0 {
+			streamIDs = []string{track.Label()}
+		}
+
+		// The layers of simulcast are signaled by their RIDs instead of
+		// their SSRCs
+		// https://tools.ietf.org/html/rfc8853#section-5.1
+		if rids := pc.simulcastRIDsSDP(mt.Sender, extensions, remoteMedia); len(rids) != 0 {
+			media = addMediaSimulcast(media, ridDirectionSend, rids)
+			for _, streamID := range streamIDs {
+				media = media.WithPropertyAttribute("msid:" + streamID + " " + track.ID())
 			}
+			break
+		}
+
+		mt.Sender.mu.RLock()
+		encoding := mt.Sender.encodings[0]
+		mt.Sender.mu.RUnlock()
+
+		// Retransmissions are sent on an RTX stream when an RTX codec
+		// for the codec of the track is signaled
+		// https://tools.ietf.org/html/rfc4588#section-8.7
+		rtx := mt.Sender.retransmissionEnabled() && hasRTXCodec(codecs, track.PayloadType())
+		if rtx {
+			media = media.WithValueAttribute("ssrc-group", fmt.Sprintf("FID %d %d", track.SSRC(), encoding.rtxSSRC))
+		}
+
+		// FlexFEC packets are sent on a separate stream too
+		// https://tools.ietf.org/html/draft-ietf-payload-flexible-fec-scheme-03#section-5.1.2
+		flexfec := mt.Sender.enabledFECMechanism() == FECMechanismFlexFEC && hasCodec(codecs, FlexFEC)
+		if flexfec {
+			media = media.WithValueAttribute("ssrc-group", fmt.Sprintf("FEC-FR %d %d", track.SSRC(), encoding.fecSSRC))
+		}
+		media = media.WithMediaSource(track.SSRC(), track.Label() /* cname */, streamIDs[0] /* streamLabel */, track.ID())
+		if rtx {
+			media = media.WithMediaSource(encoding.rtxSSRC, track.Label() /* cname */, streamIDs[0] /* streamLabel */, track.ID())
+		}
+		if flexfec {
+			media = media.WithMediaSource(encoding.fecSSRC, track.Label() /* cname */, streamIDs[0] /* streamLabel */, track.ID())
+		}
+		if pc.configuration.SDPSemantics == SDPSemanticsUnifiedPlan {
+			for _, streamID := range streamIDs {
+				media = media.WithPropertyAttribute("msid:" + streamID + " " + track.ID())
+			}
+			break
 		}
 	}
 
 	media = media.WithPropertyAttribute(t.Direction.String())
-	for _, c := range candidates {
-		sdpCandidate := c.toSDP()
-		sdpCandidate.ExtensionAttributes = append(sdpCandidate.ExtensionAttributes, sdp.ICECandidateAttribute{Key: "generation", Value: "0"})
-		sdpCandidate.Component = 1
-		media.WithICECandidate(sdpCandidate)
-		sdpCandidate.Component = 2
-		media.WithICECandidate(sdpCandidate)
+	addICECandidates(media, candidates, len(candidates) != 0 && pc.ICEGatheringState() == ICEGatheringStateComplete)
+
+	d.WithMedia(media)
+
+	return true, nil
+}
+
+// negotiationCodecs returns the codecs signaled for a transceiver, its codec
+// preferences or every codec of its kind. When answering only the codecs
+// that are offered by the remote are kept, with the payload types of the
+// offer.
+// https://tools.ietf.org/html/draft-ietf-rtcweb-jsep-26#section-5.3.1
+func (pc *PeerConnection) negotiationCodecs(t *RTPTransceiver, remoteMedia *sdp.MediaDescription) []*RTPCodec {
+	codecs := t.getCodecPreferences()
+	if len(codecs) == 0 {
+		codecs = pc.api.mediaEngine.getCodecsByKind(t.kind)
 	}
-	if len(candidates) != 0 {
-		media.WithPropertyAttribute("end-of-candidates")
+
+	if remoteMedia != nil {
+		codecs = answerCodecs(codecs, getMediaCodecs(remoteMedia))
 	}
 
-	d.WithMedia(media)
+	// RTX codecs are dropped with the codec they retransmit
+	payloadTypes := map[uint8]bool{}
+	for _, codec := range codecs {
+		payloadTypes[codec.PayloadType] = true
+	}
+	negotiated := []*RTPCodec{}
+	for _, codec := range codecs {
+		if strings.EqualFold(codec.Name, RTX) {
+			if apt, ok := rtxAssociatedPayloadType(codec.SDPFmtpLine); !ok || !payloadTypes[apt] {
+				continue
+			}
+		}
+		negotiated = append(negotiated, codec)
+	}
+	return negotiated
+}
 
-	return nil
+// answerCodecs returns the codecs that are offered in remoteCodecs, in the
+// order of codecs. They are copied with the payload types of the offer,
+// RTX codecs are matched by the offered payload type of the codec they
+// retransmit.
+// https://tools.ietf.org/html/rfc3264#section-6.1
+func answerCodecs(codecs []*RTPCodec, remoteCodecs []sdp.Codec) []*RTPCodec {
+	payloadTypes := map[uint8]uint8{}
+	for _, codec := range codecs {
+		if strings.EqualFold(codec.Name, RTX) {
+			continue
+		}
+		for _, remoteCodec := range remoteCodecs {
+			if strings.EqualFold(codec.Name, remoteCodec.Name) &&
+				codec.ClockRate == remoteCodec.ClockRate &&
+				(remoteCodec.EncodingParameters == "" || strconv.Itoa(int(codec.Channels)) == remoteCodec.EncodingParameters) {
+				payloadTypes[codec.PayloadType] = remoteCodec.PayloadType
+				break
+			}
+		}
+	}
+
+	answered := []*RTPCodec{}
+	for _, codec := range codecs {
+		for _, remoteCodec := range remoteCodecs {
+			if strings.EqualFold(codec.Name, RTX) {
+				apt, ok := rtxAssociatedPayloadType(codec.SDPFmtpLine)
+				remoteApt, remoteOK := rtxAssociatedPayloadType(remoteCodec.Fmtp)
+				remoteAssociated, associated := payloadTypes[apt]
+				if !strings.EqualFold(remoteCodec.Name, RTX) || !ok || !remoteOK || !associated || remoteApt != remoteAssociated {
+					continue
+				}
+
+				answer := *codec
+				answer.PayloadType = remoteCodec.PayloadType
+				answer.SDPFmtpLine = remoteCodec.Fmtp
+				answered = append(answered, &answer)
+				break
+			}
+
+			if remotePayloadType, ok := payloadTypes[codec.PayloadType]; ok && remotePayloadType == remoteCodec.PayloadType {
+				answer := *codec
+				answer.PayloadType = remoteCodec.PayloadType
+				answered = append(answered, &answer)
+				break
+			}
+		}
+	}
+	return answered
+}
+
+// hasCodec tells if codecs has a codec with name
+func hasCodec(codecs []*RTPCodec, name string) bool {
+	for _, codec := range codecs {
+		if strings.EqualFold(codec.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRTXCodec tells if codecs has an RTX codec for payloadType
+func hasRTXCodec(codecs []*RTPCodec, payloadType uint8) bool {
+	for _, codec := range codecs {
+		if !strings.EqualFold(codec.Name, RTX) {
+			continue
+		}
+		if apt, ok := rtxAssociatedPayloadType(codec.SDPFmtpLine); ok && apt == payloadType {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiatedRTXPayloadType returns the payload type of the RTX codec for
+// payloadType in the media section with mid, it is false unless both the
+// local and the remote description signal one
+func (pc *PeerConnection) negotiatedRTXPayloadType(mid string, payloadType uint8) (uint8, bool) {
+	return pc.negotiatedPayloadType(mid, func(codec sdp.Codec) bool {
+		if !strings.EqualFold(codec.Name, RTX) {
+			return false
+		}
+		apt, ok := rtxAssociatedPayloadType(codec.Fmtp)
+		return ok && apt == payloadType
+	})
+}
+
+// negotiatedTrackPayloadType returns the payload type of codec in the media
+// section with mid, it is false unless both the local and the remote
+// description signal it
+func (pc *PeerConnection) negotiatedTrackPayloadType(mid string, codec *RTPCodec) (uint8, bool) {
+	return pc.negotiatedPayloadType(mid, func(sdpCodec sdp.Codec) bool {
+		return strings.EqualFold(codec.Name, sdpCodec.Name) &&
+			codec.ClockRate == sdpCodec.ClockRate &&
+			(sdpCodec.EncodingParameters == "" || strconv.Itoa(int(codec.Channels)) == sdpCodec.EncodingParameters)
+	})
+}
+
+// negotiatedCodecPayloadType returns the payload type of the codec with
+// name in the media section with mid, it is false unless both the local
+// and the remote description signal it
+func (pc *PeerConnection) negotiatedCodecPayloadType(mid string, name string) (uint8, bool) {
+	return pc.negotiatedPayloadType(mid, func(codec sdp.Codec) bool {
+		return strings.EqualFold(codec.Name, name)
+	})
+}
+
+// negotiatedPayloadType returns the payload type of a codec matching match
+// in the media section with mid of both the local and the remote
+// description
+func (pc *PeerConnection) negotiatedPayloadType(mid string, match func(codec sdp.Codec) bool) (uint8, bool) {
+	payloadType, found := uint8(0), false
+	for _, desc := range []*SessionDescription{pc.currentLocalDescription, pc.currentRemoteDescription} {
+		if desc == nil || desc.parsed == nil {
+			return 0, false
+		}
+
+		found = false
+		for _, media := range desc.parsed.MediaDescriptions {
+			if pc.getMidValue(media) != mid {
+				continue
+			}
+
+			for _, codec := range getMediaCodecs(media) {
+				if match(codec) {
+					payloadType, found = codec.PayloadType, true
+				}
+			}
+		}
+		if !found {
+			return 0, false
+		}
+	}
+
+	// The payload type of the remote description is the one the remote
+	// peer expects
+	return payloadType, true
+}
+
+// negotiatedMedia returns the media sections with mid of the local and the
+// remote description, they are nil unless both have one
+func (pc *PeerConnection) negotiatedMedia(mid string) (local *sdp.MediaDescription, remote *sdp.MediaDescription) {
+	for _, desc := range []*SessionDescription{pc.currentLocalDescription, pc.currentRemoteDescription} {
+		if desc == nil || desc.parsed == nil {
+			return nil, nil
+		}
+	}
+
+	for _, media := range pc.currentLocalDescription.parsed.MediaDescriptions {
+		if pc.getMidValue(media) == mid {
+			local = media
+		}
+	}
+	for _, media := range pc.currentRemoteDescription.parsed.MediaDescriptions {
+		if pc.getMidValue(media) == mid {
+			remote = media
+		}
+	}
+	if local == nil || remote == nil {
+		return nil, nil
+	}
+	return local, remote
+}
+
+// negotiatedCodecParameters returns the codecs of the media section with
+// mid that both descriptions signal, in the order the remote peer prefers
+// them. Only the RTCP feedback signaled by both is kept.
+func (pc *PeerConnection) negotiatedCodecParameters(mid string) []RTPCodecParameters {
+	local, remote := pc.negotiatedMedia(mid)
+	if local == nil {
+		return nil
+	}
+
+	localCodecs := getMediaCodecs(local)
+	codecs := []RTPCodecParameters{}
+	for _, codec := range getMediaCodecs(remote) {
+		found := false
+		for _, localCodec := range localCodecs {
+			if localCodec.PayloadType == codec.PayloadType && strings.EqualFold(localCodec.Name, codec.Name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+
+		parameters := RTPCodecParameters{
+			MimeType:    remote.MediaName.Media + "/" + codec.Name,
+			PayloadType: codec.PayloadType,
+			ClockRate:   codec.ClockRate,
+			SDPFmtpLine: codec.Fmtp,
+		}
+		if channels, err := strconv.ParseUint(codec.EncodingParameters, 10, 16); err == nil {
+			parameters.Channels = uint16(channels)
+		}
+		for _, feedback := range mediaRTCPFeedback(remote, codec.PayloadType) {
+			if mediaHasRTCPFeedback(local, codec.PayloadType, feedback) {
+				parameters.RTCPFeedback = append(parameters.RTCPFeedback, feedback)
+			}
+		}
+		codecs = append(codecs, parameters)
+	}
+	return codecs
+}
+
+// negotiatedHeaderExtensions returns the RTP header extensions of the media
+// section with mid that both descriptions signal, with the IDs of the
+// remote description
+// https://tools.ietf.org/html/rfc8285#section-8
+func (pc *PeerConnection) negotiatedHeaderExtensions(mid string) []RTPHeaderExtensionParameters {
+	local, remote := pc.negotiatedMedia(mid)
+	if local == nil {
+		return nil
+	}
+
+	localURIs := map[string]bool{}
+	for _, extension := range getMediaHeaderExtensions(local) {
+		localURIs[extension.URI] = true
+	}
+
+	extensions := []RTPHeaderExtensionParameters{}
+	for _, extension := range getMediaHeaderExtensions(remote) {
+		if localURIs[extension.URI] {
+			extensions = append(extensions, extension)
+		}
+	}
+	return extensions
+}
+
+// headerExtensionsSDP returns the header extensions registered for kind
+// with the IDs they are signaled with in the media section with mid. An
+// answer takes the IDs of remoteMedia and leaves out the extensions it
+// doesn't offer, an offer keeps the IDs that were negotiated before.
+// ErrNoHeaderExtensionID is returned when an extension doesn't fit into
+// the IDs left over.
+// https://tools.ietf.org/html/rfc8285#section-6
+func (pc *PeerConnection) headerExtensionsSDP(kind RTPCodecType, mid string, remoteMedia *sdp.MediaDescription) ([]RTPHeaderExtensionParameters, error) {
+	registered := pc.api.mediaEngine.getHeaderExtensions(kind)
+	extensions := []RTPHeaderExtensionParameters{}
+	if remoteMedia != nil {
+		for _, extension := range registered {
+			for _, remote := range getMediaHeaderExtensions(remoteMedia) {
+				if remote.URI == extension.URI {
+					extensions = append(extensions, remote)
+					break
+				}
+			}
+		}
+		return extensions, nil
+	}
+
+	// Extensions that weren't negotiated before get the registered ID
+	// unless it is taken by another extension already, then the lowest
+	// free ID of the one-byte header form is used like in the MediaEngine.
+	// The local description holds the IDs in use, extensions a remote offer
+	// signaled that weren't accepted don't take up any.
+	negotiated := map[string]int{}
+	used := map[int]bool{}
+	if previous, _ := pc.negotiatedMedia(mid); previous != nil {
+		for _, extension := range getMediaHeaderExtensions(previous) {
+			negotiated[extension.URI] = extension.ID
+			used[extension.ID] = true
+		}
+	}
+	for _, extension := range registered {
+		if id, ok := negotiated[extension.URI]; ok {
+			extension.ID = id
+		} else {
+			if used[extension.ID] {
+				extension.ID = 0
+				for id := 1; id <= 14; id++ {
+					if !used[id] {
+						extension.ID = id
+						break
+					}
+				}
+				if extension.ID == 0 {
+					return nil, ErrNoHeaderExtensionID
+				}
+			}
+			used[extension.ID] = true
+		}
+		extensions = append(extensions, extension)
+	}
+	return extensions, nil
+}
+
+// getMediaHeaderExtensions parses the extmap attributes of a media section,
+// invalid ones are ignored
+func getMediaHeaderExtensions(media *sdp.MediaDescription) []RTPHeaderExtensionParameters {
+	extensions := []RTPHeaderExtensionParameters{}
+	for _, attr := range media.Attributes {
+		if attr.Key != "extmap" {
+			continue
+		}
+
+		// a=extmap:<value>["/"<direction>] <URI> <extensionattributes>
+		fields := strings.Fields(attr.Value)
+		if len(fields) < 2 {
+			continue
+		}
+		id, err := strconv.Atoi(strings.Split(fields[0], "/")[0])
+		if err != nil {
+			continue
+		}
+		extensions = append(extensions, RTPHeaderExtensionParameters{URI: fields[1], ID: id})
+	}
+	return extensions
+}
+
+// sendFECParameters returns how the media of sender in the media section
+// with mid is protected, FEC is only sent when the codecs of the mechanism
+// enabled on the sender are negotiated. FlexFEC is sent on the stream with
+// fecSSRC, there is none when it is 0.
+func (pc *PeerConnection) sendFECParameters(mid string, sender *RTPSender, fecSSRC uint32) RTPFecParameters {
+	switch mechanism := sender.enabledFECMechanism(); mechanism {
+	case FECMechanismULPFEC:
+		redPayloadType, hasRED := pc.negotiatedCodecPayloadType(mid, RED)
+		ulpfecPayloadType, hasULPFEC := pc.negotiatedCodecPayloadType(mid, ULPFEC)
+		if hasRED && hasULPFEC {
+			return RTPFecParameters{
+				Mechanism:      mechanism,
+				PayloadType:    ulpfecPayloadType,
+				REDPayloadType: redPayloadType,
+			}
+		}
+	case FECMechanismFlexFEC:
+		if flexfecPayloadType, ok := pc.negotiatedCodecPayloadType(mid, FlexFEC); ok && fecSSRC != 0 {
+			return RTPFecParameters{
+				Mechanism:   mechanism,
+				SSRC:        fecSSRC,
+				PayloadType: flexfecPayloadType,
+			}
+		}
+	}
+	return RTPFecParameters{}
+}
+
+// negotiatedSimulcastRIDs returns the RIDs of the simulcast streams sent in
+// the media section with mid that the remote accepted to receive
+// https://tools.ietf.org/html/rfc8853#section-5.3
+func (pc *PeerConnection) negotiatedSimulcastRIDs(mid string) []string {
+	local, remote := pc.negotiatedMedia(mid)
+	if local == nil {
+		return nil
+	}
+
+	accepted := map[string]bool{}
+	for _, rid := range getMediaSimulcastRIDs(remote, ridDirectionRecv) {
+		accepted[rid] = true
+	}
+	rids := []string{}
+	for _, rid := range getMediaSimulcastRIDs(local, ridDirectionSend) {
+		if accepted[rid] {
+			rids = append(rids, rid)
+		}
+	}
+	return rids
+}
+
+// simulcastRIDsSDP returns the RIDs of the layers of simulcast of sender
+// that are signaled, they need the RTP stream id extension. An answer only
+// keeps the ones remoteMedia accepts to receive.
+func (pc *PeerConnection) simulcastRIDsSDP(sender *RTPSender, extensions []RTPHeaderExtensionParameters, remoteMedia *sdp.MediaDescription) []string {
+	rids := sender.getRIDs()
+	if _, ok := headerExtensionID(extensions, SDESRTPStreamIDURI); !ok || len(rids) == 0 || pc.configuration.SDPSemantics == SDPSemanticsPlanB {
+		return nil
+	} else if remoteMedia == nil {
+		return rids
+	}
+
+	accepted := map[string]bool{}
+	for _, rid := range getMediaSimulcastRIDs(remoteMedia, ridDirectionRecv) {
+		accepted[rid] = true
+	}
+	filtered := []string{}
+	for _, rid := range rids {
+		if accepted[rid] {
+			filtered = append(filtered, rid)
+		}
+	}
+	return filtered
+}
+
+// receiveFECParameters returns how the media received in the media section
+// with mid can be protected. FlexFEC is used when the remote signaled a
+// FlexFEC stream with fecSSRC, ULPFEC otherwise.
+func (pc *PeerConnection) receiveFECParameters(mid string, fecSSRC uint32) RTPFecParameters {
+	parameters := RTPFecParameters{}
+	redPayloadType, hasRED := pc.negotiatedCodecPayloadType(mid, RED)
+	if hasRED {
+		parameters.REDPayloadType = redPayloadType
+	}
+
+	if flexfecPayloadType, ok := pc.negotiatedCodecPayloadType(mid, FlexFEC); ok && fecSSRC != 0 {
+		parameters.Mechanism = FECMechanismFlexFEC
+		parameters.SSRC = fecSSRC
+		parameters.PayloadType = flexfecPayloadType
+	} else if ulpfecPayloadType, ok := pc.negotiatedCodecPayloadType(mid, ULPFEC); ok && hasRED {
+		parameters.Mechanism = FECMechanismULPFEC
+		parameters.PayloadType = ulpfecPayloadType
+	}
+	return parameters
+}
+
+// getMediaCodecs returns the codecs of a media section
+func getMediaCodecs(media *sdp.MediaDescription) []sdp.Codec {
+	section := &sdp.SessionDescription{MediaDescriptions: []*sdp.MediaDescription{media}}
+
+	codecs := []sdp.Codec{}
+	for _, format := range media.MediaName.Formats {
+		payloadType, err := strconv.Atoi(format)
+		if err != nil {
+			continue
+		}
+
+		codec, err := section.GetCodecForPayloadType(uint8(payloadType))
+		if err != nil {
+			continue
+		}
+		codecs = append(codecs, codec)
+	}
+	return codecs
 }
 
 func (pc *PeerConnection) addDataMediaSection(d *sdp.SessionDescription, midValue string, iceParams ICEParameters, candidates []ICECandidate, dtlsRole sdp.ConnectionRole) {
@@ -1744,6 +3560,14 @@ func (pc *PeerConnection) addDataMediaSection(d *sdp.SessionDescription, midValu
 		WithPropertyAttribute("sctpmap:5000 webrtc-datachannel 1024").
 		WithICECredentials(iceParams.UsernameFragment, iceParams.Password)
 
+	addICECandidates(media, candidates, pc.ICEGatheringState() == ICEGatheringStateComplete)
+
+	d.WithMedia(media)
+}
+
+// addICECandidates adds the local candidates to media, they are followed by
+// end-of-candidates once gathering is complete
+func addICECandidates(media *sdp.MediaDescription, candidates []ICECandidate, complete bool) {
 	for _, c := range candidates {
 		sdpCandidate := c.toSDP()
 		sdpCandidate.ExtensionAttributes = append(sdpCandidate.ExtensionAttributes, sdp.ICECandidateAttribute{Key: "generation", Value: "0"})
@@ -1752,9 +3576,9 @@ func (pc *PeerConnection) addDataMediaSection(d *sdp.SessionDescription, midValu
 		sdpCandidate.Component = 2
 		media.WithICECandidate(sdpCandidate)
 	}
-	media.WithPropertyAttribute("end-of-candidates")
-
-	d.WithMedia(media)
+	if complete {
+		media.WithPropertyAttribute("end-of-candidates")
+	}
 }
 
 // NewTrack Creates a new Track
@@ -1777,10 +3601,13 @@ func (pc *PeerConnection) newRTPTransceiver(
 ) *RTPTransceiver {
 
 	t := &RTPTransceiver{
-		Receiver:  receiver,
-		Sender:    sender,
-		Direction: direction,
-		kind:      kind,
+		Receiver:    receiver,
+		Sender:      sender,
+		Direction:   direction,
+		kind:        kind,
+		mediaEngine: pc.api.mediaEngine,
+
+		updateNegotiationNeeded: pc.updateNegotiationNeededFlag,
 	}
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
@@ -1793,7 +3620,7 @@ func (pc *PeerConnection) newRTPTransceiver(
 // into the stable state plus any local candidates that have been generated
 // by the ICEAgent since the offer or answer was created.
 func (pc *PeerConnection) CurrentLocalDescription() *SessionDescription {
-	return pc.currentLocalDescription
+	return pc.populateLocalCandidates(pc.currentLocalDescription)
 }
 
 // PendingLocalDescription represents a local description that is in the
@@ -1801,7 +3628,7 @@ func (pc *PeerConnection) CurrentLocalDescription() *SessionDescription {
 // generated by the ICEAgent since the offer or answer was created. If the
 // PeerConnection is in the stable state, the value is null.
 func (pc *PeerConnection) PendingLocalDescription() *SessionDescription {
-	return pc.pendingLocalDescription
+	return pc.populateLocalCandidates(pc.pendingLocalDescription)
 }
 
 // CurrentRemoteDescription represents the last remote description that was
@@ -1830,11 +3657,74 @@ func (pc *PeerConnection) SignalingState() SignalingState {
 // ICEGatheringState attribute returns the ICE gathering state of the
 // PeerConnection instance.
 func (pc *PeerConnection) ICEGatheringState() ICEGatheringState {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
 	return pc.iceGatheringState
 }
 
 // ConnectionState attribute returns the connection state of the
 // PeerConnection instance.
 func (pc *PeerConnection) ConnectionState() PeerConnectionState {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
 	return pc.connectionState
 }
+
+// closed tells if Close has been called on the PeerConnection
+func (pc *PeerConnection) closed() bool {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	return pc.isClosed
+}
+
+// GetStats return data providing statistics about the overall connection
+// https://w3c.github.io/webrtc-pc/#dom-rtcpeerconnection-getstats
+func (pc *PeerConnection) GetStats() StatsReport {
+	report := StatsReport{}
+
+	pc.mu.RLock()
+	isClosed := pc.isClosed
+	dataChannels := make([]*DataChannel, 0, len(pc.dataChannels))
+	for _, d := range pc.dataChannels {
+		dataChannels = append(dataChannels, d)
+	}
+	pcStats := PeerConnectionStats{
+		Timestamp:             statsTimestampFrom(time.Now()),
+		Type:                  StatsTypePeerConnection,
+		ID:                    pc.statsID,
+		DataChannelsRequested: pc.dataChannelsRequested,
+		DataChannelsAccepted:  pc.dataChannelsAccepted,
+	}
+	pc.mu.RUnlock()
+
+	transportStats := pc.dtlsTransport.collectStats(report)
+	pc.iceTransport.collectTransportStats(&transportStats)
+	// The agent can't be queried anymore once it is closed
+	if !isClosed {
+		transportStats.SelectedCandidatePairID = pc.iceTransport.collectStats(report, transportStats.ID)
+	}
+	report[transportStats.ID] = transportStats
+
+	for _, d := range dataChannels {
+		stats := d.collectStats(transportStats.ID)
+		if stats.State != DataChannelStateConnecting {
+			pcStats.DataChannelsOpened++
+		}
+		if stats.State == DataChannelStateClosed {
+			pcStats.DataChannelsClosed++
+		}
+		report[stats.ID] = stats
+	}
+	report[pcStats.ID] = pcStats
+
+	for _, t := range pc.GetTransceivers() {
+		if t.Sender != nil {
+			t.Sender.collectStats(report, transportStats.ID)
+		}
+		if t.Receiver != nil {
+			t.Receiver.collectStats(report, transportStats.ID)
+		}
+	}
+
+	return report
+}
@@ -464,3 +464,189 @@ func TestPeerConnection_satisfyTypeAndDirection(t *testing.T) {
 	}
 
 }
+
+func TestPeerConnection_Trickle(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	s := SettingEngine{}
+	s.SetTrickle(true)
+
+	api := NewAPI(WithSettingEngine(s))
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collect := func(pc *PeerConnection) (chan ICECandidateInit, chan struct{}) {
+		candidates := make(chan ICECandidateInit, 64)
+		done := make(chan struct{})
+		pc.OnICECandidate(func(c *ICECandidate) {
+			if c == nil {
+				close(done)
+				return
+			}
+			candidates <- c.ToJSON()
+		})
+		return candidates, done
+	}
+	offerCandidates, offerDone := collect(pcOffer)
+	answerCandidates, answerDone := collect(pcAnswer)
+
+	gatheringStates := make(chan ICEGatheringState, 8)
+	pcOffer.OnICEGatheringStateChange(func() {
+		gatheringStates <- pcOffer.ICEGatheringState()
+	})
+
+	connected := make(chan struct{})
+	pcAnswer.OnICEConnectionStateChange(func(s ICEConnectionState) {
+		if s == ICEConnectionStateConnected {
+			close(connected)
+		}
+	})
+
+	_, err = pcOffer.CreateDataChannel("initial_data_channel", nil)
+	assert.NoError(t, err)
+
+	offer, err := pcOffer.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.NotContains(t, offer.SDP, "a=candidate")
+	assert.NoError(t, pcOffer.SetLocalDescription(offer))
+	assert.NoError(t, pcAnswer.SetRemoteDescription(offer))
+
+	answer, err := pcAnswer.CreateAnswer(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, pcAnswer.SetLocalDescription(answer))
+	assert.NoError(t, pcOffer.SetRemoteDescription(answer))
+
+	<-offerDone
+	<-answerDone
+	assert.Equal(t, ICEGatheringStateComplete, pcOffer.ICEGatheringState())
+	for s := range gatheringStates {
+		if s == ICEGatheringStateComplete {
+			break
+		}
+	}
+
+	close(offerCandidates)
+	close(answerCandidates)
+	assert.NotEmpty(t, offerCandidates)
+	assert.NotEmpty(t, answerCandidates)
+	for c := range offerCandidates {
+		// The candidates are gathered for the bundled transport of the
+		// first media section
+		if assert.NotNil(t, c.SDPMid) && assert.NotNil(t, c.SDPMLineIndex) {
+			assert.Equal(t, "0", *c.SDPMid)
+			assert.Equal(t, uint16(0), *c.SDPMLineIndex)
+		}
+		assert.NoError(t, pcAnswer.AddICECandidate(c))
+	}
+	for c := range answerCandidates {
+		assert.NoError(t, pcOffer.AddICECandidate(c))
+	}
+
+	<-connected
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_TrickleDescriptions(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	s := SettingEngine{}
+	s.SetTrickle(true)
+	pc, err := NewAPI(WithSettingEngine(s)).NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	gathered := make(chan struct{})
+	pc.OnICECandidate(func(c *ICECandidate) {
+		if c == nil {
+			close(gathered)
+		}
+	})
+
+	_, err = pc.CreateDataChannel("initial_data_channel", nil)
+	assert.NoError(t, err)
+	offer, err := pc.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, pc.SetLocalDescription(offer))
+	<-gathered
+
+	// The candidates gathered after the offer was created are part of the
+	// local description
+	assert.NotContains(t, offer.SDP, "a=candidate")
+	assert.Contains(t, pc.PendingLocalDescription().SDP, "a=candidate")
+	assert.Contains(t, pc.LocalDescription().SDP, "a=end-of-candidates")
+
+	// Gathering may complete again after a restart
+	assert.NotPanics(t, func() { pc.onICEGathererStateChange(ICEGathererStateComplete) })
+	assert.Equal(t, ICEGatheringStateComplete, pc.ICEGatheringState())
+
+	assert.NoError(t, pc.Close())
+}
+
+func TestPeerConnection_TrickleDisabled(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	// Trickle ICE is opt-in, by default every candidate is gathered before
+	// the offer is created
+	pc, err := NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	_, err = pc.CreateDataChannel("initial_data_channel", nil)
+	assert.NoError(t, err)
+	offer, err := pc.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.Contains(t, offer.SDP, "a=candidate")
+	assert.Contains(t, offer.SDP, "a=end-of-candidates")
+
+	assert.NoError(t, pc.Close())
+}
+
+func TestPeerConnection_ConnectionStateChange(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	pcOffer, pcAnswer, err := newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, PeerConnectionStateNew, pcOffer.ConnectionState())
+
+	offerStates := make(chan PeerConnectionState, 10)
+	pcOffer.OnConnectionStateChange(func(state PeerConnectionState) {
+		offerStates <- state
+	})
+	answerConnected := make(chan struct{})
+	pcAnswer.OnConnectionStateChange(func(state PeerConnectionState) {
+		if state == PeerConnectionStateConnected {
+			close(answerConnected)
+		}
+	})
+
+	if _, err = pcOffer.CreateDataChannel("initial_data_channel", nil); err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	// Connected is only reported once DTLS is established
+	for state := range offerStates {
+		if state == PeerConnectionStateConnected {
+			break
+		}
+		assert.Contains(t, []PeerConnectionState{PeerConnectionStateConnecting, PeerConnectionStateConnected}, state)
+	}
+	assert.Equal(t, DTLSTransportStateConnected, pcOffer.dtlsTransport.State())
+	assert.Equal(t, PeerConnectionStateConnected, pcOffer.ConnectionState())
+	<-answerConnected
+
+	assert.NoError(t, pcOffer.Close())
+	assert.Equal(t, PeerConnectionStateClosed, pcOffer.ConnectionState())
+	assert.Equal(t, DTLSTransportStateClosed, pcOffer.dtlsTransport.State())
+	assert.Equal(t, PeerConnectionStateClosed, <-offerStates)
+
+	assert.NoError(t, pcAnswer.Close())
+}
@@ -21,8 +21,10 @@ type PeerConnection struct {
 	onSignalingStateChangeHandler    *js.Func
 	onDataChannelHandler             *js.Func
 	onICEConectionStateChangeHandler *js.Func
+	onConnectionStateChangeHandler   *js.Func
 	onICECandidateHandler            *js.Func
 	onICEGatheringStateChangeHandler *js.Func
+	onNegotiationNeededHandler       *js.Func
 
 	// A reference to the associated API state used by this connection
 	api *API
@@ -65,6 +67,21 @@ func (pc *PeerConnection) OnSignalingStateChange(f func(SignalingState)) {
 	pc.underlying.Set("onsignalingstatechange", onSignalingStateChangeHandler)
 }
 
+// OnNegotiationNeeded sets an event handler which is invoked when
+// a change has occurred which requires session negotiation
+func (pc *PeerConnection) OnNegotiationNeeded(f func()) {
+	if pc.onNegotiationNeededHandler != nil {
+		oldHandler := pc.onNegotiationNeededHandler
+		defer oldHandler.Release()
+	}
+	onNegotiationNeededHandler := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		go f()
+		return js.Undefined()
+	})
+	pc.onNegotiationNeededHandler = &onNegotiationNeededHandler
+	pc.underlying.Set("onnegotiationneeded", onNegotiationNeededHandler)
+}
+
 // OnDataChannel sets an event handler which is invoked when a data
 // channel message arrives from a remote peer.
 func (pc *PeerConnection) OnDataChannel(f func(*DataChannel)) {
@@ -110,6 +127,22 @@ func (pc *PeerConnection) OnICEConnectionStateChange(f func(ICEConnectionState))
 	pc.underlying.Set("oniceconnectionstatechange", onICEConectionStateChangeHandler)
 }
 
+// OnConnectionStateChange sets an event handler which is called
+// when the PeerConnectionState has changed
+func (pc *PeerConnection) OnConnectionStateChange(f func(PeerConnectionState)) {
+	if pc.onConnectionStateChangeHandler != nil {
+		oldHandler := pc.onConnectionStateChangeHandler
+		defer oldHandler.Release()
+	}
+	onConnectionStateChangeHandler := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		connectionState := newPeerConnectionState(pc.underlying.Get("connectionState").String())
+		go f(connectionState)
+		return js.Undefined()
+	})
+	pc.onConnectionStateChangeHandler = &onConnectionStateChangeHandler
+	pc.underlying.Set("onconnectionstatechange", onConnectionStateChangeHandler)
+}
+
 func (pc *PeerConnection) checkConfiguration(configuration Configuration) error {
 	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-setconfiguration (step #2)
 	if pc.ConnectionState() == PeerConnectionStateClosed {
@@ -286,6 +319,12 @@ func (pc *PeerConnection) ICEConnectionState() ICEConnectionState {
 	return newICEConnectionState(pc.underlying.Get("iceConnectionState").String())
 }
 
+// RestartICE requests an ICE restart, the next offer created with CreateOffer
+// has new ICE credentials. The restart is signaled through OnNegotiationNeeded.
+func (pc *PeerConnection) RestartICE() {
+	pc.underlying.Call("restartIce")
+}
+
 // OnICECandidate sets an event handler which is invoked when a new ICE
 // candidate is found.
 func (pc *PeerConnection) OnICECandidate(f func(candidate *ICECandidate)) {
@@ -391,12 +430,18 @@ func (pc *PeerConnection) Close() (err error) {
 	if pc.onICEConectionStateChangeHandler != nil {
 		pc.onICEConectionStateChangeHandler.Release()
 	}
+	if pc.onConnectionStateChangeHandler != nil {
+		pc.onConnectionStateChangeHandler.Release()
+	}
 	if pc.onICECandidateHandler != nil {
 		pc.onICECandidateHandler.Release()
 	}
 	if pc.onICEGatheringStateChangeHandler != nil {
 		pc.onICEGatheringStateChangeHandler.Release()
 	}
+	if pc.onNegotiationNeededHandler != nil {
+		pc.onNegotiationNeededHandler.Release()
+	}
 
 	return nil
 }
@@ -595,7 +640,7 @@ func offerOptionsToValue(offerOptions *OfferOptions) js.Value {
 	}
 	return js.ValueOf(map[string]interface{}{
 		"iceRestart":             offerOptions.ICERestart,
-		"voiceActivityDetection": offerOptions.VoiceActivityDetection,
+		"voiceActivityDetection": boolPointerToValue(offerOptions.VoiceActivityDetection),
 	})
 }
 
@@ -604,7 +649,7 @@ func answerOptionsToValue(answerOptions *AnswerOptions) js.Value {
 		return js.Undefined()
 	}
 	return js.ValueOf(map[string]interface{}{
-		"voiceActivityDetection": answerOptions.VoiceActivityDetection,
+		"voiceActivityDetection": boolPointerToValue(answerOptions.VoiceActivityDetection),
 	})
 }
 
@@ -8,14 +8,18 @@ import (
 	"io"
 	"math/rand"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
 	"github.com/pion/sdp/v2"
 	"github.com/pion/transport/test"
+	"github.com/pion/webrtc/v2/pkg/interceptor"
 	"github.com/pion/webrtc/v2/pkg/media"
+	"github.com/stretchr/testify/assert"
 )
 
 /*
@@ -495,4 +499,1544 @@ func TestOfferRejectionMissingCodec(t *testing.T) {
 	if got, want := videoDesc.MediaName.Formats, []string{"0"}; !reflect.DeepEqual(got, want) {
 		t.Fatalf("rejecting unknown codec: sdp m=%s, want trailing 0", *videoDesc.MediaName.String())
 	}
+
+	// The rejected section is not part of the BUNDLE group
+	group, ok := sdes.Attribute(sdp.AttrKeyGroup)
+	if !ok {
+		t.Fatal("answer has no BUNDLE group")
+	}
+	for _, mid := range strings.Fields(group)[1:] {
+		if transceiver := pc.transceiverForMid(mid); transceiver != nil && transceiver.kind == RTPCodecTypeVideo {
+			t.Fatalf("rejected section %s is bundled: %s", mid, group)
+		}
+	}
+}
+
+func TestPeerConnection_AnswerPayloadTypes(t *testing.T) {
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+	api.mediaEngine.RegisterDefaultRetransmission()
+
+	pcOffer, err := api.NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+	pcAnswer, err := api.NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	_, err = pcOffer.AddTransceiverFromKind(RTPCodecTypeVideo)
+	assert.NoError(t, err)
+
+	offer, err := pcOffer.CreateOffer(nil)
+	assert.NoError(t, err)
+
+	// The offerer signals VP8 and its RTX codec with other payload types
+	offer.SDP = strings.NewReplacer(
+		fmt.Sprintf(":%d ", DefaultPayloadTypeVP8), ":100 ",
+		fmt.Sprintf("apt=%d", DefaultPayloadTypeVP8), "apt=100",
+	).Replace(offer.SDP)
+	offer.SDP = strings.Replace(offer.SDP, fmt.Sprintf(" %d ", DefaultPayloadTypeVP8), " 100 ", 1)
+	assert.NoError(t, pcAnswer.SetRemoteDescription(offer))
+
+	answer, err := pcAnswer.CreateAnswer(nil)
+	assert.NoError(t, err)
+	assert.Contains(t, answer.SDP, "a=rtpmap:100 VP8/90000")
+	assert.Contains(t, answer.SDP, "a=fmtp:97 apt=100")
+	assert.NotContains(t, answer.SDP, fmt.Sprintf("a=rtpmap:%d VP8/90000", DefaultPayloadTypeVP8))
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_Media_NegotiatedPayloadTypes(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	// The offerer registers VP8 with another payload type than the answerer
+	const offerPayloadTypeVP8 = 100
+	offerAPI := NewAPI()
+	offerAPI.mediaEngine.RegisterCodec(NewRTPVP8Codec(offerPayloadTypeVP8, 90000))
+	pcOffer, err := offerAPI.NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	answerAPI := NewAPI()
+	answerAPI.mediaEngine.RegisterDefaultCodecs()
+	pcAnswer, err := answerAPI.NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	offerTrack, err := pcOffer.NewTrack(offerPayloadTypeVP8, rand.Uint32(), "video", "offer")
+	assert.NoError(t, err)
+	_, err = pcOffer.AddTransceiverFromTrack(offerTrack, RtpTransceiverInit{Direction: RTPTransceiverDirectionSendrecv})
+	assert.NoError(t, err)
+
+	answerTrack, err := pcAnswer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "answer")
+	assert.NoError(t, err)
+	_, err = pcAnswer.AddTransceiverFromTrack(answerTrack, RtpTransceiverInit{Direction: RTPTransceiverDirectionSendrecv})
+	assert.NoError(t, err)
+
+	// Both sides send VP8 with the payload type of the offer, which the
+	// answer echoes
+	var wg sync.WaitGroup
+	wg.Add(2)
+	onTrack := func(track *Track, receiver *RTPReceiver) {
+		defer wg.Done()
+		pkt, err := track.ReadRTP()
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, uint8(offerPayloadTypeVP8), track.PayloadType())
+		assert.Equal(t, uint8(offerPayloadTypeVP8), pkt.PayloadType)
+		assert.Equal(t, VP8, track.Codec().Name)
+	}
+	pcOffer.OnTrack(onTrack)
+	pcAnswer.OnTrack(onTrack)
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+	assert.Contains(t, pcAnswer.LocalDescription().SDP, fmt.Sprintf("a=rtpmap:%d VP8/90000", offerPayloadTypeVP8))
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	func() {
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				assert.NoError(t, offerTrack.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}))
+				assert.NoError(t, answerTrack.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_AddTransceiver(t *testing.T) {
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+
+	pc, err := api.NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	for _, direction := range []RTPTransceiverDirection{
+		RTPTransceiverDirectionSendrecv,
+		RTPTransceiverDirectionSendonly,
+		RTPTransceiverDirectionRecvonly,
+		RTPTransceiverDirectionInactive,
+	} {
+		transceiver, err := pc.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: direction})
+		assert.NoError(t, err)
+		assert.Equal(t, direction, transceiver.Direction)
+		assert.NotNil(t, transceiver.Receiver)
+		assert.NotNil(t, transceiver.Sender)
+		assert.Nil(t, transceiver.Sender.Track())
+	}
+
+	_, err = pc.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{}, RtpTransceiverInit{})
+	assert.Error(t, err)
+
+	// AddTrack sends on the first transceiver that has no track
+	track, err := pc.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	assert.NoError(t, err)
+	sender, err := pc.AddTrack(track)
+	assert.NoError(t, err)
+	assert.Equal(t, pc.GetTransceivers()[0].Sender, sender)
+	assert.Equal(t, track, sender.Track())
+
+	// A transceiver whose track was removed is reused with a new sender
+	assert.NoError(t, pc.RemoveTrack(sender))
+	reused, err := pc.AddTrack(track)
+	assert.NoError(t, err)
+	assert.NotEqual(t, sender, reused)
+	assert.Equal(t, pc.GetTransceivers()[0].Sender, reused)
+	assert.Equal(t, RTPTransceiverDirectionSendrecv, pc.GetTransceivers()[0].Direction)
+
+	// The deprecated AddTransceiver sends a placeholder track on sendrecv
+	// transceivers and creates recvonly ones without a sender
+	placeholder, err := pc.AddTransceiver(RTPCodecTypeVideo)
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(DefaultPayloadTypeVP8), placeholder.Sender.Track().PayloadType())
+	recvonly, err := pc.AddTransceiver(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+	assert.NoError(t, err)
+	assert.Nil(t, recvonly.Sender)
+
+	audioTrack, err := pc.NewTrack(DefaultPayloadTypeOpus, rand.Uint32(), "audio", "pion")
+	assert.NoError(t, err)
+	transceiver, err := pc.AddTransceiverFromTrack(audioTrack, RtpTransceiverInit{
+		Direction: RTPTransceiverDirectionSendonly,
+		StreamIDs: []string{"stream-a", "stream-b"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, audioTrack, transceiver.Sender.Track())
+	assert.NotNil(t, transceiver.Receiver)
+
+	offer, err := pc.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.Contains(t, offer.SDP, "a=msid:stream-a audio")
+	assert.Contains(t, offer.SDP, "a=msid:stream-b audio")
+	assert.Contains(t, offer.SDP, "a=msid:pion video")
+
+	assert.NoError(t, pc.Close())
+}
+
+func TestPeerConnection_SetCodecPreferences(t *testing.T) {
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+
+	pcOffer, pcAnswer, err := api.newPair()
+	assert.NoError(t, err)
+
+	h264 := NewRTPH264Codec(DefaultPayloadTypeH264, 90000).RTPCodecCapability
+	vp8 := NewRTPVP8Codec(DefaultPayloadTypeVP8, 90000).RTPCodecCapability
+	vp9 := NewRTPVP9Codec(DefaultPayloadTypeVP9, 90000).RTPCodecCapability
+
+	h264Transceiver, err := pcOffer.AddTransceiverFromKind(RTPCodecTypeVideo)
+	assert.NoError(t, err)
+	assert.NoError(t, h264Transceiver.SetCodecPreferences([]RTPCodecCapability{h264}))
+
+	vp8Transceiver, err := pcOffer.AddTransceiverFromKind(RTPCodecTypeVideo)
+	assert.NoError(t, err)
+	assert.NoError(t, vp8Transceiver.SetCodecPreferences([]RTPCodecCapability{vp8, vp9}))
+
+	// Codecs have to be registered with the MediaEngine
+	g711 := RTPCodecCapability{MimeType: "audio/PCMU", ClockRate: 8000}
+	assert.Error(t, vp8Transceiver.SetCodecPreferences([]RTPCodecCapability{g711}))
+
+	formats := func(desc SessionDescription) [][]string {
+		result := [][]string{}
+		for _, media := range desc.parsed.MediaDescriptions {
+			if media.MediaName.Media == "video" {
+				result = append(result, media.MediaName.Formats)
+			}
+		}
+		return result
+	}
+
+	offer, err := pcOffer.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"102"}, {"96", "98"}}, formats(offer))
+
+	// The answer only contains offered codecs, ordered by local preference
+	for i := 0; i < 2; i++ {
+		_, err = pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, pcAnswer.SetRemoteDescription(offer))
+	for _, transceiver := range pcAnswer.GetTransceivers() {
+		if transceiver.Mid == vp8Transceiver.Mid {
+			assert.NoError(t, transceiver.SetCodecPreferences([]RTPCodecCapability{vp9, vp8}))
+		}
+	}
+
+	answer, err := pcAnswer.CreateAnswer(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"102"}, {"98", "96"}}, formats(answer))
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_Media_SenderReports(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	s := SettingEngine{}
+	s.SetSenderReportInterval(50 * time.Millisecond)
+	m := MediaEngine{}
+	m.RegisterDefaultCodecs()
+	api := NewAPI(WithMediaEngine(m), WithSettingEngine(s))
+
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+	assert.NoError(t, err)
+
+	vp8Track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	assert.NoError(t, err)
+	_, err = pcOffer.AddTrack(vp8Track)
+	assert.NoError(t, err)
+
+	senderReport := make(chan *rtcp.SenderReport, 1)
+	pcAnswer.OnTrack(func(track *Track, receiver *RTPReceiver) {
+		for {
+			pkts, err := receiver.ReadRTCP()
+			if err != nil {
+				return
+			}
+
+			for _, pkt := range pkts {
+				if sr, ok := pkt.(*rtcp.SenderReport); ok {
+					select {
+					case senderReport <- sr:
+					default:
+					}
+				}
+			}
+		}
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	var sr *rtcp.SenderReport
+	func() {
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				assert.NoError(t, vp8Track.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}))
+			case sr = <-senderReport:
+				return
+			}
+		}
+	}()
+
+	assert.Equal(t, vp8Track.SSRC(), sr.SSRC)
+	assert.NotZero(t, sr.PacketCount)
+	assert.NotZero(t, sr.OctetCount)
+	assert.InDelta(t, ntpTime(time.Now())>>32, sr.NTPTime>>32, 2)
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_Media_ReceiverReports(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	s := SettingEngine{}
+	s.SetSenderReportInterval(50 * time.Millisecond)
+	s.SetReceiverReportInterval(50 * time.Millisecond)
+	m := MediaEngine{}
+	m.RegisterDefaultCodecs()
+	api := NewAPI(WithMediaEngine(m), WithSettingEngine(s))
+
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+	assert.NoError(t, err)
+
+	vp8Track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	assert.NoError(t, err)
+	sender, err := pcOffer.AddTrack(vp8Track)
+	assert.NoError(t, err)
+
+	pcAnswer.OnTrack(func(track *Track, receiver *RTPReceiver) {
+		for {
+			if _, err := track.ReadRTP(); err != nil {
+				return
+			}
+		}
+	})
+
+	// Wait for a Receiver Report that was sent after a Sender Report
+	receiverReport := make(chan *rtcp.ReceiverReport, 1)
+	go func() {
+		for {
+			pkts, err := sender.ReadRTCP()
+			if err != nil {
+				return
+			}
+
+			for _, pkt := range pkts {
+				if rr, ok := pkt.(*rtcp.ReceiverReport); ok && len(rr.Reports) == 1 && rr.Reports[0].LastSenderReport != 0 {
+					select {
+					case receiverReport <- rr:
+					default:
+					}
+				}
+			}
+		}
+	}()
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	var rr *rtcp.ReceiverReport
+	func() {
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				assert.NoError(t, vp8Track.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}))
+			case rr = <-receiverReport:
+				return
+			}
+		}
+	}()
+
+	report := rr.Reports[0]
+	assert.Equal(t, vp8Track.SSRC(), report.SSRC)
+	assert.Zero(t, report.TotalLost)
+	assert.NotZero(t, report.LastSequenceNumber)
+
+	// The round trip time on loopback may be below the resolution of the
+	// report timestamps
+	remoteInbound := findStats(sender.GetStats(), StatsTypeRemoteInboundRTP)
+	if assert.Len(t, remoteInbound, 1) {
+		stats := remoteInbound[0].(RemoteInboundRTPStreamStats)
+		assert.Zero(t, stats.PacketsLost)
+		assert.True(t, stats.RoundTripTime >= 0 && stats.RoundTripTime < 1)
+	}
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_Media_Retransmission(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+	assert.NoError(t, err)
+
+	vp8Track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	assert.NoError(t, err)
+	sender, err := pcOffer.AddTrack(vp8Track)
+	assert.NoError(t, err)
+	assert.Error(t, sender.EnableRetransmission(0))
+	assert.NoError(t, sender.EnableRetransmission(128))
+
+	offer, err := pcOffer.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.Contains(t, offer.SDP, fmt.Sprintf("a=rtcp-fb:%d nack\r\n", DefaultPayloadTypeVP8))
+
+	retransmitted := make(chan *RTPReceiver, 1)
+	pcAnswer.OnTrack(func(track *Track, receiver *RTPReceiver) {
+		first, err := track.ReadRTP()
+		if err != nil {
+			return
+		}
+
+		assert.NoError(t, pcAnswer.WriteRTCP([]rtcp.Packet{&rtcp.TransportLayerNack{
+			MediaSSRC: track.SSRC(),
+			Nacks:     []rtcp.NackPair{{PacketID: first.SequenceNumber}},
+		}}))
+
+		for {
+			pkt, err := track.ReadRTP()
+			if err != nil {
+				return
+			}
+			if pkt.SequenceNumber == first.SequenceNumber {
+				assert.Equal(t, first.Payload, pkt.Payload)
+				retransmitted <- receiver
+				return
+			}
+		}
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	func() {
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				assert.NoError(t, vp8Track.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}))
+			case <-retransmitted:
+				return
+			}
+		}
+	}()
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_Media_NACKGeneration(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+	api.mediaEngine.RegisterDefaultRetransmission()
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+	assert.NoError(t, err)
+
+	vp8Track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	assert.NoError(t, err)
+	sender, err := pcOffer.AddTrack(vp8Track)
+	assert.NoError(t, err)
+
+	pcAnswer.OnTrack(func(track *Track, receiver *RTPReceiver) {
+		for {
+			if _, err := track.ReadRTP(); err != nil {
+				return
+			}
+		}
+	})
+
+	// One sequence number is skipped, as if the packet was lost
+	const lost = 101
+	nack := make(chan *rtcp.TransportLayerNack, 1)
+	go func() {
+		for {
+			pkts, err := sender.ReadRTCP()
+			if err != nil {
+				return
+			}
+
+			for _, pkt := range pkts {
+				if p, ok := pkt.(*rtcp.TransportLayerNack); ok {
+					select {
+					case nack <- p:
+					default:
+					}
+				}
+			}
+		}
+	}()
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	var received *rtcp.TransportLayerNack
+	func() {
+		seq := uint16(0)
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				if seq == lost {
+					seq++
+				}
+				assert.NoError(t, vp8Track.WriteRTP(&rtp.Packet{
+					Header: rtp.Header{
+						Version:        2,
+						SequenceNumber: seq,
+						PayloadType:    DefaultPayloadTypeVP8,
+						SSRC:           vp8Track.SSRC(),
+					},
+					Payload: []byte{0x00},
+				}))
+				seq++
+			case received = <-nack:
+				return
+			}
+		}
+	}()
+
+	assert.Equal(t, vp8Track.SSRC(), received.MediaSSRC)
+	assert.Equal(t, []uint16{lost}, received.Nacks[0].PacketList())
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_Media_RTX(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+	api.mediaEngine.RegisterDefaultRetransmission()
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+	assert.NoError(t, err)
+
+	vp8Track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	assert.NoError(t, err)
+	sender, err := pcOffer.AddTrack(vp8Track)
+	assert.NoError(t, err)
+	assert.NoError(t, sender.EnableRetransmission(128))
+
+	offer, err := pcOffer.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.Contains(t, offer.SDP, fmt.Sprintf("a=rtpmap:%d rtx/90000\r\na=fmtp:%d apt=%d\r\n", DefaultPayloadTypeVP8RTX, DefaultPayloadTypeVP8RTX, DefaultPayloadTypeVP8))
+	fidGroup := fmt.Sprintf("a=ssrc-group:FID %d %d\r\n", vp8Track.SSRC(), sender.encodings[0].rtxSSRC)
+	assert.Contains(t, offer.SDP, fidGroup)
+
+	retransmitted := make(chan *RTPReceiver, 1)
+	pcAnswer.OnTrack(func(track *Track, receiver *RTPReceiver) {
+		first, err := track.ReadRTP()
+		if err != nil {
+			return
+		}
+
+		assert.NoError(t, pcAnswer.WriteRTCP([]rtcp.Packet{&rtcp.TransportLayerNack{
+			MediaSSRC: track.SSRC(),
+			Nacks:     []rtcp.NackPair{{PacketID: first.SequenceNumber}},
+		}}))
+
+		for {
+			pkt, err := track.ReadRTP()
+			if err != nil {
+				return
+			}
+			if pkt.SequenceNumber == first.SequenceNumber {
+				// The RTX packet is restored to the original one
+				assert.Equal(t, first.SSRC, pkt.SSRC)
+				assert.Equal(t, first.PayloadType, pkt.PayloadType)
+				assert.Equal(t, first.Payload, pkt.Payload)
+				retransmitted <- receiver
+				return
+			}
+		}
+	})
+
+	gathered := make(chan struct{})
+	pcOffer.OnICECandidate(func(c *ICECandidate) {
+		if c == nil {
+			close(gathered)
+		}
+	})
+	assert.NoError(t, pcOffer.SetLocalDescription(offer))
+	<-gathered
+
+	// The group follows the SSRCs it groups, like Firefox sends it
+	offer = *pcOffer.PendingLocalDescription()
+	offer.SDP = strings.Replace(offer.SDP, fidGroup, "", 1)
+	offer.SDP = strings.Replace(offer.SDP, "a=sendonly\r\n", "a=sendonly\r\n"+fidGroup, 1)
+	assert.NoError(t, pcAnswer.SetRemoteDescription(offer))
+	answer, err := pcAnswer.CreateAnswer(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, pcAnswer.SetLocalDescription(answer))
+	assert.NoError(t, pcOffer.SetRemoteDescription(answer))
+
+	var receiver *RTPReceiver
+	func() {
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				assert.NoError(t, vp8Track.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}))
+			case receiver = <-retransmitted:
+				return
+			}
+		}
+	}()
+
+	// The retransmission was sent on the RTX stream
+	sender.mu.RLock()
+	assert.Equal(t, RTPRtxParameters{SSRC: sender.encodings[0].rtxSSRC, PayloadType: DefaultPayloadTypeVP8RTX}, sender.encodings[0].rtx)
+	sender.mu.RUnlock()
+
+	// The retransmission is counted apart from the packets of the stream,
+	// which lost none
+	stats, ok := receiver.GetStats()[fmt.Sprintf("InboundRTPStream-%d", vp8Track.SSRC())].(InboundRTPStreamStats)
+	if assert.True(t, ok) {
+		assert.Equal(t, uint32(1), stats.RetransmittedPacketsReceived)
+		assert.NotZero(t, stats.RetransmittedBytesReceived)
+		assert.Equal(t, int32(0), stats.PacketsLost)
+	}
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_RTXWithoutAssociatedCodec(t *testing.T) {
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+	api.mediaEngine.RegisterDefaultRetransmission()
+	pc, err := api.NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	transceiver, err := pc.AddTransceiverFromKind(RTPCodecTypeVideo)
+	assert.NoError(t, err)
+
+	// The RTX codec of VP8 is left out with VP8
+	rtx := NewRTPRTXCodec(DefaultPayloadTypeVP8RTX, 90000, DefaultPayloadTypeVP8)
+	h264 := NewRTPH264Codec(DefaultPayloadTypeH264, 90000)
+	assert.NoError(t, transceiver.SetCodecPreferences([]RTPCodecCapability{rtx.RTPCodecCapability, h264.RTPCodecCapability}))
+
+	offer, err := pc.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.NotContains(t, offer.SDP, "rtx/90000")
+
+	assert.NoError(t, pc.Close())
+}
+
+func TestPeerConnection_Media_FEC(t *testing.T) {
+	for _, mechanism := range []FECMechanism{FECMechanismULPFEC, FECMechanismFlexFEC} {
+		mechanism := mechanism
+		t.Run(mechanism.String(), func(t *testing.T) {
+			lim := test.TimeOut(time.Second * 30)
+			defer lim.Stop()
+
+			api := NewAPI()
+			api.mediaEngine.RegisterDefaultCodecs()
+			api.mediaEngine.RegisterDefaultFEC()
+			pcOffer, pcAnswer, err := api.newPair()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+			assert.NoError(t, err)
+
+			vp8Track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+			assert.NoError(t, err)
+			sender, err := pcOffer.AddTrack(vp8Track)
+			assert.NoError(t, err)
+			assert.Error(t, sender.EnableFEC(FECMechanism(Unknown), 50))
+			assert.Error(t, sender.EnableFEC(mechanism, 0))
+			assert.Error(t, sender.EnableFEC(mechanism, 101))
+			assert.NoError(t, sender.EnableFEC(mechanism, 100))
+
+			offer, err := pcOffer.CreateOffer(nil)
+			assert.NoError(t, err)
+			assert.Contains(t, offer.SDP, fmt.Sprintf("a=rtpmap:%d red/90000\r\n", DefaultPayloadTypeRED))
+			assert.Contains(t, offer.SDP, fmt.Sprintf("a=rtpmap:%d ulpfec/90000\r\n", DefaultPayloadTypeULPFEC))
+			assert.Contains(t, offer.SDP, fmt.Sprintf("a=rtpmap:%d flexfec-03/90000\r\na=fmtp:%d repair-window=10000000\r\n", DefaultPayloadTypeFlexFEC, DefaultPayloadTypeFlexFEC))
+			fecGroup := fmt.Sprintf("a=ssrc-group:FEC-FR %d %d\r\n", vp8Track.SSRC(), sender.encodings[0].fecSSRC)
+			if mechanism == FECMechanismFlexFEC {
+				assert.Contains(t, offer.SDP, fecGroup)
+			} else {
+				assert.NotContains(t, offer.SDP, fecGroup)
+			}
+
+			received := make(chan struct{})
+			pcAnswer.OnTrack(func(track *Track, receiver *RTPReceiver) {
+				receiver.mu.RLock()
+				fec := receiver.tracks[0].fec
+				receiver.mu.RUnlock()
+				assert.Equal(t, mechanism, fec.Mechanism)
+				assert.Equal(t, uint8(DefaultPayloadTypeRED), fec.REDPayloadType)
+
+				// Each media packet is followed by an FEC packet, the
+				// ULPFEC packets take sequence numbers of the track
+				var last *rtp.Packet
+				for i := 0; i < 5; i++ {
+					pkt, err := track.ReadRTP()
+					if err != nil {
+						return
+					}
+					assert.Equal(t, uint8(DefaultPayloadTypeVP8), pkt.PayloadType)
+					if last != nil && mechanism == FECMechanismULPFEC {
+						assert.Equal(t, last.SequenceNumber+2, pkt.SequenceNumber)
+					} else if last != nil {
+						assert.Equal(t, last.SequenceNumber+1, pkt.SequenceNumber)
+					}
+					last = pkt
+				}
+				close(received)
+			})
+
+			assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+			func() {
+				for {
+					select {
+					case <-time.After(20 * time.Millisecond):
+						assert.NoError(t, vp8Track.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}))
+					case <-received:
+						return
+					}
+				}
+			}()
+
+			sender.mu.RLock()
+			fec := sender.encodings[0].fec
+			sender.mu.RUnlock()
+			if assert.NotNil(t, fec) {
+				expected := RTPFecParameters{Mechanism: mechanism, PayloadType: DefaultPayloadTypeFlexFEC, SSRC: sender.encodings[0].fecSSRC}
+				if mechanism == FECMechanismULPFEC {
+					expected = RTPFecParameters{Mechanism: mechanism, PayloadType: DefaultPayloadTypeULPFEC, REDPayloadType: DefaultPayloadTypeRED}
+				}
+				assert.Equal(t, expected, fec.parameters)
+			}
+
+			assert.NoError(t, pcOffer.Close())
+			assert.NoError(t, pcAnswer.Close())
+		})
+	}
+}
+
+// dropFirstInterceptor drops the first packet sent on the stream with ssrc
+type dropFirstInterceptor struct {
+	interceptor.NoOp
+
+	ssrc    uint32
+	mu      sync.Mutex
+	dropped *rtp.Header
+}
+
+func (i *dropFirstInterceptor) BindLocalStream(info *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	if info.SSRC != i.ssrc {
+		return writer
+	}
+
+	return interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+		i.mu.Lock()
+		defer i.mu.Unlock()
+		if i.dropped == nil {
+			dropped := *header
+			i.dropped = &dropped
+			return header.MarshalSize() + len(payload), nil
+		}
+		return writer.Write(header, payload, attributes)
+	})
+}
+
+func TestPeerConnection_Media_FECFirstPacket(t *testing.T) {
+	for _, mechanism := range []FECMechanism{FECMechanismULPFEC, FECMechanismFlexFEC} {
+		mechanism := mechanism
+		t.Run(mechanism.String(), func(t *testing.T) {
+			lim := test.TimeOut(time.Second * 30)
+			defer lim.Stop()
+
+			ssrc := rand.Uint32()
+			drop := &dropFirstInterceptor{ssrc: ssrc}
+			registry := interceptor.Registry{}
+			registry.Add(func() (interceptor.Interceptor, error) {
+				return drop, nil
+			})
+
+			api := NewAPI(WithInterceptorRegistry(registry))
+			api.mediaEngine.RegisterDefaultCodecs()
+			api.mediaEngine.RegisterDefaultFEC()
+			pcOffer, pcAnswer, err := api.newPair()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+			assert.NoError(t, err)
+
+			vp8Track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, ssrc, "video", "pion")
+			assert.NoError(t, err)
+			sender, err := pcOffer.AddTrack(vp8Track)
+			assert.NoError(t, err)
+			assert.NoError(t, sender.EnableFEC(mechanism, 100))
+
+			// The first media packet is lost, it is recovered from the FEC
+			// packet that protects it and the payload type of the track is
+			// learned from it
+			firstRead := make(chan *rtp.Packet, 1)
+			pcAnswer.OnTrack(func(track *Track, receiver *RTPReceiver) {
+				assert.Equal(t, uint8(DefaultPayloadTypeVP8), track.PayloadType())
+				if pkt, err := track.ReadRTP(); err == nil {
+					firstRead <- pkt
+				}
+			})
+
+			assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+			var first *rtp.Packet
+			func() {
+				for {
+					select {
+					case <-time.After(20 * time.Millisecond):
+						assert.NoError(t, vp8Track.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}))
+					case first = <-firstRead:
+						return
+					}
+				}
+			}()
+
+			// The recovered packet was read to determine the payload type,
+			// the application reads the media packet sent after it. The
+			// ULPFEC packet takes the sequence number in between.
+			next := uint16(1)
+			if mechanism == FECMechanismULPFEC {
+				next = 2
+			}
+			drop.mu.Lock()
+			if assert.NotNil(t, drop.dropped) {
+				assert.Equal(t, drop.dropped.SequenceNumber+next, first.SequenceNumber)
+			}
+			drop.mu.Unlock()
+			assert.Equal(t, uint8(DefaultPayloadTypeVP8), first.PayloadType)
+
+			assert.NoError(t, pcOffer.Close())
+			assert.NoError(t, pcAnswer.Close())
+		})
+	}
+}
+
+// testInterceptor rewrites the last byte of every sent payload and records
+// the streams it is bound to
+type testInterceptor struct {
+	interceptor.NoOp
+
+	mu                          sync.Mutex
+	localStreams, remoteStreams []*interceptor.StreamInfo
+	unbound                     int
+	rtcpRead, rtcpWritten       int
+}
+
+func (i *testInterceptor) BindRTCPReader(reader interceptor.RTCPReader) interceptor.RTCPReader {
+	return interceptor.RTCPReaderFunc(func(b []byte, attributes interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, attributes, err := reader.Read(b, attributes)
+		if err == nil {
+			i.mu.Lock()
+			i.rtcpRead++
+			i.mu.Unlock()
+		}
+		return n, attributes, err
+	})
+}
+
+func (i *testInterceptor) BindRTCPWriter(writer interceptor.RTCPWriter) interceptor.RTCPWriter {
+	return interceptor.RTCPWriterFunc(func(pkts []rtcp.Packet, attributes interceptor.Attributes) (int, error) {
+		i.mu.Lock()
+		i.rtcpWritten++
+		i.mu.Unlock()
+		return writer.Write(pkts, attributes)
+	})
+}
+
+func (i *testInterceptor) BindLocalStream(info *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	i.mu.Lock()
+	i.localStreams = append(i.localStreams, info)
+	i.mu.Unlock()
+
+	return interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+		rewritten := append([]byte{}, payload...)
+		rewritten[len(rewritten)-1] = 0xAA
+		return writer.Write(header, rewritten, attributes)
+	})
+}
+
+func (i *testInterceptor) UnbindLocalStream(_ *interceptor.StreamInfo) {
+	i.mu.Lock()
+	i.unbound++
+	i.mu.Unlock()
+}
+
+func (i *testInterceptor) BindRemoteStream(info *interceptor.StreamInfo, reader interceptor.RTPReader) interceptor.RTPReader {
+	i.mu.Lock()
+	i.remoteStreams = append(i.remoteStreams, info)
+	i.mu.Unlock()
+	return reader
+}
+
+func (i *testInterceptor) UnbindRemoteStream(_ *interceptor.StreamInfo) {
+	i.mu.Lock()
+	i.unbound++
+	i.mu.Unlock()
+}
+
+func TestPeerConnection_Media_Interceptor(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	interceptors := []*testInterceptor{}
+	registry := interceptor.Registry{}
+	registry.Add(func() (interceptor.Interceptor, error) {
+		i := &testInterceptor{}
+		interceptors = append(interceptors, i)
+		return i, nil
+	})
+
+	api := NewAPI(WithInterceptorRegistry(registry))
+	api.mediaEngine.RegisterDefaultCodecs()
+	api.mediaEngine.RegisterDefaultRetransmission()
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(interceptors))
+	offerInterceptor, answerInterceptor := interceptors[0], interceptors[1]
+
+	_, err = pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+	assert.NoError(t, err)
+
+	vp8Track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	assert.NoError(t, err)
+	sender, err := pcOffer.AddTrack(vp8Track)
+	assert.NoError(t, err)
+
+	received := make(chan struct{})
+	pcAnswer.OnTrack(func(track *Track, receiver *RTPReceiver) {
+		pkt, err := track.ReadRTP()
+		if err != nil {
+			return
+		}
+		assert.Equal(t, byte(0xAA), pkt.Payload[len(pkt.Payload)-1])
+
+		assert.NoError(t, pcAnswer.WriteRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: track.SSRC()}}))
+		if _, err := sender.ReadRTCP(); err == nil {
+			close(received)
+		}
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	func() {
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				assert.NoError(t, vp8Track.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}))
+			case <-received:
+				return
+			}
+		}
+	}()
+
+	offerInterceptor.mu.Lock()
+	assert.Equal(t, 1, len(offerInterceptor.localStreams))
+	local := offerInterceptor.localStreams[0]
+	assert.Equal(t, vp8Track.SSRC(), local.SSRC)
+	assert.Equal(t, uint8(DefaultPayloadTypeVP8), local.PayloadType)
+	assert.Equal(t, "video/VP8", local.MimeType)
+	assert.Equal(t, uint32(90000), local.ClockRate)
+	assert.NotZero(t, offerInterceptor.rtcpRead)
+	offerInterceptor.mu.Unlock()
+
+	answerInterceptor.mu.Lock()
+	assert.Equal(t, 1, len(answerInterceptor.remoteStreams))
+	remote := answerInterceptor.remoteStreams[0]
+	assert.Equal(t, vp8Track.SSRC(), remote.SSRC)
+	assert.Equal(t, "video/VP8", remote.MimeType)
+	assert.Contains(t, remote.RTCPFeedback, interceptor.RTCPFeedback{Type: TypeRTCPFBNACK})
+	assert.NotZero(t, answerInterceptor.rtcpWritten)
+	answerInterceptor.mu.Unlock()
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+
+	offerInterceptor.mu.Lock()
+	assert.Equal(t, 1, offerInterceptor.unbound)
+	offerInterceptor.mu.Unlock()
+	answerInterceptor.mu.Lock()
+	assert.Equal(t, 1, answerInterceptor.unbound)
+	answerInterceptor.mu.Unlock()
+}
+
+func TestPeerConnection_Media_TransportCC(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+	api.mediaEngine.RegisterDefaultCongestionControl()
+	transportCCExtensionID, err := api.mediaEngine.RegisterHeaderExtension(TransportCCURI, RTPCodecTypeVideo)
+	assert.NoError(t, err)
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+	assert.NoError(t, err)
+
+	vp8Track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	assert.NoError(t, err)
+	_, err = pcOffer.AddTrack(vp8Track)
+	assert.NoError(t, err)
+
+	offer, err := pcOffer.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.Contains(t, offer.SDP, fmt.Sprintf("a=extmap:%d %s\r\n", transportCCExtensionID, TransportCCURI))
+	assert.Contains(t, offer.SDP, fmt.Sprintf("a=rtcp-fb:%d transport-cc\r\n", DefaultPayloadTypeVP8))
+
+	// Every packet carries a transport-wide sequence number
+	received := make(chan struct{})
+	pcAnswer.OnTrack(func(track *Track, receiver *RTPReceiver) {
+		pkt, err := track.ReadRTP()
+		if err != nil {
+			return
+		}
+		_, ok := transportCCSequenceNumber(&pkt.Header, uint8(transportCCExtensionID))
+		assert.True(t, ok)
+		close(received)
+	})
+
+	// The feedback of the answerer changes the target bitrate of the
+	// offerer
+	targetBitrate := make(chan uint64, 1)
+	pcOffer.OnTargetBitrateChange(func(bitrate uint64) {
+		select {
+		case targetBitrate <- bitrate:
+		default:
+		}
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+	assert.Contains(t, pcAnswer.LocalDescription().SDP, fmt.Sprintf("a=extmap:%d %s\r\n", transportCCExtensionID, TransportCCURI))
+
+	func() {
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				assert.NoError(t, vp8Track.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}))
+			case bitrate := <-targetBitrate:
+				assert.True(t, bitrate >= minTargetBitrate)
+				return
+			}
+		}
+	}()
+	<-received
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_Media_REMB(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	// The offerer only supports REMB feedback
+	offerAPI := NewAPI()
+	vp8Codec := NewRTPVP8Codec(DefaultPayloadTypeVP8, 90000)
+	vp8Codec.RTCPFeedback = []RTCPFeedback{{Type: TypeRTCPFBGoogREMB}}
+	offerAPI.mediaEngine.RegisterCodec(vp8Codec)
+	absSendTimeExtensionID, err := offerAPI.mediaEngine.RegisterHeaderExtension(AbsSendTimeURI, RTPCodecTypeVideo)
+	assert.NoError(t, err)
+	pcOffer, err := offerAPI.NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	answerAPI := NewAPI()
+	answerAPI.mediaEngine.RegisterDefaultCodecs()
+	answerAPI.mediaEngine.RegisterDefaultCongestionControl()
+	pcAnswer, err := answerAPI.NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	_, err = pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+	assert.NoError(t, err)
+
+	vp8Track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	assert.NoError(t, err)
+	sender, err := pcOffer.AddTrack(vp8Track)
+	assert.NoError(t, err)
+
+	offer, err := pcOffer.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.Contains(t, offer.SDP, fmt.Sprintf("a=extmap:%d %s\r\n", absSendTimeExtensionID, AbsSendTimeURI))
+	assert.Contains(t, offer.SDP, fmt.Sprintf("a=rtcp-fb:%d goog-remb\r\n", DefaultPayloadTypeVP8))
+
+	// Every packet carries its absolute send time
+	pcAnswer.OnTrack(func(track *Track, receiver *RTPReceiver) {
+		pkt, err := track.ReadRTP()
+		if err != nil {
+			return
+		}
+		_, ok := getAbsSendTime(&pkt.Header, uint8(absSendTimeExtensionID))
+		assert.True(t, ok)
+	})
+
+	// The answerer sends the bitrate it estimated for the track
+	remb := make(chan *rtcp.ReceiverEstimatedMaximumBitrate, 1)
+	go func() {
+		for {
+			pkts, err := sender.ReadRTCP()
+			if err != nil {
+				return
+			}
+			for _, pkt := range pkts {
+				if p, ok := pkt.(*rtcp.ReceiverEstimatedMaximumBitrate); ok {
+					select {
+					case remb <- p:
+					default:
+					}
+				}
+			}
+		}
+	}()
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+	assert.Contains(t, pcAnswer.LocalDescription().SDP, fmt.Sprintf("a=extmap:%d %s\r\n", absSendTimeExtensionID, AbsSendTimeURI))
+
+	func() {
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				assert.NoError(t, vp8Track.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}))
+			case p := <-remb:
+				assert.Equal(t, []uint32{vp8Track.SSRC()}, p.SSRCs)
+				assert.True(t, uint64(p.Bitrate) >= minTargetBitrate)
+				return
+			}
+		}
+	}()
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_Media_HeaderExtensions(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	// Only the video orientation is supported by both besides the
+	// defaults, with different IDs
+	const toffsetURI = "urn:ietf:params:rtp-hdrext:toffset"
+	offerAPI := NewAPI()
+	offerAPI.mediaEngine.RegisterDefaultCodecs()
+	_, err := offerAPI.mediaEngine.RegisterHeaderExtension(toffsetURI, RTPCodecTypeVideo)
+	assert.NoError(t, err)
+	orientationID, err := offerAPI.mediaEngine.RegisterHeaderExtension(VideoOrientationURI, RTPCodecTypeVideo)
+	assert.NoError(t, err)
+	pcOffer, err := offerAPI.NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	answerAPI := NewAPI()
+	answerAPI.mediaEngine.RegisterDefaultCodecs()
+	answerOrientationID, err := answerAPI.mediaEngine.RegisterHeaderExtension(VideoOrientationURI, RTPCodecTypeVideo)
+	assert.NoError(t, err)
+	assert.NotEqual(t, orientationID, answerOrientationID)
+	pcAnswer, err := answerAPI.NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	_, err = pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+	assert.NoError(t, err)
+
+	vp8Track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	assert.NoError(t, err)
+	_, err = pcOffer.AddTrack(vp8Track)
+	assert.NoError(t, err)
+
+	// The extensions are read with the IDs of the offer
+	received := make(chan struct{})
+	pcAnswer.OnTrack(func(track *Track, receiver *RTPReceiver) {
+		pkt, err := track.ReadRTP()
+		if err != nil {
+			return
+		}
+		orientation, ok := receiver.GetHeaderExtension(&pkt.Header, VideoOrientationURI)
+		assert.True(t, ok)
+		assert.Equal(t, []byte{0x01}, orientation)
+		_, ok = getRTPHeaderExtension(&pkt.Header, uint8(orientationID))
+		assert.True(t, ok)
+		_, ok = receiver.GetHeaderExtension(&pkt.Header, toffsetURI)
+		assert.False(t, ok)
+		close(received)
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+	answer := pcAnswer.LocalDescription().SDP
+	assert.Contains(t, answer, fmt.Sprintf("a=extmap:%d %s\r\n", orientationID, VideoOrientationURI))
+	assert.NotContains(t, answer, toffsetURI)
+
+	// A new offer keeps the negotiated IDs
+	offer, err := pcAnswer.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.Contains(t, offer.SDP, fmt.Sprintf("a=extmap:%d %s\r\n", orientationID, VideoOrientationURI))
+
+	func() {
+		sequenceNumber := uint16(0)
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				sequenceNumber++
+				assert.NoError(t, vp8Track.WriteRTPWithHeaderExtensions(&rtp.Packet{
+					Header: rtp.Header{
+						Version:        2,
+						PayloadType:    DefaultPayloadTypeVP8,
+						SequenceNumber: sequenceNumber,
+						SSRC:           vp8Track.SSRC(),
+					},
+					Payload: []byte{0x00},
+				},
+					RTPHeaderExtension{URI: VideoOrientationURI, Payload: []byte{0x01}},
+					RTPHeaderExtension{URI: toffsetURI, Payload: []byte{0x00, 0x00, 0x01}},
+				))
+			case <-received:
+				return
+			}
+		}
+	}()
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_Media_Simulcast(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+	extensions := api.mediaEngine.getHeaderExtensions(RTPCodecTypeVideo)
+	midID, _ := headerExtensionID(extensions, SDESMidURI)
+	ridID, _ := headerExtensionID(extensions, SDESRTPStreamIDURI)
+
+	pcOffer, pcAnswer, err := api.newPair()
+	assert.NoError(t, err)
+
+	transceiver, err := pcOffer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionSendonly})
+	assert.NoError(t, err)
+	_, err = pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+	assert.NoError(t, err)
+
+	// The layers are received by the RIDs in their packets, they aren't
+	// signaled with SSRCs
+	var tracksLock sync.Mutex
+	tracks := map[string]*Track{}
+	received := make(chan struct{})
+	pcAnswer.OnTrack(func(track *Track, receiver *RTPReceiver) {
+		pkt, err := track.ReadRTP()
+		if err != nil {
+			return
+		}
+		assert.Equal(t, track.SSRC(), pkt.SSRC)
+		assert.Equal(t, DefaultPayloadTypeVP8, int(track.PayloadType()))
+		assert.Len(t, receiver.Tracks(), 2)
+
+		tracksLock.Lock()
+		defer tracksLock.Unlock()
+		tracks[track.RID()] = track
+		if len(tracks) == 2 {
+			close(received)
+		}
+	})
+
+	gathered := make(chan struct{})
+	pcOffer.OnICECandidate(func(c *ICECandidate) {
+		if c == nil {
+			close(gathered)
+		}
+	})
+	offer, err := pcOffer.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, pcOffer.SetLocalDescription(offer))
+	<-gathered
+
+	offer = *pcOffer.PendingLocalDescription()
+	offer.SDP = strings.Replace(offer.SDP, "a=sendonly\r\n", "a=sendonly\r\na=rid:h send\r\na=rid:l send\r\na=simulcast:send h;l\r\n", 1)
+	assert.NoError(t, pcAnswer.SetRemoteDescription(offer))
+	answer, err := pcAnswer.CreateAnswer(nil)
+	assert.NoError(t, err)
+	assert.Contains(t, answer.SDP, "a=rid:h recv\r\na=rid:l recv\r\na=simulcast:recv h;l\r\n")
+	assert.NoError(t, pcAnswer.SetLocalDescription(answer))
+	assert.NoError(t, pcOffer.SetRemoteDescription(answer))
+
+	ssrcs := map[string]uint32{"h": rand.Uint32(), "l": rand.Uint32()}
+	func() {
+		sequenceNumber := uint16(0)
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				srtpSession, err := pcOffer.dtlsTransport.getSRTPSession()
+				if err != nil {
+					continue
+				}
+				writeStream, err := srtpSession.OpenWriteStream()
+				assert.NoError(t, err)
+
+				sequenceNumber++
+				for rid, ssrc := range ssrcs {
+					header := &rtp.Header{
+						Version:        2,
+						PayloadType:    DefaultPayloadTypeVP8,
+						SequenceNumber: sequenceNumber,
+						SSRC:           ssrc,
+					}
+					assert.NoError(t, setRTPHeaderExtension(header, midID, []byte(transceiver.Mid)))
+					assert.NoError(t, setRTPHeaderExtension(header, ridID, []byte(rid)))
+					_, err = writeStream.WriteRTP(header, []byte{0x00})
+					assert.NoError(t, err)
+				}
+			case <-received:
+				return
+			}
+		}
+	}()
+
+	tracksLock.Lock()
+	for rid, ssrc := range ssrcs {
+		assert.Equal(t, ssrc, tracks[rid].SSRC())
+	}
+	tracksLock.Unlock()
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_Media_SimulcastReceiveRIDs(t *testing.T) {
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+	pcOffer, pcAnswer, err := api.newPair()
+	assert.NoError(t, err)
+
+	_, err = pcOffer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionSendonly})
+	assert.NoError(t, err)
+	transceiver, err := pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+	assert.NoError(t, err)
+
+	offer, err := pcOffer.CreateOffer(nil)
+	assert.NoError(t, err)
+	offer.SDP = strings.Replace(offer.SDP, "a=sendonly\r\n", "a=sendonly\r\na=rid:h send\r\na=rid:l send\r\na=simulcast:send h;l\r\n", 1)
+	assert.NoError(t, pcAnswer.SetRemoteDescription(offer))
+	answer, err := pcAnswer.CreateAnswer(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, pcAnswer.SetLocalDescription(answer))
+
+	// The RIDs wait for their streams once openSRTP returns, before
+	// drainSRTP reads the first packets
+	pcAnswer.mediaLock.Lock()
+	pcAnswer.openSRTP()
+	pcAnswer.mediaLock.Unlock()
+	assert.True(t, transceiver.Receiver.hasReceived())
+	rids := []string{}
+	for _, track := range transceiver.Receiver.Tracks() {
+		rids = append(rids, track.RID())
+	}
+	assert.Equal(t, []string{"h", "l"}, rids)
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_Media_SendSimulcast(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+	pcOffer, pcAnswer, err := api.newPair()
+	assert.NoError(t, err)
+
+	vp8Track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	assert.NoError(t, err)
+
+	// The RIDs have to be valid and unique
+	for _, rids := range [][]string{{"h", "h"}, {"h", ""}, {"h", "m~"}} {
+		encodings := []RTPEncodingParameters{}
+		for _, rid := range rids {
+			encodings = append(encodings, RTPEncodingParameters{RTPCodingParameters{RID: rid}})
+		}
+		_, err = pcOffer.AddTransceiverFromTrack(vp8Track, RtpTransceiverInit{Direction: RTPTransceiverDirectionSendonly, SendEncodings: encodings})
+		assert.Error(t, err, rids)
+	}
+
+	transceiver, err := pcOffer.AddTransceiverFromTrack(vp8Track, RtpTransceiverInit{
+		Direction: RTPTransceiverDirectionSendonly,
+		SendEncodings: []RTPEncodingParameters{
+			{RTPCodingParameters{RID: "h"}},
+			{RTPCodingParameters{RID: "m", SSRC: 5000}},
+			{RTPCodingParameters{RID: "l"}},
+		},
+	})
+	assert.NoError(t, err)
+	_, err = pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+	assert.NoError(t, err)
+
+	layers := transceiver.Sender.Tracks()
+	if !assert.Len(t, layers, 3) {
+		return
+	}
+	assert.Equal(t, vp8Track, layers[0])
+	assert.Equal(t, uint32(5000), layers[1].SSRC())
+	ssrcs := map[string]uint32{}
+	for i, rid := range []string{"h", "m", "l"} {
+		assert.Equal(t, rid, layers[i].RID())
+		assert.Equal(t, vp8Track.ID(), layers[i].ID())
+		ssrcs[rid] = layers[i].SSRC()
+	}
+
+	var receivedLock sync.Mutex
+	received := map[string]uint32{}
+	done := make(chan struct{})
+	pcAnswer.OnTrack(func(track *Track, receiver *RTPReceiver) {
+		if _, err := track.ReadRTP(); err != nil {
+			return
+		}
+
+		receivedLock.Lock()
+		defer receivedLock.Unlock()
+		received[track.RID()] = track.SSRC()
+		if len(received) == len(ssrcs) {
+			close(done)
+		}
+	})
+
+	// The layers are signaled by their RIDs instead of SSRCs
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+	offer := pcOffer.LocalDescription().SDP
+	assert.Contains(t, offer, "a=rid:h send\r\na=rid:m send\r\na=rid:l send\r\na=simulcast:send h;m;l\r\n")
+	assert.NotContains(t, offer, fmt.Sprintf("a=ssrc:%d", vp8Track.SSRC()))
+	assert.Contains(t, pcAnswer.LocalDescription().SDP, "a=simulcast:recv h;m;l\r\n")
+
+	func() {
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				for _, layer := range layers {
+					assert.NoError(t, layer.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}))
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	receivedLock.Lock()
+	assert.Equal(t, ssrcs, received)
+	receivedLock.Unlock()
+
+	// Every layer is an outbound RTP stream
+	for _, ssrc := range ssrcs {
+		assert.Contains(t, transceiver.Sender.GetStats(), fmt.Sprintf("OutboundRTPStream-%d", ssrc))
+	}
+
+	// The RTCP of the other layers is still read once the RTCP stream of
+	// one layer ends
+	transceiver.Sender.mu.RLock()
+	assert.NoError(t, transceiver.Sender.encodings[0].rtcpReadStream.Close())
+	transceiver.Sender.mu.RUnlock()
+	assert.NoError(t, pcAnswer.WriteRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: ssrcs["l"]}}))
+	for pli := false; !pli; {
+		pkts, err := transceiver.Sender.ReadRTCP()
+		if !assert.NoError(t, err) {
+			break
+		}
+		for _, pkt := range pkts {
+			if p, ok := pkt.(*rtcp.PictureLossIndication); ok && p.MediaSSRC == ssrcs["l"] {
+				pli = true
+			}
+		}
+	}
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_Media_SimulcastSendingTrack(t *testing.T) {
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+	api.mediaEngine.RegisterDefaultFEC()
+	pc, err := api.NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	vp8Track, err := pc.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	assert.NoError(t, err)
+	transceiver, err := pc.AddTransceiverFromTrack(vp8Track, RtpTransceiverInit{
+		SendEncodings: []RTPEncodingParameters{
+			{RTPCodingParameters{RID: "h"}},
+			{RTPCodingParameters{RID: "l"}},
+		},
+	})
+	assert.NoError(t, err)
+	layers := transceiver.Sender.Tracks()
+
+	// The FlexFEC streams of the layers can't be signaled
+	assert.Error(t, transceiver.Sender.EnableFEC(FECMechanismFlexFEC, 10))
+	assert.NoError(t, transceiver.Sender.EnableFEC(FECMechanismULPFEC, 10))
+
+	// Replacing the track of the first layer keeps the other layers
+	replacement, err := pc.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	assert.NoError(t, err)
+	assert.NoError(t, transceiver.setSendingTrack(replacement))
+	assert.Equal(t, []*Track{replacement, layers[1]}, transceiver.Sender.Tracks())
+	assert.Equal(t, "h", replacement.RID())
+	assert.Equal(t, 0, vp8Track.totalSenderCount)
+	assert.Equal(t, 1, replacement.totalSenderCount)
+	assert.Equal(t, 1, layers[1].totalSenderCount)
+
+	// Removing the track releases all layers
+	assert.NoError(t, transceiver.setSendingTrack(nil))
+	assert.Empty(t, transceiver.Sender.Tracks())
+	assert.Equal(t, 0, replacement.totalSenderCount)
+	assert.Equal(t, 0, layers[1].totalSenderCount)
+
+	assert.NoError(t, pc.Close())
 }
@@ -0,0 +1,739 @@
+// +build !js
+
+package webrtc
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/ice"
+	"github.com/pion/sdp/v2"
+	"github.com/pion/transport/test"
+	"github.com/pion/webrtc/v2/pkg/media"
+	"github.com/stretchr/testify/assert"
+)
+
+// renegotiate runs a subsequent offer/answer exchange between two
+// PeerConnections that have already been signaled with signalPair
+func renegotiate(pcOffer, pcAnswer *PeerConnection) error {
+	// Candidates are already known from the initial negotiation
+	pcOffer.OnICECandidate(nil)
+
+	offer, err := pcOffer.CreateOffer(nil)
+	if err != nil {
+		return err
+	}
+	if err = pcOffer.SetLocalDescription(offer); err != nil {
+		return err
+	}
+	if err = pcAnswer.SetRemoteDescription(offer); err != nil {
+		return err
+	}
+
+	answer, err := pcAnswer.CreateAnswer(nil)
+	if err != nil {
+		return err
+	}
+	if err = pcAnswer.SetLocalDescription(answer); err != nil {
+		return err
+	}
+	return pcOffer.SetRemoteDescription(answer)
+}
+
+func TestPeerConnection_Renegotiation_AddTrack(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = pcAnswer.AddTransceiver(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+	assert.NoError(t, err)
+
+	connected := make(chan struct{})
+	pcAnswer.OnICEConnectionStateChange(func(iceState ICEConnectionState) {
+		if iceState == ICEConnectionStateConnected {
+			close(connected)
+		}
+	})
+
+	onTrackFired := make(chan *Track)
+	pcAnswer.OnTrack(func(track *Track, r *RTPReceiver) {
+		onTrackFired <- track
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+	<-connected
+
+	vp8Track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "foo", "bar")
+	assert.NoError(t, err)
+
+	_, err = pcOffer.AddTrack(vp8Track)
+	assert.NoError(t, err)
+
+	assert.NoError(t, renegotiate(pcOffer, pcAnswer))
+
+	transportBefore := pcAnswer.sctpTransport
+	func() {
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				assert.NoError(t, vp8Track.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}))
+			case track := <-onTrackFired:
+				assert.Equal(t, vp8Track.SSRC(), track.SSRC())
+				return
+			}
+		}
+	}()
+
+	// The transports of the initial negotiation are reused
+	assert.True(t, transportBefore == pcAnswer.sctpTransport)
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_Renegotiation_RemoveTrack(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = pcAnswer.AddTransceiver(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+	assert.NoError(t, err)
+
+	vp8Track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "foo", "bar")
+	assert.NoError(t, err)
+	sender, err := pcOffer.AddTrack(vp8Track)
+	assert.NoError(t, err)
+
+	onTrackFired := make(chan *Track)
+	pcAnswer.OnTrack(func(track *Track, r *RTPReceiver) {
+		onTrackFired <- track
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	var remoteTrack *Track
+	func() {
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				assert.NoError(t, vp8Track.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}))
+			case remoteTrack = <-onTrackFired:
+				return
+			}
+		}
+	}()
+
+	negotiationNeeded := make(chan struct{}, 10)
+	pcOffer.OnNegotiationNeeded(func() {
+		negotiationNeeded <- struct{}{}
+	})
+
+	assert.NoError(t, pcOffer.RemoveTrack(sender))
+	<-negotiationNeeded
+	assert.Equal(t, RTPTransceiverDirectionInactive, pcOffer.GetTransceivers()[0].Direction)
+	assert.Equal(t, io.ErrClosedPipe, vp8Track.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}))
+
+	// Removing the track again is a no-op
+	assert.NoError(t, pcOffer.RemoveTrack(sender))
+
+	assert.NoError(t, renegotiate(pcOffer, pcAnswer))
+
+	// The remote receiver is stopped once the track is no longer signaled
+	for {
+		if _, err = remoteTrack.ReadRTP(); err != nil {
+			break
+		}
+	}
+
+	pcOther, err := api.NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+	otherTrack, err := pcOther.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "foo", "bar")
+	assert.NoError(t, err)
+	otherSender, err := pcOther.AddTrack(otherTrack)
+	assert.NoError(t, err)
+	assert.Error(t, pcOffer.RemoveTrack(otherSender))
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+	assert.NoError(t, pcOther.Close())
+}
+
+func TestPeerConnection_Renegotiation_ICERestart(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	pcOffer, pcAnswer, err := newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dc, err := pcOffer.CreateDataChannel("restart", nil)
+	assert.NoError(t, err)
+
+	opened := make(chan struct{})
+	dc.OnOpen(func() {
+		close(opened)
+	})
+
+	messages := make(chan string, 10)
+	pcAnswer.OnDataChannel(func(d *DataChannel) {
+		d.OnMessage(func(msg DataChannelMessage) {
+			messages <- string(msg.Data)
+		})
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+	<-opened
+	assert.NoError(t, dc.SendText("before"))
+	assert.Equal(t, "before", <-messages)
+
+	offerParams, err := pcOffer.iceGatherer.GetLocalParameters()
+	assert.NoError(t, err)
+	answerParams, err := pcAnswer.iceGatherer.GetLocalParameters()
+	assert.NoError(t, err)
+	connBefore := pcOffer.iceTransport.conn.current()
+	dtlsBefore := pcOffer.dtlsTransport.conn
+
+	pcOffer.OnICECandidate(nil)
+	offer, err := pcOffer.CreateOffer(&OfferOptions{ICERestart: true})
+	assert.NoError(t, err)
+	assert.NotContains(t, offer.SDP, offerParams.UsernameFragment)
+
+	// Offers created until the restart is negotiated reuse the new credentials
+	restartParams, err := pcOffer.iceGatherer.GetLocalParameters()
+	assert.NoError(t, err)
+	offer, err = pcOffer.CreateOffer(&OfferOptions{ICERestart: true})
+	assert.NoError(t, err)
+	assert.Contains(t, offer.SDP, "a=ice-ufrag:"+restartParams.UsernameFragment)
+
+	assert.NoError(t, pcOffer.SetLocalDescription(offer))
+	assert.NoError(t, pcAnswer.SetRemoteDescription(offer))
+	answer, err := pcAnswer.CreateAnswer(nil)
+	assert.NoError(t, err)
+	assert.NotContains(t, answer.SDP, answerParams.UsernameFragment)
+	assert.NoError(t, pcAnswer.SetLocalDescription(answer))
+	assert.NoError(t, pcOffer.SetRemoteDescription(answer))
+
+	for pcOffer.iceTransport.conn.current() == connBefore {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// DTLS and SCTP keep running on top of the new candidate pair
+	assert.True(t, dtlsBefore == pcOffer.dtlsTransport.conn)
+	assert.NoError(t, dc.SendText("after"))
+	assert.Equal(t, "after", <-messages)
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_Renegotiation_AutomaticICERestart(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	s := SettingEngine{}
+	s.SetAutomaticICERestart(true)
+	pcOffer, pcAnswer, err := NewAPI(WithSettingEngine(s)).newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dc, err := pcOffer.CreateDataChannel("restart", nil)
+	assert.NoError(t, err)
+
+	opened := make(chan struct{})
+	dc.OnOpen(func() {
+		close(opened)
+	})
+
+	messages := make(chan string, 10)
+	pcAnswer.OnDataChannel(func(d *DataChannel) {
+		d.OnMessage(func(msg DataChannelMessage) {
+			messages <- string(msg.Data)
+		})
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+	<-opened
+
+	offerNegotiationNeeded := make(chan struct{}, 10)
+	pcOffer.OnNegotiationNeeded(func() {
+		offerNegotiationNeeded <- struct{}{}
+	})
+	answerNegotiationNeeded := make(chan struct{}, 10)
+	pcAnswer.OnNegotiationNeeded(func() {
+		answerNegotiationNeeded <- struct{}{}
+	})
+
+	// Only the controlling side restarts
+	pcAnswer.iceTransport.onConnectionStateChange(ICETransportStateDisconnected)
+	pcOffer.iceTransport.onConnectionStateChange(ICETransportStateDisconnected)
+	<-offerNegotiationNeeded
+	select {
+	case <-answerNegotiationNeeded:
+		t.Fatal("controlled side requested an ICE restart")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	connBefore := pcOffer.iceTransport.conn.current()
+	assert.NoError(t, renegotiate(pcOffer, pcAnswer))
+	for pcOffer.iceTransport.conn.current() == connBefore {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	assert.NoError(t, dc.SendText("after"))
+	assert.Equal(t, "after", <-messages)
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_RestartICE(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	pcOffer, pcAnswer, err := newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	restartNeeded := func() bool {
+		pcOffer.mu.RLock()
+		defer pcOffer.mu.RUnlock()
+		return pcOffer.iceRestartNeeded
+	}
+
+	// Creating an offer doesn't complete the restart, only applying it does
+	pcOffer.RestartICE()
+	offer, err := pcOffer.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.True(t, restartNeeded())
+	assert.True(t, pcOffer.checkNegotiationNeeded())
+
+	assert.NoError(t, pcOffer.SetLocalDescription(offer))
+	assert.False(t, restartNeeded())
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_Renegotiation_ICERestartError(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	pcOffer, pcAnswer, err := newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	pcOffer.OnICECandidate(nil)
+	pcAnswer.OnICECandidate(nil)
+	offer, err := pcOffer.CreateOffer(&OfferOptions{ICERestart: true})
+	assert.NoError(t, err)
+	assert.NoError(t, pcOffer.SetLocalDescription(offer))
+
+	// The restart runs before SetRemoteDescription returns, so a restart
+	// the agent can't start is reported by it
+	restartParams, err := pcOffer.iceGatherer.GetLocalParameters()
+	assert.NoError(t, err)
+	offer.SDP = strings.Replace(offer.SDP, "a=ice-pwd:"+restartParams.Password+"\r\n", "", -1)
+	assert.Equal(t, ice.ErrRemotePwdEmpty, pcAnswer.SetRemoteDescription(offer))
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_VoiceActivityDetection(t *testing.T) {
+	api := NewAPI()
+	api.mediaEngine.RegisterCodec(NewRTPOpusCodec(DefaultPayloadTypeOpus, 48000))
+	api.mediaEngine.RegisterCodec(NewRTPCNCodec(DefaultPayloadTypeCN, 8000))
+
+	pc, err := api.NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	_, err = pc.AddTransceiver(RTPCodecTypeAudio)
+	assert.NoError(t, err)
+
+	offer, err := pc.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.Contains(t, offer.SDP, "CN/8000")
+
+	// Options that leave it unset keep it enabled
+	offer, err = pc.CreateOffer(&OfferOptions{})
+	assert.NoError(t, err)
+	assert.Contains(t, offer.SDP, "CN/8000")
+
+	enabled, disabled := true, false
+	offer, err = pc.CreateOffer(&OfferOptions{OfferAnswerOptions: OfferAnswerOptions{VoiceActivityDetection: &enabled}})
+	assert.NoError(t, err)
+	assert.Contains(t, offer.SDP, "CN/8000")
+
+	offer, err = pc.CreateOffer(&OfferOptions{OfferAnswerOptions: OfferAnswerOptions{VoiceActivityDetection: &disabled}})
+	assert.NoError(t, err)
+	assert.NotContains(t, offer.SDP, "CN/8000")
+	assert.Contains(t, offer.SDP, "opus/48000")
+
+	assert.NoError(t, pc.Close())
+}
+
+func TestPeerConnection_VoiceActivityDetection_DefaultCodecs(t *testing.T) {
+	// Comfort noise isn't part of the default codecs
+	pc, err := NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+	_, err = pc.AddTransceiver(RTPCodecTypeAudio)
+	assert.NoError(t, err)
+
+	offer, err := pc.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.NotContains(t, offer.SDP, "CN/8000")
+	assert.Contains(t, offer.SDP, "opus/48000")
+	assert.NoError(t, pc.Close())
+
+	// It is negotiated once it is registered on top of them
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+	api.mediaEngine.RegisterCodec(NewRTPCNCodec(DefaultPayloadTypeCN, 8000))
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = pcOffer.AddTransceiver(RTPCodecTypeAudio)
+	assert.NoError(t, err)
+
+	offer, err = pcOffer.CreateOffer(&OfferOptions{ICERestart: true})
+	assert.NoError(t, err)
+	assert.Contains(t, offer.SDP, fmt.Sprintf("a=rtpmap:%d CN/8000", DefaultPayloadTypeCN))
+
+	assert.NoError(t, pcOffer.SetLocalDescription(offer))
+	assert.NoError(t, pcAnswer.SetRemoteDescription(offer))
+
+	answer, err := pcAnswer.CreateAnswer(&AnswerOptions{})
+	assert.NoError(t, err)
+	assert.Contains(t, answer.SDP, "CN/8000")
+
+	disabled := false
+	answer, err = pcAnswer.CreateAnswer(&AnswerOptions{OfferAnswerOptions: OfferAnswerOptions{VoiceActivityDetection: &disabled}})
+	assert.NoError(t, err)
+	assert.NotContains(t, answer.SDP, "CN/8000")
+	assert.Contains(t, answer.SDP, "opus/48000")
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_OnNegotiationNeeded(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	negotiationNeeded := make(chan struct{}, 10)
+	pcOffer.OnNegotiationNeeded(func() {
+		negotiationNeeded <- struct{}{}
+	})
+
+	assertFired := func(expected bool) {
+		select {
+		case <-negotiationNeeded:
+			assert.True(t, expected, "OnNegotiationNeeded fired unexpectedly")
+		case <-time.After(100 * time.Millisecond):
+			assert.False(t, expected, "OnNegotiationNeeded did not fire")
+		}
+	}
+
+	// Changes are coalesced until the next negotiation
+	videoTransceiver, err := pcOffer.AddTransceiver(RTPCodecTypeVideo)
+	assert.NoError(t, err)
+	assertFired(true)
+
+	_, err = pcOffer.CreateDataChannel("initial_data_channel", nil)
+	assert.NoError(t, err)
+	assertFired(false)
+
+	// Nothing is left to negotiate after the exchange
+	assert.NoError(t, renegotiate(pcOffer, pcAnswer))
+	assertFired(false)
+
+	// Changes while not stable fire once stable is reached again
+	offer, err := pcOffer.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, pcOffer.SetLocalDescription(offer))
+
+	opusTrack, err := pcOffer.NewTrack(DefaultPayloadTypeOpus, rand.Uint32(), "audio", "pion")
+	assert.NoError(t, err)
+	opusSender, err := pcOffer.AddTrack(opusTrack)
+	assert.NoError(t, err)
+	assertFired(false)
+
+	assert.NoError(t, pcAnswer.SetRemoteDescription(offer))
+	answer, err := pcAnswer.CreateAnswer(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, pcAnswer.SetLocalDescription(answer))
+	assert.NoError(t, pcOffer.SetRemoteDescription(answer))
+	assertFired(true)
+
+	assert.NoError(t, renegotiate(pcOffer, pcAnswer))
+	assertFired(false)
+
+	// Direction changes of a transceiver have to be negotiated
+	assert.NoError(t, pcOffer.RemoveTrack(opusSender))
+	assertFired(true)
+	assert.NoError(t, renegotiate(pcOffer, pcAnswer))
+	assertFired(false)
+
+	_, err = pcOffer.AddTrack(opusTrack)
+	assert.NoError(t, err)
+	assertFired(true)
+	assert.NoError(t, renegotiate(pcOffer, pcAnswer))
+	assertFired(false)
+
+	assert.NoError(t, videoTransceiver.SetDirection(RTPTransceiverDirectionInactive))
+	assertFired(true)
+	assert.NoError(t, renegotiate(pcOffer, pcAnswer))
+	assertFired(false)
+
+	assert.NoError(t, videoTransceiver.SetDirection(RTPTransceiverDirectionInactive))
+	assertFired(false)
+	assert.Error(t, videoTransceiver.SetDirection(RTPTransceiverDirection(Unknown)))
+	assert.Equal(t, RTPTransceiverDirectionInactive, videoTransceiver.Direction)
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_Renegotiation_SetDirection(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offerTrack, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "offer", "pion")
+	assert.NoError(t, err)
+	transceiver, err := pcOffer.AddTransceiverFromTrack(offerTrack, RtpTransceiverInit{Direction: RTPTransceiverDirectionSendonly})
+	assert.NoError(t, err)
+
+	answerTrack, err := pcAnswer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "answer", "pion")
+	assert.NoError(t, err)
+	_, err = pcAnswer.AddTransceiverFromTrack(answerTrack, RtpTransceiverInit{Direction: RTPTransceiverDirectionSendrecv})
+	assert.NoError(t, err)
+
+	received := make(chan struct{})
+	pcOffer.OnTrack(func(track *Track, receiver *RTPReceiver) {
+		assert.Equal(t, answerTrack.SSRC(), track.SSRC())
+		close(received)
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	// The offerer starts receiving once the new direction is negotiated
+	assert.NoError(t, transceiver.SetDirection(RTPTransceiverDirectionSendrecv))
+	assert.NoError(t, renegotiate(pcOffer, pcAnswer))
+
+	func() {
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				assert.NoError(t, answerTrack.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}))
+			case <-received:
+				return
+			}
+		}
+	}()
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_Renegotiation_StableMids(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err = pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+		assert.NoError(t, err)
+
+		var track *Track
+		track, err = pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "foo", "bar")
+		assert.NoError(t, err)
+		_, err = pcOffer.AddTrack(track)
+		assert.NoError(t, err)
+	}
+
+	offerMids := func() []string {
+		mids := []string{}
+		for _, media := range pcOffer.CurrentLocalDescription().parsed.MediaDescriptions {
+			mids = append(mids, pcOffer.getMidValue(media))
+		}
+		return mids
+	}
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	initialMids := offerMids()
+	assert.Len(t, initialMids, 3)
+
+	transceivers := pcOffer.GetTransceivers()
+	assert.NotEqual(t, "", transceivers[0].Mid)
+	assert.NotEqual(t, transceivers[0].Mid, transceivers[1].Mid)
+	for _, transceiver := range transceivers {
+		assert.NotNil(t, pcAnswer.transceiverForMid(transceiver.Mid))
+	}
+
+	_, err = pcOffer.AddTransceiverFromKind(RTPCodecTypeAudio)
+	assert.NoError(t, err)
+	assert.NoError(t, renegotiate(pcOffer, pcAnswer))
+
+	// Existing sections keep their mid and position, the new one is appended
+	mids := offerMids()
+	assert.Len(t, mids, 4)
+	assert.Equal(t, initialMids, mids[:3])
+	assert.Equal(t, pcOffer.GetTransceivers()[2].Mid, mids[3])
+	assert.NotContains(t, initialMids, mids[3])
+	assert.Equal(t, transceivers[0].Mid, pcOffer.GetTransceivers()[0].Mid)
+	assert.Equal(t, transceivers[1].Mid, pcOffer.GetTransceivers()[1].Mid)
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_Renegotiation_HeaderExtensionIDs(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	// The video orientation is negotiated with ID 14 of the offer, which
+	// the answerer registered toffset with
+	const toffsetURI = "urn:ietf:params:rtp-hdrext:toffset"
+	offerAPI := NewAPI()
+	offerAPI.mediaEngine.RegisterDefaultCodecs()
+	for i := 0; ; i++ {
+		id, err := offerAPI.mediaEngine.RegisterHeaderExtension(fmt.Sprintf("urn:example:offer-%d", i), RTPCodecTypeVideo)
+		assert.NoError(t, err)
+		if id == 13 {
+			break
+		}
+	}
+	orientationID, err := offerAPI.mediaEngine.RegisterHeaderExtension(VideoOrientationURI, RTPCodecTypeVideo)
+	assert.NoError(t, err)
+	assert.Equal(t, 14, orientationID)
+
+	answerAPI := NewAPI()
+	answerAPI.mediaEngine.RegisterDefaultCodecs()
+	answerOrientationID, err := answerAPI.mediaEngine.RegisterHeaderExtension(VideoOrientationURI, RTPCodecTypeVideo)
+	assert.NoError(t, err)
+	for i := 0; ; i++ {
+		id, err := answerAPI.mediaEngine.RegisterHeaderExtension(fmt.Sprintf("urn:example:answer-%d", i), RTPCodecTypeVideo)
+		assert.NoError(t, err)
+		if id == 13 {
+			break
+		}
+	}
+	toffsetID, err := answerAPI.mediaEngine.RegisterHeaderExtension(toffsetURI, RTPCodecTypeVideo)
+	assert.NoError(t, err)
+	assert.Equal(t, orientationID, toffsetID)
+
+	pcOffer, err := offerAPI.NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+	pcAnswer, err := answerAPI.NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	_, err = pcOffer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+	assert.NoError(t, err)
+	_, err = pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo)
+	assert.NoError(t, err)
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+	assert.Contains(t, pcAnswer.LocalDescription().SDP, fmt.Sprintf("a=extmap:%d %s\r\n", orientationID, VideoOrientationURI))
+
+	// toffset gets the lowest free ID, the one the answerer registered the
+	// video orientation with
+	offer, err := pcAnswer.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.Contains(t, offer.SDP, fmt.Sprintf("a=extmap:%d %s\r\n", orientationID, VideoOrientationURI))
+	assert.Contains(t, offer.SDP, fmt.Sprintf("a=extmap:%d %s\r\n", answerOrientationID, toffsetURI))
+
+	// The answerer offers this time, candidates are known already
+	pcOffer.OnICECandidate(nil)
+	assert.NoError(t, renegotiate(pcAnswer, pcOffer))
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_Renegotiation_RejectedSection(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+	pcOffer, err := api.NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	// The answerer has no codecs and rejects the video section
+	pcAnswer, err := NewAPI().NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	assert.NoError(t, err)
+	_, err = pcOffer.AddTrack(track)
+	assert.NoError(t, err)
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+	mid := pcOffer.GetTransceivers()[0].Mid
+
+	assertRejected := func(desc *SessionDescription) {
+		for _, media := range desc.parsed.MediaDescriptions {
+			if pcOffer.getMidValue(media) == mid {
+				assert.Equal(t, 0, media.MediaName.Port.Value)
+			}
+		}
+		group, ok := desc.parsed.Attribute(sdp.AttrKeyGroup)
+		assert.True(t, ok)
+		assert.NotContains(t, strings.Fields(group)[1:], mid)
+	}
+	assertRejected(pcOffer.CurrentRemoteDescription())
+
+	// The rejected section is offered again with port 0 and stays rejected
+	assert.NoError(t, renegotiate(pcOffer, pcAnswer))
+	assertRejected(pcOffer.CurrentLocalDescription())
+	assertRejected(pcOffer.CurrentRemoteDescription())
+	assert.Len(t, pcOffer.CurrentLocalDescription().parsed.MediaDescriptions, 2)
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
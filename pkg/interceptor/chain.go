@@ -0,0 +1,82 @@
+package interceptor
+
+import (
+	"errors"
+	"strings"
+)
+
+// Chain is an Interceptor that binds its interceptors in order, the first
+// one is the closest to the application and the last to the network
+type Chain struct {
+	interceptors []Interceptor
+}
+
+// NewChain returns a Chain of interceptors
+func NewChain(interceptors []Interceptor) *Chain {
+	return &Chain{interceptors: interceptors}
+}
+
+// BindRTCPReader binds the interceptors from the network towards the
+// application, so the last one reads first
+func (c *Chain) BindRTCPReader(reader RTCPReader) RTCPReader {
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		reader = c.interceptors[i].BindRTCPReader(reader)
+	}
+	return reader
+}
+
+// BindRTCPWriter binds the interceptors from the network towards the
+// application, so the first one writes first
+func (c *Chain) BindRTCPWriter(writer RTCPWriter) RTCPWriter {
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		writer = c.interceptors[i].BindRTCPWriter(writer)
+	}
+	return writer
+}
+
+// BindLocalStream binds the interceptors from the network towards the
+// application, so the first one writes first
+func (c *Chain) BindLocalStream(info *StreamInfo, writer RTPWriter) RTPWriter {
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		writer = c.interceptors[i].BindLocalStream(info, writer)
+	}
+	return writer
+}
+
+// UnbindLocalStream unbinds the stream from all interceptors
+func (c *Chain) UnbindLocalStream(info *StreamInfo) {
+	for _, i := range c.interceptors {
+		i.UnbindLocalStream(info)
+	}
+}
+
+// BindRemoteStream binds the interceptors from the network towards the
+// application, so the last one reads first
+func (c *Chain) BindRemoteStream(info *StreamInfo, reader RTPReader) RTPReader {
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		reader = c.interceptors[i].BindRemoteStream(info, reader)
+	}
+	return reader
+}
+
+// UnbindRemoteStream unbinds the stream from all interceptors
+func (c *Chain) UnbindRemoteStream(info *StreamInfo) {
+	for _, i := range c.interceptors {
+		i.UnbindRemoteStream(info)
+	}
+}
+
+// Close closes all interceptors and returns their errors
+func (c *Chain) Close() error {
+	var errs []string
+	for _, i := range c.interceptors {
+		if err := i.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(errs, "\n"))
+}
@@ -0,0 +1,116 @@
+package interceptor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+// testInterceptor records the order it is called in with its name
+type testInterceptor struct {
+	NoOp
+	name     string
+	calls    *[]string
+	closeErr error
+}
+
+func (i *testInterceptor) BindRTCPReader(reader RTCPReader) RTCPReader {
+	return RTCPReaderFunc(func(b []byte, attributes Attributes) (int, Attributes, error) {
+		*i.calls = append(*i.calls, i.name)
+		return reader.Read(b, attributes)
+	})
+}
+
+func (i *testInterceptor) BindRTCPWriter(writer RTCPWriter) RTCPWriter {
+	return RTCPWriterFunc(func(pkts []rtcp.Packet, attributes Attributes) (int, error) {
+		*i.calls = append(*i.calls, i.name)
+		return writer.Write(pkts, attributes)
+	})
+}
+
+func (i *testInterceptor) BindLocalStream(info *StreamInfo, writer RTPWriter) RTPWriter {
+	return RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes Attributes) (int, error) {
+		*i.calls = append(*i.calls, i.name)
+		attributes[i.name] = true
+		return writer.Write(header, payload, attributes)
+	})
+}
+
+func (i *testInterceptor) BindRemoteStream(info *StreamInfo, reader RTPReader) RTPReader {
+	return RTPReaderFunc(func(b []byte, attributes Attributes) (int, Attributes, error) {
+		*i.calls = append(*i.calls, i.name)
+		return reader.Read(b, attributes)
+	})
+}
+
+func (i *testInterceptor) UnbindLocalStream(_ *StreamInfo) {
+	*i.calls = append(*i.calls, i.name)
+}
+
+func (i *testInterceptor) Close() error {
+	return i.closeErr
+}
+
+func TestChain(t *testing.T) {
+	calls := []string{}
+	chain := NewChain([]Interceptor{
+		&testInterceptor{name: "first", calls: &calls},
+		&testInterceptor{name: "second", calls: &calls},
+	})
+
+	// Writes pass the interceptors from the application to the network
+	var written Attributes
+	writer := chain.BindLocalStream(&StreamInfo{SSRC: 5000}, RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes Attributes) (int, error) {
+		written = attributes
+		return len(payload), nil
+	}))
+	n, err := writer.Write(&rtp.Header{}, []byte{0x01, 0x02}, Attributes{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"first", "second"}, calls)
+	assert.Equal(t, Attributes{"first": true, "second": true}, written)
+
+	calls = calls[:0]
+	_, err = chain.BindRTCPWriter(RTCPWriterFunc(func(pkts []rtcp.Packet, _ Attributes) (int, error) {
+		return 0, nil
+	})).Write(nil, Attributes{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first", "second"}, calls)
+
+	// Reads pass them from the network to the application, the reader
+	// closest to the application is called first and reads through the rest
+	calls = calls[:0]
+	reader := chain.BindRemoteStream(&StreamInfo{SSRC: 5000}, RTPReaderFunc(func(b []byte, attributes Attributes) (int, Attributes, error) {
+		calls = append(calls, "network")
+		return 0, attributes, nil
+	}))
+	_, _, err = reader.Read(nil, Attributes{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first", "second", "network"}, calls)
+
+	calls = calls[:0]
+	_, _, err = chain.BindRTCPReader(RTCPReaderFunc(func(b []byte, attributes Attributes) (int, Attributes, error) {
+		return 0, attributes, nil
+	})).Read(nil, Attributes{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first", "second"}, calls)
+
+	calls = calls[:0]
+	chain.UnbindLocalStream(&StreamInfo{SSRC: 5000})
+	assert.Equal(t, []string{"first", "second"}, calls)
+}
+
+func TestChain_Close(t *testing.T) {
+	calls := []string{}
+	assert.NoError(t, NewChain([]Interceptor{&testInterceptor{calls: &calls}}).Close())
+
+	err := NewChain([]Interceptor{
+		&testInterceptor{calls: &calls, closeErr: errors.New("first")},
+		&testInterceptor{calls: &calls},
+		&testInterceptor{calls: &calls, closeErr: errors.New("third")},
+	}).Close()
+	assert.EqualError(t, err, "first\nthird")
+}
@@ -0,0 +1,96 @@
+// Package interceptor lets applications observe and rewrite the RTP and
+// RTCP packets of PeerConnections, or send packets of their own, without
+// changes to the media path
+package interceptor
+
+import (
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+)
+
+// Interceptor is bound to the streams of a DTLSTransport. Every Bind method
+// gets the reader or writer of the next step and returns the one that is
+// used instead, an Interceptor that doesn't care about a stream returns it
+// unchanged.
+type Interceptor interface {
+	// BindRTCPReader is called for the incoming RTCP of every RTPSender and
+	// RTPReceiver
+	BindRTCPReader(reader RTCPReader) RTCPReader
+
+	// BindRTCPWriter is called once for all outgoing RTCP, the writer can
+	// also be kept to send RTCP packets
+	BindRTCPWriter(writer RTCPWriter) RTCPWriter
+
+	// BindLocalStream is called when an RTPSender starts sending a stream,
+	// the writer can also be kept to send RTP packets on it
+	BindLocalStream(info *StreamInfo, writer RTPWriter) RTPWriter
+
+	// UnbindLocalStream is called when the stream is no longer sent
+	UnbindLocalStream(info *StreamInfo)
+
+	// BindRemoteStream is called when an RTPReceiver starts receiving a
+	// stream
+	BindRemoteStream(info *StreamInfo, reader RTPReader) RTPReader
+
+	// UnbindRemoteStream is called when the stream is no longer received
+	UnbindRemoteStream(info *StreamInfo)
+
+	// Close is called when the DTLSTransport is stopped
+	Close() error
+}
+
+// Attributes are values attached to a packet while it passes the
+// interceptors, they are empty when a packet enters the chain
+type Attributes map[interface{}]interface{}
+
+// RTPWriter writes an RTP packet
+type RTPWriter interface {
+	Write(header *rtp.Header, payload []byte, attributes Attributes) (int, error)
+}
+
+// RTPReader reads a marshaled RTP packet
+type RTPReader interface {
+	Read(b []byte, attributes Attributes) (int, Attributes, error)
+}
+
+// RTCPWriter writes RTCP packets
+type RTCPWriter interface {
+	Write(pkts []rtcp.Packet, attributes Attributes) (int, error)
+}
+
+// RTCPReader reads marshaled RTCP packets
+type RTCPReader interface {
+	Read(b []byte, attributes Attributes) (int, Attributes, error)
+}
+
+// RTPWriterFunc is an adapter for RTPWriter
+type RTPWriterFunc func(header *rtp.Header, payload []byte, attributes Attributes) (int, error)
+
+// Write calls f
+func (f RTPWriterFunc) Write(header *rtp.Header, payload []byte, attributes Attributes) (int, error) {
+	return f(header, payload, attributes)
+}
+
+// RTPReaderFunc is an adapter for RTPReader
+type RTPReaderFunc func(b []byte, attributes Attributes) (int, Attributes, error)
+
+// Read calls f
+func (f RTPReaderFunc) Read(b []byte, attributes Attributes) (int, Attributes, error) {
+	return f(b, attributes)
+}
+
+// RTCPWriterFunc is an adapter for RTCPWriter
+type RTCPWriterFunc func(pkts []rtcp.Packet, attributes Attributes) (int, error)
+
+// Write calls f
+func (f RTCPWriterFunc) Write(pkts []rtcp.Packet, attributes Attributes) (int, error) {
+	return f(pkts, attributes)
+}
+
+// RTCPReaderFunc is an adapter for RTCPReader
+type RTCPReaderFunc func(b []byte, attributes Attributes) (int, Attributes, error)
+
+// Read calls f
+func (f RTCPReaderFunc) Read(b []byte, attributes Attributes) (int, Attributes, error) {
+	return f(b, attributes)
+}
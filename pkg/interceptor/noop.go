@@ -0,0 +1,36 @@
+package interceptor
+
+// NoOp is an Interceptor that leaves all streams unchanged, it can be
+// embedded by interceptors that only bind some of them
+type NoOp struct{}
+
+// BindRTCPReader returns reader unchanged
+func (i *NoOp) BindRTCPReader(reader RTCPReader) RTCPReader {
+	return reader
+}
+
+// BindRTCPWriter returns writer unchanged
+func (i *NoOp) BindRTCPWriter(writer RTCPWriter) RTCPWriter {
+	return writer
+}
+
+// BindLocalStream returns writer unchanged
+func (i *NoOp) BindLocalStream(_ *StreamInfo, writer RTPWriter) RTPWriter {
+	return writer
+}
+
+// UnbindLocalStream does nothing
+func (i *NoOp) UnbindLocalStream(_ *StreamInfo) {}
+
+// BindRemoteStream returns reader unchanged
+func (i *NoOp) BindRemoteStream(_ *StreamInfo, reader RTPReader) RTPReader {
+	return reader
+}
+
+// UnbindRemoteStream does nothing
+func (i *NoOp) UnbindRemoteStream(_ *StreamInfo) {}
+
+// Close does nothing
+func (i *NoOp) Close() error {
+	return nil
+}
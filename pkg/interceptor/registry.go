@@ -0,0 +1,35 @@
+package interceptor
+
+// Factory creates an Interceptor
+type Factory func() (Interceptor, error)
+
+// Registry holds the interceptors of an API, every DTLSTransport of the API
+// gets its own instances
+type Registry struct {
+	factories []Factory
+}
+
+// Add adds an interceptor after the ones added before, which are closer to
+// the application
+func (r *Registry) Add(factory Factory) {
+	r.factories = append(r.factories, factory)
+}
+
+// Build creates the interceptors and returns them as a Chain
+func (r *Registry) Build() (Interceptor, error) {
+	if len(r.factories) == 0 {
+		return &NoOp{}, nil
+	}
+
+	interceptors := []Interceptor{}
+	for _, factory := range r.factories {
+		i, err := factory()
+		if err != nil {
+			// The interceptors that were created are discarded
+			_ = NewChain(interceptors).Close()
+			return nil, err
+		}
+		interceptors = append(interceptors, i)
+	}
+	return NewChain(interceptors), nil
+}
@@ -0,0 +1,46 @@
+package interceptor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// closeCounter counts how often it is closed
+type closeCounter struct {
+	NoOp
+	closed int
+}
+
+func (c *closeCounter) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestRegistry_Build(t *testing.T) {
+	r := &Registry{}
+	i, err := r.Build()
+	assert.NoError(t, err)
+	assert.IsType(t, &NoOp{}, i)
+
+	created := &closeCounter{}
+	r.Add(func() (Interceptor, error) {
+		return created, nil
+	})
+	i, err = r.Build()
+	assert.NoError(t, err)
+	assert.IsType(t, &Chain{}, i)
+	assert.NoError(t, i.Close())
+	assert.Equal(t, 1, created.closed)
+
+	// The interceptors created before a factory fails are closed
+	errFactory := errors.New("factory failed")
+	r.Add(func() (Interceptor, error) {
+		return nil, errFactory
+	})
+	i, err = r.Build()
+	assert.Equal(t, errFactory, err)
+	assert.Nil(t, i)
+	assert.Equal(t, 2, created.closed)
+}
@@ -0,0 +1,33 @@
+package interceptor
+
+// RTPHeaderExtension is a header extension negotiated for a stream
+type RTPHeaderExtension struct {
+	URI string
+	ID  int
+}
+
+// RTCPFeedback is an RTCP feedback mechanism negotiated for a stream
+type RTCPFeedback struct {
+	Type      string
+	Parameter string
+}
+
+// StreamInfo describes a stream an Interceptor is bound to with what was
+// negotiated for it. The codec of a remote stream is the first negotiated
+// one, it is the one the remote peer prefers to send.
+type StreamInfo struct {
+	// ID is the ID of the track of the stream
+	ID string
+
+	// Attributes can be used by interceptors to keep values of the stream
+	Attributes Attributes
+
+	SSRC                uint32
+	PayloadType         uint8
+	RTPHeaderExtensions []RTPHeaderExtension
+	MimeType            string
+	ClockRate           uint32
+	Channels            uint16
+	SDPFmtpLine         string
+	RTCPFeedback        []RTCPFeedback
+}
@@ -0,0 +1,140 @@
+// +build !js
+
+package prometheus
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/pion/webrtc/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricDesc describes one exported metric, every metric carries the
+// peer_connection label first
+type metricDesc struct {
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+}
+
+func newMetricDesc(name, help string, valueType prometheus.ValueType, labels ...string) metricDesc {
+	return metricDesc{
+		desc:      prometheus.NewDesc(name, help, append([]string{"peer_connection"}, labels...), nil),
+		valueType: valueType,
+	}
+}
+
+var (
+	peerConnectionDataChannelsOpened = newMetricDesc("webrtc_peer_connection_data_channels_opened_total", "Number of unique DataChannels that have entered the open state.", prometheus.CounterValue)
+	peerConnectionDataChannelsClosed = newMetricDesc("webrtc_peer_connection_data_channels_closed_total", "Number of unique DataChannels that have left the open state.", prometheus.CounterValue)
+
+	transportPacketsSent     = newMetricDesc("webrtc_transport_packets_sent_total", "Packets sent over the transport.", prometheus.CounterValue, "transport_id")
+	transportPacketsReceived = newMetricDesc("webrtc_transport_packets_received_total", "Packets received on the transport.", prometheus.CounterValue, "transport_id")
+	transportBytesSent       = newMetricDesc("webrtc_transport_bytes_sent_total", "Bytes sent over the transport, including DTLS, SRTP and SCTP headers.", prometheus.CounterValue, "transport_id")
+	transportBytesReceived   = newMetricDesc("webrtc_transport_bytes_received_total", "Bytes received on the transport, including DTLS, SRTP and SCTP headers.", prometheus.CounterValue, "transport_id")
+
+	candidatePairBytesSent        = newMetricDesc("webrtc_candidate_pair_bytes_sent_total", "Bytes sent over the ICE candidate pair, including DTLS, SRTP and SCTP headers.", prometheus.CounterValue, "candidate_pair_id", "nominated")
+	candidatePairBytesReceived    = newMetricDesc("webrtc_candidate_pair_bytes_received_total", "Bytes received on the ICE candidate pair, including DTLS, SRTP and SCTP headers.", prometheus.CounterValue, "candidate_pair_id", "nominated")
+	candidatePairCurrentRoundTrip = newMetricDesc("webrtc_candidate_pair_current_round_trip_time_seconds", "Latest round trip time measured by STUN connectivity checks.", prometheus.GaugeValue, "candidate_pair_id", "nominated")
+
+	outboundRTPPacketsSent   = newMetricDesc("webrtc_outbound_rtp_packets_sent_total", "RTP packets sent for the SSRC.", prometheus.CounterValue, "ssrc", "kind")
+	outboundRTPBytesSent     = newMetricDesc("webrtc_outbound_rtp_bytes_sent_total", "RTP payload bytes sent for the SSRC.", prometheus.CounterValue, "ssrc", "kind")
+	outboundRTPNACKsReceived = newMetricDesc("webrtc_outbound_rtp_nacks_received_total", "NACK packets received for the SSRC.", prometheus.CounterValue, "ssrc", "kind")
+	outboundRTPPLIsReceived  = newMetricDesc("webrtc_outbound_rtp_plis_received_total", "PLI packets received for the SSRC.", prometheus.CounterValue, "ssrc", "kind")
+	outboundRTPFIRsReceived  = newMetricDesc("webrtc_outbound_rtp_firs_received_total", "FIR packets received for the SSRC.", prometheus.CounterValue, "ssrc", "kind")
+
+	inboundRTPPacketsReceived = newMetricDesc("webrtc_inbound_rtp_packets_received_total", "RTP packets received for the SSRC.", prometheus.CounterValue, "ssrc", "kind")
+	inboundRTPBytesReceived   = newMetricDesc("webrtc_inbound_rtp_bytes_received_total", "RTP payload bytes received for the SSRC.", prometheus.CounterValue, "ssrc", "kind")
+	inboundRTPPacketsLost     = newMetricDesc("webrtc_inbound_rtp_packets_lost", "RTP packets lost for the SSRC as defined in RFC 3550.", prometheus.GaugeValue, "ssrc", "kind")
+	inboundRTPJitter          = newMetricDesc("webrtc_inbound_rtp_jitter_seconds", "Interarrival jitter of the SSRC.", prometheus.GaugeValue, "ssrc", "kind")
+	inboundRTPNACKsSent       = newMetricDesc("webrtc_inbound_rtp_nacks_sent_total", "NACK packets sent for the SSRC.", prometheus.CounterValue, "ssrc", "kind")
+	inboundRTPPLIsSent        = newMetricDesc("webrtc_inbound_rtp_plis_sent_total", "PLI packets sent for the SSRC.", prometheus.CounterValue, "ssrc", "kind")
+	inboundRTPFIRsSent        = newMetricDesc("webrtc_inbound_rtp_firs_sent_total", "FIR packets sent for the SSRC.", prometheus.CounterValue, "ssrc", "kind")
+
+	remoteInboundRTPPacketsLost   = newMetricDesc("webrtc_remote_inbound_rtp_packets_lost", "RTP packets lost for the SSRC as reported by the remote peer.", prometheus.GaugeValue, "ssrc", "kind")
+	remoteInboundRTPFractionLost  = newMetricDesc("webrtc_remote_inbound_rtp_fraction_lost", "Fraction of RTP packets lost as reported by the remote peer.", prometheus.GaugeValue, "ssrc", "kind")
+	remoteInboundRTPJitter        = newMetricDesc("webrtc_remote_inbound_rtp_jitter_seconds", "Interarrival jitter of the SSRC as reported by the remote peer.", prometheus.GaugeValue, "ssrc", "kind")
+	remoteInboundRTPRoundTripTime = newMetricDesc("webrtc_remote_inbound_rtp_round_trip_time_seconds", "Round trip time computed from RTCP reports.", prometheus.GaugeValue, "ssrc", "kind")
+
+	dataChannelMessagesSent     = newMetricDesc("webrtc_data_channel_messages_sent_total", "Messages sent on the DataChannel.", prometheus.CounterValue, "data_channel_id", "label")
+	dataChannelBytesSent        = newMetricDesc("webrtc_data_channel_bytes_sent_total", "Payload bytes sent on the DataChannel.", prometheus.CounterValue, "data_channel_id", "label")
+	dataChannelMessagesReceived = newMetricDesc("webrtc_data_channel_messages_received_total", "Messages received on the DataChannel.", prometheus.CounterValue, "data_channel_id", "label")
+	dataChannelBytesReceived    = newMetricDesc("webrtc_data_channel_bytes_received_total", "Payload bytes received on the DataChannel.", prometheus.CounterValue, "data_channel_id", "label")
+)
+
+// metricDescs are all the exported metrics
+var metricDescs = []metricDesc{
+	peerConnectionDataChannelsOpened, peerConnectionDataChannelsClosed,
+	transportPacketsSent, transportPacketsReceived, transportBytesSent, transportBytesReceived,
+	candidatePairBytesSent, candidatePairBytesReceived, candidatePairCurrentRoundTrip,
+	outboundRTPPacketsSent, outboundRTPBytesSent, outboundRTPNACKsReceived, outboundRTPPLIsReceived, outboundRTPFIRsReceived,
+	inboundRTPPacketsReceived, inboundRTPBytesReceived, inboundRTPPacketsLost, inboundRTPJitter, inboundRTPNACKsSent, inboundRTPPLIsSent, inboundRTPFIRsSent,
+	remoteInboundRTPPacketsLost, remoteInboundRTPFractionLost, remoteInboundRTPJitter, remoteInboundRTPRoundTripTime,
+	dataChannelMessagesSent, dataChannelBytesSent, dataChannelMessagesReceived, dataChannelBytesReceived,
+}
+
+// collectReport sends the metrics of report to ch, the stats are visited in
+// ID order so scrapes are stable
+func collectReport(ch chan<- prometheus.Metric, peerConnectionID string, report webrtc.StatsReport) {
+	ids := make([]string, 0, len(report))
+	for id := range report {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	send := func(m metricDesc, value float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(m.desc, m.valueType, value, append([]string{peerConnectionID}, labels...)...)
+	}
+
+	for _, id := range ids {
+		switch stats := report[id].(type) {
+		case webrtc.PeerConnectionStats:
+			send(peerConnectionDataChannelsOpened, float64(stats.DataChannelsOpened))
+			send(peerConnectionDataChannelsClosed, float64(stats.DataChannelsClosed))
+
+		case webrtc.TransportStats:
+			send(transportPacketsSent, float64(stats.PacketsSent), stats.ID)
+			send(transportPacketsReceived, float64(stats.PacketsReceived), stats.ID)
+			send(transportBytesSent, float64(stats.BytesSent), stats.ID)
+			send(transportBytesReceived, float64(stats.BytesReceived), stats.ID)
+
+		case webrtc.ICECandidatePairStats:
+			nominated := strconv.FormatBool(stats.Nominated)
+			send(candidatePairBytesSent, float64(stats.BytesSent), stats.ID, nominated)
+			send(candidatePairBytesReceived, float64(stats.BytesReceived), stats.ID, nominated)
+			send(candidatePairCurrentRoundTrip, stats.CurrentRoundTripTime, stats.ID, nominated)
+
+		case webrtc.OutboundRTPStreamStats:
+			ssrc := fmt.Sprint(stats.SSRC)
+			send(outboundRTPPacketsSent, float64(stats.PacketsSent), ssrc, stats.Kind)
+			send(outboundRTPBytesSent, float64(stats.BytesSent), ssrc, stats.Kind)
+			send(outboundRTPNACKsReceived, float64(stats.NACKCount), ssrc, stats.Kind)
+			send(outboundRTPPLIsReceived, float64(stats.PLICount), ssrc, stats.Kind)
+			send(outboundRTPFIRsReceived, float64(stats.FIRCount), ssrc, stats.Kind)
+
+		case webrtc.InboundRTPStreamStats:
+			ssrc := fmt.Sprint(stats.SSRC)
+			send(inboundRTPPacketsReceived, float64(stats.PacketsReceived), ssrc, stats.Kind)
+			send(inboundRTPBytesReceived, float64(stats.BytesReceived), ssrc, stats.Kind)
+			send(inboundRTPPacketsLost, float64(stats.PacketsLost), ssrc, stats.Kind)
+			send(inboundRTPJitter, stats.Jitter, ssrc, stats.Kind)
+			send(inboundRTPNACKsSent, float64(stats.NACKCount), ssrc, stats.Kind)
+			send(inboundRTPPLIsSent, float64(stats.PLICount), ssrc, stats.Kind)
+			send(inboundRTPFIRsSent, float64(stats.FIRCount), ssrc, stats.Kind)
+
+		case webrtc.RemoteInboundRTPStreamStats:
+			ssrc := fmt.Sprint(stats.SSRC)
+			send(remoteInboundRTPPacketsLost, float64(stats.PacketsLost), ssrc, stats.Kind)
+			send(remoteInboundRTPFractionLost, stats.FractionLost, ssrc, stats.Kind)
+			send(remoteInboundRTPJitter, stats.Jitter, ssrc, stats.Kind)
+			send(remoteInboundRTPRoundTripTime, stats.RoundTripTime, ssrc, stats.Kind)
+
+		case webrtc.DataChannelStats:
+			send(dataChannelMessagesSent, float64(stats.MessagesSent), stats.ID, stats.Label)
+			send(dataChannelBytesSent, float64(stats.BytesSent), stats.ID, stats.Label)
+			send(dataChannelMessagesReceived, float64(stats.MessagesReceived), stats.ID, stats.Label)
+			send(dataChannelBytesReceived, float64(stats.BytesReceived), stats.ID, stats.Label)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// +build !js
+
+// Package prometheus exports the statistics of PeerConnections as
+// Prometheus metrics
+// https://prometheus.io/docs/concepts/data_model/
+package prometheus
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pion/webrtc/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// StatsGetter is implemented by the objects a Collector can export, like
+// *webrtc.PeerConnection, *webrtc.RTPSender and *webrtc.RTPReceiver
+type StatsGetter interface {
+	GetStats() webrtc.StatsReport
+}
+
+// Collector walks the registered PeerConnections when scraped and exports
+// their stats as labeled metrics. Every metric carries a peer_connection
+// label with the ID it was registered with. Collector implements
+// prometheus.Collector, it is exported by registering it with a
+// prometheus.Registerer.
+type Collector struct {
+	lock    sync.RWMutex
+	getters map[string]StatsGetter
+}
+
+var _ prometheus.Collector = (*Collector)(nil)
+
+// NewCollector creates a Collector without registered PeerConnections
+func NewCollector() *Collector {
+	return &Collector{
+		getters: map[string]StatsGetter{},
+	}
+}
+
+// Register adds getter to the exported objects under id
+func (c *Collector) Register(id string, getter StatsGetter) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.getters[id]; ok {
+		return fmt.Errorf("%q is already registered", id)
+	}
+	c.getters[id] = getter
+	return nil
+}
+
+// Unregister removes the object registered under id, this should be called
+// once a PeerConnection is closed
+func (c *Collector) Unregister(id string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.getters, id)
+}
+
+// Describe sends the descriptors of all metrics the Collector exports
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range metricDescs {
+		ch <- m.desc
+	}
+}
+
+// Collect sends the metrics of all registered objects
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.lock.RLock()
+	ids := make([]string, 0, len(c.getters))
+	for id := range c.getters {
+		ids = append(ids, id)
+	}
+	getters := make([]StatsGetter, 0, len(ids))
+	sort.Strings(ids)
+	for _, id := range ids {
+		getters = append(getters, c.getters[id])
+	}
+	c.lock.RUnlock()
+
+	// GetStats is called without holding the lock, so a slow PeerConnection
+	// doesn't block Register and Unregister
+	for i, getter := range getters {
+		collectReport(ch, ids[i], getter.GetStats())
+	}
+}
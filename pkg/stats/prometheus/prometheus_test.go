@@ -0,0 +1,223 @@
+// +build !js
+
+package prometheus
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/transport/test"
+	"github.com/pion/webrtc/v2"
+	"github.com/pion/webrtc/v2/pkg/media"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/assert"
+)
+
+type staticStats webrtc.StatsReport
+
+func (s staticStats) GetStats() webrtc.StatsReport {
+	return webrtc.StatsReport(s)
+}
+
+func scrape(t *testing.T, c *Collector) string {
+	registry := prometheus.NewPedanticRegistry()
+	assert.NoError(t, registry.Register(c))
+
+	server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, resp.Body.Close())
+	}()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return string(body)
+}
+
+// signalPair connects pcOffer and pcAnswer once all candidates are gathered
+func signalPair(pcOffer, pcAnswer *webrtc.PeerConnection) error {
+	gathered := func(pc *webrtc.PeerConnection) chan struct{} {
+		done := make(chan struct{})
+		pc.OnICECandidate(func(c *webrtc.ICECandidate) {
+			if c == nil {
+				close(done)
+			}
+		})
+		return done
+	}
+
+	offerGathered := gathered(pcOffer)
+	offer, err := pcOffer.CreateOffer(nil)
+	if err != nil {
+		return err
+	}
+	if err = pcOffer.SetLocalDescription(offer); err != nil {
+		return err
+	}
+	<-offerGathered
+	if err = pcAnswer.SetRemoteDescription(*pcOffer.PendingLocalDescription()); err != nil {
+		return err
+	}
+
+	answerGathered := gathered(pcAnswer)
+	answer, err := pcAnswer.CreateAnswer(nil)
+	if err != nil {
+		return err
+	}
+	if err = pcAnswer.SetLocalDescription(answer); err != nil {
+		return err
+	}
+	<-answerGathered
+	return pcOffer.SetRemoteDescription(*pcAnswer.CurrentLocalDescription())
+}
+
+func TestCollector(t *testing.T) {
+	c := NewCollector()
+	assert.NoError(t, c.Register("pc1", staticStats{
+		"OutboundRTPStream-1": webrtc.OutboundRTPStreamStats{
+			Type:        webrtc.StatsTypeOutboundRTP,
+			ID:          "OutboundRTPStream-1",
+			SSRC:        1,
+			Kind:        "video",
+			PacketsSent: 10,
+			BytesSent:   1200,
+			NACKCount:   2,
+		},
+		"InboundRTPStream-2": webrtc.InboundRTPStreamStats{
+			Type:            webrtc.StatsTypeInboundRTP,
+			ID:              "InboundRTPStream-2",
+			SSRC:            2,
+			Kind:            "audio",
+			PacketsReceived: 50,
+			PacketsLost:     3,
+			Jitter:          0.005,
+		},
+		"DataChannel-1": webrtc.DataChannelStats{
+			Type:         webrtc.StatsTypeDataChannel,
+			ID:           "DataChannel-1",
+			Label:        `chat "room"`,
+			MessagesSent: 4,
+		},
+	}))
+	assert.NoError(t, c.Register("pc2", staticStats{
+		"RemoteInboundRTPStream-3": webrtc.RemoteInboundRTPStreamStats{
+			Type:          webrtc.StatsTypeRemoteInboundRTP,
+			ID:            "RemoteInboundRTPStream-3",
+			SSRC:          3,
+			Kind:          "video",
+			RoundTripTime: 0.1,
+			FractionLost:  0.25,
+		},
+	}))
+	assert.Error(t, c.Register("pc2", staticStats{}))
+
+	body := scrape(t, c)
+	for _, line := range []string{
+		"# TYPE webrtc_outbound_rtp_packets_sent_total counter",
+		`webrtc_outbound_rtp_packets_sent_total{kind="video",peer_connection="pc1",ssrc="1"} 10`,
+		`webrtc_outbound_rtp_bytes_sent_total{kind="video",peer_connection="pc1",ssrc="1"} 1200`,
+		`webrtc_outbound_rtp_nacks_received_total{kind="video",peer_connection="pc1",ssrc="1"} 2`,
+		"# TYPE webrtc_inbound_rtp_packets_lost gauge",
+		`webrtc_inbound_rtp_packets_lost{kind="audio",peer_connection="pc1",ssrc="2"} 3`,
+		`webrtc_inbound_rtp_jitter_seconds{kind="audio",peer_connection="pc1",ssrc="2"} 0.005`,
+		`webrtc_data_channel_messages_sent_total{data_channel_id="DataChannel-1",label="chat \"room\"",peer_connection="pc1"} 4`,
+		`webrtc_remote_inbound_rtp_round_trip_time_seconds{kind="video",peer_connection="pc2",ssrc="3"} 0.1`,
+		`webrtc_remote_inbound_rtp_fraction_lost{kind="video",peer_connection="pc2",ssrc="3"} 0.25`,
+	} {
+		assert.Contains(t, strings.Split(body, "\n"), line)
+	}
+
+	// Metrics without samples are omitted
+	assert.NotContains(t, body, "webrtc_transport_bytes_sent_total")
+
+	c.Unregister("pc2")
+	assert.NotContains(t, scrape(t, c), `peer_connection="pc2"`)
+}
+
+func TestCollector_PeerConnection(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	s := webrtc.SettingEngine{}
+	s.SetSenderReportInterval(20 * time.Millisecond)
+	s.SetReceiverReportInterval(20 * time.Millisecond)
+	m := webrtc.MediaEngine{}
+	m.RegisterDefaultCodecs()
+	api := webrtc.NewAPI(webrtc.WithSettingEngine(s), webrtc.WithMediaEngine(m))
+
+	pcOffer, err := api.NewPeerConnection(webrtc.Configuration{})
+	assert.NoError(t, err)
+	pcAnswer, err := api.NewPeerConnection(webrtc.Configuration{})
+	assert.NoError(t, err)
+
+	track, err := pcOffer.NewTrack(webrtc.DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	assert.NoError(t, err)
+	_, err = pcOffer.AddTrack(track)
+	assert.NoError(t, err)
+	_, err = pcAnswer.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo, webrtc.RtpTransceiverInit{Direction: webrtc.RTPTransceiverDirectionRecvonly})
+	assert.NoError(t, err)
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	// Send media until the receiver reports of the answerer yield a round
+	// trip time
+	var report webrtc.StatsReport
+	var remoteInbound webrtc.RemoteInboundRTPStreamStats
+	for remoteInbound.RoundTripTime == 0 {
+		time.Sleep(20 * time.Millisecond)
+		assert.NoError(t, track.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}))
+
+		report = pcOffer.GetStats()
+		for _, s := range report {
+			if stats, ok := s.(webrtc.RemoteInboundRTPStreamStats); ok {
+				remoteInbound = stats
+			}
+		}
+	}
+
+	var transport webrtc.TransportStats
+	for _, s := range report {
+		if stats, ok := s.(webrtc.TransportStats); ok {
+			transport = stats
+		}
+	}
+	pair := report[transport.SelectedCandidatePairID].(webrtc.ICECandidatePairStats)
+
+	// The scrape exports the report that was checked above
+	c := NewCollector()
+	assert.NoError(t, c.Register("pc", staticStats(report)))
+
+	formatFloat := func(f float64) string {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	body := scrape(t, c)
+	for _, line := range []string{
+		`webrtc_peer_connection_data_channels_opened_total{peer_connection="pc"} 0`,
+		fmt.Sprintf(`webrtc_transport_bytes_sent_total{peer_connection="pc",transport_id="%s"} %s`, transport.ID, formatFloat(float64(transport.BytesSent))),
+		fmt.Sprintf(`webrtc_candidate_pair_current_round_trip_time_seconds{candidate_pair_id="%s",nominated="true",peer_connection="pc"} %s`, pair.ID, formatFloat(pair.CurrentRoundTripTime)),
+		fmt.Sprintf(`webrtc_remote_inbound_rtp_round_trip_time_seconds{kind="video",peer_connection="pc",ssrc="%d"} %s`, track.SSRC(), formatFloat(remoteInbound.RoundTripTime)),
+	} {
+		assert.Contains(t, strings.Split(body, "\n"), line)
+	}
+
+	// Scraping a live PeerConnection
+	live := NewCollector()
+	assert.NoError(t, live.Register("pc", pcOffer))
+	assert.Contains(t, scrape(t, live), fmt.Sprintf(`webrtc_remote_inbound_rtp_round_trip_time_seconds{kind="video",peer_connection="pc",ssrc="%d"}`, track.SSRC()))
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
@@ -0,0 +1,157 @@
+// +build !js
+
+package webrtc
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// rembInterval is how often the estimate is sent when it doesn't drop,
+	// it is checked every rembCheckInterval
+	rembInterval      = time.Second
+	rembCheckInterval = 100 * time.Millisecond
+
+	// rembDecreaseThreshold is how far the estimate drops below the last
+	// one sent before it is sent immediately
+	rembDecreaseThreshold = 0.97
+
+	// rembRateWindow is the window the incoming bitrate is measured over
+	rembRateWindow = time.Second
+
+	// rembSSRCTimeout is how long an SSRC is reported without receiving
+	// packets from it
+	rembSSRCTimeout = 2 * time.Second
+)
+
+// rembArrival is a packet of size that arrived at a time
+type rembArrival struct {
+	arrival time.Time
+	size    int
+}
+
+// rembSSRC is an SSRC the estimate concerns and when its last packet arrived
+type rembSSRC struct {
+	ssrc        uint32
+	lastArrival time.Time
+}
+
+// rembEstimator estimates the bitrate packets can be sent to a transport
+// with from the absolute send times and the arrival times of the received
+// packets, the estimate is sent back to the sender in REMB packets.
+// https://tools.ietf.org/html/draft-alvestrand-rmcat-remb-03
+type rembEstimator struct {
+	lock sync.Mutex
+
+	// The send times are unwrapped relative to the first packet, the
+	// arrival times are relative to when the first packet arrived
+	started      bool
+	start        time.Time
+	lastSendTime uint32
+	sendTime     time.Duration
+
+	arrivals []rembArrival
+	ssrcs    []rembSSRC
+
+	delay delayBasedEstimator
+
+	lastReport  time.Time
+	lastBitrate float64
+}
+
+func newREMBEstimator() *rembEstimator {
+	return &rembEstimator{
+		delay: newDelayBasedEstimator(initialTargetBitrate),
+	}
+}
+
+// record adds a packet of size from ssrc that was sent at the absolute
+// send time sendTime and arrived at now
+func (e *rembEstimator) record(ssrc uint32, sendTime uint32, size int, now time.Time) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if !e.started {
+		e.started = true
+		e.start = now
+		e.lastSendTime = sendTime
+	}
+
+	// The difference is sign extended from 24 bits, reordered packets were
+	// sent before the last one
+	diff := int32((sendTime-e.lastSendTime)&absSendTimeMask<<8) >> 8
+	e.lastSendTime = sendTime
+	e.sendTime += time.Duration(diff) * time.Second >> absSendTimeFractionBits
+
+	e.arrivals = append(e.arrivals, rembArrival{arrival: now, size: size})
+	e.addSSRC(ssrc, now)
+	e.delay.addPacket(e.start.Add(e.sendTime), now.Sub(e.start), now)
+}
+
+func (e *rembEstimator) addSSRC(ssrc uint32, now time.Time) {
+	for i := range e.ssrcs {
+		if e.ssrcs[i].ssrc == ssrc {
+			e.ssrcs[i].lastArrival = now
+			return
+		}
+	}
+	e.ssrcs = append(e.ssrcs, rembSSRC{ssrc: ssrc, lastArrival: now})
+}
+
+// activeSSRCs removes the SSRCs that had no packets for rembSSRCTimeout at
+// now and returns the others
+func (e *rembEstimator) activeSSRCs(now time.Time) []uint32 {
+	active := e.ssrcs[:0]
+	ssrcs := []uint32{}
+	for _, s := range e.ssrcs {
+		if now.Sub(s.lastArrival) > rembSSRCTimeout {
+			continue
+		}
+		active = append(active, s)
+		ssrcs = append(ssrcs, s.ssrc)
+	}
+	e.ssrcs = active
+	return ssrcs
+}
+
+// report returns the estimate and the SSRCs it concerns if it is due at
+// now, it is due every rembInterval or when it dropped. SSRCs that stopped
+// sending aren't reported, there is no report without SSRCs.
+// https://tools.ietf.org/html/draft-alvestrand-rmcat-remb-03#section-2
+func (e *rembEstimator) report(now time.Time) (uint64, []uint32, bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if !e.started {
+		return 0, nil, false
+	}
+
+	ssrcs := e.activeSSRCs(now)
+	if len(ssrcs) == 0 {
+		return 0, nil, false
+	}
+
+	// The incoming bitrate is only known once the window is full
+	i := 0
+	for i < len(e.arrivals) && now.Sub(e.arrivals[i].arrival) > rembRateWindow {
+		i++
+	}
+	e.arrivals = e.arrivals[i:]
+	if now.Sub(e.start) >= rembRateWindow {
+		received := 0
+		for _, a := range e.arrivals {
+			received += a.size
+		}
+		e.delay.incoming = float64(received*8) / rembRateWindow.Seconds()
+	}
+	e.delay.update(now)
+
+	bitrate := e.delay.bitrate
+	if !e.lastReport.IsZero() && now.Sub(e.lastReport) < rembInterval && bitrate >= rembDecreaseThreshold*e.lastBitrate {
+		return 0, nil, false
+	}
+	e.lastReport = now
+	e.lastBitrate = bitrate
+	return uint64(bitrate), ssrcs, true
+}
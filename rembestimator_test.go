@@ -0,0 +1,72 @@
+// +build !js
+
+package webrtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestREMBEstimator(t *testing.T) {
+	const size = 1200
+	e := newREMBEstimator()
+	now := time.Unix(0, 0)
+	_, _, ok := e.report(now)
+	assert.False(t, ok)
+
+	// Packets of 1200 bytes are sent every 10ms, about 1Mbit/s, over a link
+	// with capacity bits per second. The absolute send times start right
+	// before they wrap around.
+	sent := time.Unix(63, 900000000)
+	var free, checked time.Time
+	reports := []uint64{}
+	run := func(duration time.Duration, capacity float64) {
+		transmission := time.Duration(size * 8 / capacity * float64(time.Second))
+		for end := now.Add(duration); now.Before(end); now = now.Add(10 * time.Millisecond) {
+			arrival := now.Add(20 * time.Millisecond)
+			if arrival.Before(free) {
+				arrival = free
+			}
+			arrival = arrival.Add(transmission)
+			free = arrival
+			e.record(5000, absSendTime(sent), size, arrival)
+			sent = sent.Add(10 * time.Millisecond)
+
+			if arrival.Sub(checked) >= rembCheckInterval {
+				checked = arrival
+				if bitrate, ssrcs, ok := e.report(arrival); ok {
+					assert.Equal(t, []uint32{5000}, ssrcs)
+					reports = append(reports, bitrate)
+				}
+			}
+		}
+	}
+
+	// Without congestion the estimate follows what is received and is
+	// sent every rembInterval
+	run(20*time.Second, 5000000)
+	assert.True(t, len(reports) >= 20 && len(reports) <= 21, len(reports))
+	assert.True(t, reports[len(reports)-1] > 900000, reports[len(reports)-1])
+
+	// The queue of a link with less capacity delays the packets more and
+	// more, the estimate drops below its capacity and is sent right away
+	reports = reports[:0]
+	run(5*time.Second, 500000)
+	assert.True(t, len(reports) > 5, len(reports))
+	assert.True(t, reports[len(reports)-1] < 500000, reports[len(reports)-1])
+
+	// SSRCs that stopped sending expire, the estimate isn't reported
+	// without SSRCs
+	e.record(6000, absSendTime(sent), size, free)
+	_, ssrcs, ok := e.report(free.Add(rembInterval))
+	assert.True(t, ok)
+	assert.Equal(t, []uint32{5000, 6000}, ssrcs)
+	e.record(6000, absSendTime(sent.Add(1500*time.Millisecond)), size, free.Add(1500*time.Millisecond))
+	_, ssrcs, ok = e.report(free.Add(2500 * time.Millisecond))
+	assert.True(t, ok)
+	assert.Equal(t, []uint32{6000}, ssrcs)
+	_, _, ok = e.report(free.Add(1500*time.Millisecond + rembSSRCTimeout + time.Millisecond))
+	assert.False(t, ok)
+}
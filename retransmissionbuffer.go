@@ -0,0 +1,45 @@
+// +build !js
+
+package webrtc
+
+import "sync"
+
+// retransmissionBuffer keeps the most recently sent RTP packets of a stream
+// so they can be sent again when the remote peer NACKs them
+// https://tools.ietf.org/html/rfc4585#section-6.2.1
+type retransmissionBuffer struct {
+	lock    sync.RWMutex
+	packets [][]byte
+	seqs    []uint16
+}
+
+func newRetransmissionBuffer(size uint16) *retransmissionBuffer {
+	return &retransmissionBuffer{
+		packets: make([][]byte, size),
+		seqs:    make([]uint16, size),
+	}
+}
+
+// add stores the marshaled packet with sequence number seq, replacing the
+// oldest one
+func (b *retransmissionBuffer) add(seq uint16, packet []byte) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	i := int(seq) % len(b.packets)
+	b.packets[i] = packet
+	b.seqs[i] = seq
+}
+
+// get returns the marshaled packet with sequence number seq, or nil if it
+// isn't stored anymore
+func (b *retransmissionBuffer) get(seq uint16) []byte {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	i := int(seq) % len(b.packets)
+	if b.packets[i] == nil || b.seqs[i] != seq {
+		return nil
+	}
+	return b.packets[i]
+}
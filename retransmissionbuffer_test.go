@@ -0,0 +1,25 @@
+// +build !js
+
+package webrtc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetransmissionBuffer(t *testing.T) {
+	b := newRetransmissionBuffer(4)
+	assert.Nil(t, b.get(0))
+
+	for seq := uint16(65533); seq != 2; seq++ {
+		b.add(seq, []byte{byte(seq)})
+	}
+
+	// 65533 was replaced by 1
+	assert.Nil(t, b.get(65533))
+	for _, seq := range []uint16{65534, 65535, 0, 1} {
+		assert.Equal(t, []byte{byte(seq)}, b.get(seq))
+	}
+	assert.Nil(t, b.get(2))
+}
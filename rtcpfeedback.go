@@ -1,5 +1,27 @@
 package webrtc
 
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pion/sdp/v2"
+)
+
+// TypeRTCPFBNACK is the feedback type of Generic NACKs, they request the
+// retransmission of lost RTP packets
+// https://tools.ietf.org/html/rfc4585#section-4.2
+const TypeRTCPFBNACK = "nack"
+
+// TypeRTCPFBTransportCC is the feedback type of transport-cc feedback, it
+// tells when the packets of a transport arrived
+// https://tools.ietf.org/html/draft-holmer-rmcat-transport-wide-cc-extensions-01#section-4.1
+const TypeRTCPFBTransportCC = "transport-cc"
+
+// TypeRTCPFBGoogREMB is the feedback type of Receiver Estimated Maximum
+// Bitrate messages, they tell the sender the bitrate the receiver estimated
+// https://tools.ietf.org/html/draft-alvestrand-rmcat-remb-03
+const TypeRTCPFBGoogREMB = "goog-remb"
+
 // RTCPFeedback signals the connection to use additional RTCP packet types.
 // https://draft.ortc.org/#dom-rtcrtcpfeedback
 type RTCPFeedback struct {
@@ -12,3 +34,66 @@ type RTCPFeedback struct {
 	// For example, type="nack" parameter="pli" will send Picture Loss Indicator packets.
 	Parameter string
 }
+
+// hasRTCPFeedback tells if feedbacks contains feedback
+func hasRTCPFeedback(feedbacks []RTCPFeedback, feedback RTCPFeedback) bool {
+	for _, f := range feedbacks {
+		if f == feedback {
+			return true
+		}
+	}
+	return false
+}
+
+// addRTCPFeedback appends feedback to feedbacks unless it is in there already
+func addRTCPFeedback(feedbacks []RTCPFeedback, feedback RTCPFeedback) []RTCPFeedback {
+	if hasRTCPFeedback(feedbacks, feedback) {
+		return feedbacks
+	}
+	return append(feedbacks, feedback)
+}
+
+// mediaHasRTCPFeedback tells if media signals feedback for payloadType or
+// for all payload types with the wildcard
+// https://tools.ietf.org/html/rfc4585#section-4.2
+func mediaHasRTCPFeedback(media *sdp.MediaDescription, payloadType uint8, feedback RTCPFeedback) bool {
+	want := strings.TrimSpace(fmt.Sprintf("%s %s", feedback.Type, feedback.Parameter))
+	for _, attr := range media.Attributes {
+		if attr.Key != "rtcp-fb" {
+			continue
+		}
+
+		parts := strings.SplitN(attr.Value, " ", 2)
+		if len(parts) != 2 || (parts[0] != "*" && parts[0] != fmt.Sprint(payloadType)) {
+			continue
+		}
+		if strings.TrimSpace(parts[1]) == want {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaRTCPFeedback returns the RTCP feedback a media section signals for
+// payloadType
+func mediaRTCPFeedback(media *sdp.MediaDescription, payloadType uint8) []RTCPFeedback {
+	feedback := []RTCPFeedback{}
+	for _, attr := range media.Attributes {
+		if attr.Key != "rtcp-fb" {
+			continue
+		}
+
+		parts := strings.SplitN(attr.Value, " ", 2)
+		if len(parts) != 2 || (parts[0] != "*" && parts[0] != fmt.Sprint(payloadType)) {
+			continue
+		}
+
+		fields := strings.SplitN(strings.TrimSpace(parts[1]), " ", 2)
+		f := RTCPFeedback{Type: fields[0]}
+		if len(fields) == 2 {
+			f.Parameter = fields[1]
+		}
+		feedback = append(feedback, f)
+	}
+	return feedback
+}
@@ -0,0 +1,13 @@
+package webrtc
+
+// RTPCodecParameters describes a codec negotiated for an RTPSender or
+// RTPReceiver
+// http://draft.ortc.org/#dom-rtcrtpcodecparameters
+type RTPCodecParameters struct {
+	MimeType     string         `json:"mimeType"`
+	PayloadType  uint8          `json:"payloadType"`
+	ClockRate    uint32         `json:"clockRate"`
+	Channels     uint16         `json:"channels"`
+	SDPFmtpLine  string         `json:"sdpFmtpLine"`
+	RTCPFeedback []RTCPFeedback `json:"rtcpFeedback"`
+}
@@ -0,0 +1,18 @@
+package webrtc
+
+// RTPFecParameters describes the Forward Error Correction of an RTP stream,
+// it is unused when Mechanism is unset
+// http://draft.ortc.org/#dom-rtcrtpfecparameters
+type RTPFecParameters struct {
+	Mechanism FECMechanism `json:"mechanism"`
+
+	// SSRC is the SSRC of the FlexFEC stream
+	SSRC uint32 `json:"ssrc"`
+
+	// PayloadType is the payload type of the ULPFEC or FlexFEC packets
+	PayloadType uint8 `json:"payloadType"`
+
+	// REDPayloadType is the payload type of the RED packets that carry the
+	// media and ULPFEC packets of the stream
+	REDPayloadType uint8 `json:"redPayloadType"`
+}
@@ -0,0 +1,237 @@
+package webrtc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+// SDESMidURI is the URI of the header extension that carries the mid of
+// the media section a packet belongs to
+// https://tools.ietf.org/html/rfc8843#section-15
+const SDESMidURI = "urn:ietf:params:rtp-hdrext:sdes:mid"
+
+// SDESRTPStreamIDURI is the URI of the header extension that carries the
+// RID of the RTP stream a packet belongs to
+// https://tools.ietf.org/html/rfc8852#section-3.1
+const SDESRTPStreamIDURI = "urn:ietf:params:rtp-hdrext:sdes:rtp-stream-id"
+
+// RepairedRTPStreamIDURI is the URI of the header extension that carries
+// the RID of the RTP stream the packets of a redundancy stream, like RTX,
+// repair
+// https://tools.ietf.org/html/rfc8852#section-3.2
+const RepairedRTPStreamIDURI = "urn:ietf:params:rtp-hdrext:sdes:repaired-rtp-stream-id"
+
+// AudioLevelURI is the URI of the header extension that carries the level
+// of the audio in a packet
+// https://tools.ietf.org/html/rfc6464#section-4
+const AudioLevelURI = "urn:ietf:params:rtp-hdrext:ssrc-audio-level"
+
+// VideoOrientationURI is the URI of the header extension that carries the
+// orientation the video in a packet is rendered with
+// https://www.3gpp.org/DynaReport/26114.htm
+const VideoOrientationURI = "urn:3gpp:video-orientation"
+
+// TransportCCURI is the URI of the header extension that carries the
+// transport-wide sequence numbers acknowledged by transport-cc feedback
+// https://tools.ietf.org/html/draft-holmer-rmcat-transport-wide-cc-extensions-01#section-2
+const TransportCCURI = "http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01"
+
+// AbsSendTimeURI is the URI of the header extension that carries the time
+// a packet was sent at, the receiver estimates the bandwidth from it for
+// REMB feedback
+// https://webrtc.org/experiments/rtp-hdrext/abs-send-time/
+const AbsSendTimeURI = "http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time"
+
+// RTPHeaderExtension is the payload of the RTP header extension with URI,
+// it is sent and received with the ID negotiated for URI
+// https://tools.ietf.org/html/rfc8285
+type RTPHeaderExtension struct {
+	URI     string
+	Payload []byte
+}
+
+// headerExtensionID returns the ID negotiated for the header extension
+// with uri, it is false if the extension wasn't negotiated
+func headerExtensionID(extensions []RTPHeaderExtensionParameters, uri string) (uint8, bool) {
+	for _, extension := range extensions {
+		if extension.URI == uri && extension.ID > 0 && extension.ID <= 255 {
+			return uint8(extension.ID), true
+		}
+	}
+	return 0, false
+}
+
+// The absolute send time is a 24-bit 6.18 fixed point number of seconds,
+// it wraps around every 64 seconds
+const (
+	absSendTimeFractionBits = 18
+	absSendTimeMask         = 1<<24 - 1
+)
+
+// RTP header extension profiles
+// https://tools.ietf.org/html/rfc8285#section-4
+const (
+	oneByteHeaderExtensionProfile     = 0xBEDE
+	twoByteHeaderExtensionProfile     = 0x1000
+	twoByteHeaderExtensionProfileMask = 0xFFF0
+)
+
+// rtpHeaderExtensionElement is an element of an RTP header extension
+type rtpHeaderExtensionElement struct {
+	id      uint8
+	payload []byte
+}
+
+// parseRTPHeaderExtension returns the elements of the header extension of
+// header, it is empty if there is none
+// https://tools.ietf.org/html/rfc8285#section-4.2
+func parseRTPHeaderExtension(header *rtp.Header) ([]rtpHeaderExtensionElement, error) {
+	if !header.Extension {
+		return nil, nil
+	}
+
+	oneByte := header.ExtensionProfile == oneByteHeaderExtensionProfile
+	if !oneByte && header.ExtensionProfile&twoByteHeaderExtensionProfileMask != twoByteHeaderExtensionProfile {
+		return nil, fmt.Errorf("unknown header extension profile %#x", header.ExtensionProfile)
+	}
+
+	elements := []rtpHeaderExtensionElement{}
+	b := header.ExtensionPayload
+	for len(b) > 0 {
+		// Padding between and after the elements
+		if b[0] == 0 {
+			b = b[1:]
+			continue
+		}
+
+		var id uint8
+		var length int
+		if oneByte {
+			id, length = b[0]>>4, int(b[0]&0x0F)+1
+			b = b[1:]
+
+			// The ID 15 stops the parsing of the extension
+			if id == 15 {
+				break
+			}
+		} else {
+			if len(b) < 2 {
+				return nil, fmt.Errorf("header extension element is too short")
+			}
+			id, length = b[0], int(b[1])
+			b = b[2:]
+		}
+
+		if len(b) < length {
+			return nil, fmt.Errorf("header extension element is too short")
+		}
+		elements = append(elements, rtpHeaderExtensionElement{id: id, payload: b[:length]})
+		b = b[length:]
+	}
+	return elements, nil
+}
+
+// getRTPHeaderExtension returns the payload of the header extension element
+// of header with id
+func getRTPHeaderExtension(header *rtp.Header, id uint8) ([]byte, bool) {
+	elements, err := parseRTPHeaderExtension(header)
+	if err != nil {
+		return nil, false
+	}
+
+	for _, e := range elements {
+		if e.id == id {
+			return e.payload, true
+		}
+	}
+	return nil, false
+}
+
+// setRTPHeaderExtension sets the header extension element of header with id
+// to payload. The one-byte format is used unless an element doesn't fit it.
+// https://tools.ietf.org/html/rfc8285#section-4.3
+func setRTPHeaderExtension(header *rtp.Header, id uint8, payload []byte) error {
+	if id == 0 || len(payload) > 255 {
+		return fmt.Errorf("invalid header extension element %d", id)
+	}
+
+	elements, err := parseRTPHeaderExtension(header)
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i := range elements {
+		if elements[i].id == id {
+			elements[i].payload = payload
+			replaced = true
+		}
+	}
+	if !replaced {
+		elements = append(elements, rtpHeaderExtensionElement{id: id, payload: payload})
+	}
+
+	oneByte := true
+	for _, e := range elements {
+		if e.id > 14 || len(e.payload) == 0 || len(e.payload) > 16 {
+			oneByte = false
+		}
+	}
+
+	b := []byte{}
+	for _, e := range elements {
+		if oneByte {
+			b = append(b, e.id<<4|uint8(len(e.payload)-1))
+		} else {
+			b = append(b, e.id, uint8(len(e.payload)))
+		}
+		b = append(b, e.payload...)
+	}
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+
+	header.Extension = true
+	header.ExtensionProfile = oneByteHeaderExtensionProfile
+	if !oneByte {
+		header.ExtensionProfile = twoByteHeaderExtensionProfile
+	}
+	header.ExtensionPayload = b
+	return nil
+}
+
+// transportCCSequenceNumber returns the transport-wide sequence number of
+// the header extension element of header with id
+func transportCCSequenceNumber(header *rtp.Header, id uint8) (uint16, bool) {
+	payload, ok := getRTPHeaderExtension(header, id)
+	if !ok || len(payload) != 2 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint16(payload), true
+}
+
+// absSendTime returns the absolute send time of t
+func absSendTime(t time.Time) uint32 {
+	fraction := uint64(t.Nanosecond()) << absSendTimeFractionBits / uint64(time.Second)
+	return uint32((uint64(t.Unix())<<absSendTimeFractionBits | fraction) & absSendTimeMask)
+}
+
+// getAbsSendTime returns the absolute send time of the header extension
+// element of header with id
+func getAbsSendTime(header *rtp.Header, id uint8) (uint32, bool) {
+	payload, ok := getRTPHeaderExtension(header, id)
+	if !ok || len(payload) != 3 {
+		return 0, false
+	}
+	return uint32(payload[0])<<16 | uint32(payload[1])<<8 | uint32(payload[2]), true
+}
+
+// setAbsSendTime sets the header extension element of header with id to
+// the absolute send time of t
+func setAbsSendTime(header *rtp.Header, id uint8, t time.Time) error {
+	sendTime := absSendTime(t)
+	return setRTPHeaderExtension(header, id, []byte{byte(sendTime >> 16), byte(sendTime >> 8), byte(sendTime)})
+}
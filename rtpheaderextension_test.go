@@ -0,0 +1,98 @@
+package webrtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRTPHeaderExtension(t *testing.T) {
+	header := &rtp.Header{Version: 2, SSRC: 5000}
+	_, ok := getRTPHeaderExtension(header, 1)
+	assert.False(t, ok)
+
+	assert.NoError(t, setRTPHeaderExtension(header, 5, []byte{0x01, 0x02}))
+	assert.NoError(t, setRTPHeaderExtension(header, 1, []byte{0x03}))
+	assert.True(t, header.Extension)
+	assert.Equal(t, uint16(oneByteHeaderExtensionProfile), header.ExtensionProfile)
+	assert.Equal(t, []byte{0x51, 0x01, 0x02, 0x10, 0x03, 0x00, 0x00, 0x00}, header.ExtensionPayload)
+
+	// Elements are replaced and survive a marshal roundtrip
+	assert.NoError(t, setRTPHeaderExtension(header, 5, []byte{0x04, 0x05}))
+	raw, err := (&rtp.Packet{Header: *header, Payload: []byte{0xFF}}).Marshal()
+	assert.NoError(t, err)
+	p := &rtp.Packet{}
+	assert.NoError(t, p.Unmarshal(raw))
+
+	payload, ok := getRTPHeaderExtension(&p.Header, 5)
+	assert.True(t, ok)
+	assert.Equal(t, []byte{0x04, 0x05}, payload)
+	sequenceNumber, ok := transportCCSequenceNumber(&p.Header, 5)
+	assert.True(t, ok)
+	assert.Equal(t, uint16(0x0405), sequenceNumber)
+	_, ok = transportCCSequenceNumber(&p.Header, 1)
+	assert.False(t, ok)
+	assert.Equal(t, []byte{0xFF}, p.Payload)
+
+	// Elements that don't fit the one-byte format switch to the two-byte one
+	assert.NoError(t, setRTPHeaderExtension(header, 20, make([]byte, 17)))
+	assert.Equal(t, uint16(twoByteHeaderExtensionProfile), header.ExtensionProfile)
+	payload, ok = getRTPHeaderExtension(header, 1)
+	assert.True(t, ok)
+	assert.Equal(t, []byte{0x03}, payload)
+	payload, ok = getRTPHeaderExtension(header, 20)
+	assert.True(t, ok)
+	assert.Equal(t, make([]byte, 17), payload)
+
+	assert.Error(t, setRTPHeaderExtension(header, 0, []byte{0x01}))
+	assert.Error(t, setRTPHeaderExtension(&rtp.Header{Extension: true, ExtensionProfile: 0x1234}, 1, []byte{0x01}))
+}
+
+func TestParseRTPHeaderExtension(t *testing.T) {
+	// Padding is skipped, the ID 15 ends the parsing
+	elements, err := parseRTPHeaderExtension(&rtp.Header{
+		Extension:        true,
+		ExtensionProfile: oneByteHeaderExtensionProfile,
+		ExtensionPayload: []byte{0x10, 0xAA, 0x00, 0x21, 0xBB, 0xCC, 0xF0, 0x31},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []rtpHeaderExtensionElement{
+		{id: 1, payload: []byte{0xAA}},
+		{id: 2, payload: []byte{0xBB, 0xCC}},
+	}, elements)
+
+	_, err = parseRTPHeaderExtension(&rtp.Header{
+		Extension:        true,
+		ExtensionProfile: oneByteHeaderExtensionProfile,
+		ExtensionPayload: []byte{0x13, 0xAA, 0x00, 0x00},
+	})
+	assert.Error(t, err)
+
+	elements, err = parseRTPHeaderExtension(&rtp.Header{
+		Extension:        true,
+		ExtensionProfile: twoByteHeaderExtensionProfile | 0x03,
+		ExtensionPayload: []byte{0x01, 0x00, 0x02, 0x01, 0xAA, 0x00, 0x00, 0x00},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []rtpHeaderExtensionElement{
+		{id: 1, payload: []byte{}},
+		{id: 2, payload: []byte{0xAA}},
+	}, elements)
+}
+
+func TestAbsSendTime(t *testing.T) {
+	// The 6.18 fixed point seconds wrap around every 64 seconds
+	sendTime := time.Unix(65, 500000000)
+	assert.Equal(t, uint32(1<<18|1<<17), absSendTime(sendTime))
+
+	header := &rtp.Header{Version: 2, SSRC: 5000}
+	assert.NoError(t, setAbsSendTime(header, 3, sendTime))
+	assert.Equal(t, []byte{0x32, 0x06, 0x00, 0x00}, header.ExtensionPayload)
+	value, ok := getAbsSendTime(header, 3)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(1<<18|1<<17), value)
+	_, ok = getAbsSendTime(header, 5)
+	assert.False(t, ok)
+}
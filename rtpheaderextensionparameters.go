@@ -0,0 +1,9 @@
+package webrtc
+
+// RTPHeaderExtensionParameters describes a header extension negotiated for
+// an RTPSender or RTPReceiver
+// http://draft.ortc.org/#dom-rtcrtpheaderextensionparameters
+type RTPHeaderExtensionParameters struct {
+	URI string `json:"uri"`
+	ID  int    `json:"id"`
+}
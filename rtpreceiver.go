@@ -3,11 +3,18 @@
 package webrtc
 
 import (
+	"encoding/binary"
 	"fmt"
+	"math/rand"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
 	"github.com/pion/srtp"
+	"github.com/pion/transport/packetio"
+	"github.com/pion/webrtc/v2/pkg/interceptor"
 )
 
 // RTPReceiver allows an application to inspect the receipt of a Track
@@ -15,18 +22,83 @@ type RTPReceiver struct {
 	kind      RTPCodecType
 	transport *DTLSTransport
 
-	track *Track
+	// tracks has the streams of every encoding that is received, the
+	// encodings received by their RID only get them once the SSRC carrying
+	// the RID arrives
+	tracks     []*trackStreams
+	parameters RTPReceiveParameters
 
 	closed, received chan interface{}
 	mu               sync.RWMutex
 
-	rtpReadStream  *srtp.ReadStreamSRTP
-	rtcpReadStream *srtp.ReadStreamSRTCP
+	// rtcpBuffer holds the RTCP read by the readRTCPLoops until the
+	// application reads it
+	rtcpBuffer *packetio.Buffer
+
+	// streamInfos describe the streams to the interceptors
+	streamInfos []*interceptor.StreamInfo
+
+	// rtcpSSRC identifies the RTPReceiver as the sender of Receiver Reports
+	// and NACKs
+	rtcpSSRC uint32
+
+	// headerExtensions were negotiated for the packets of the track.
+	// transportCCExtensionID is set when transport-wide sequence numbers
+	// were negotiated, the arrival of the packets is fed back with them.
+	headerExtensions       []RTPHeaderExtensionParameters
+	transportCCExtensionID uint8
+
+	// absSendTimeExtensionID is set when absolute send times were
+	// negotiated, remb is set when the bitrate estimated from them is fed
+	// back in REMB packets
+	absSendTimeExtensionID uint8
+	remb                   *rembEstimator
+
+	statsID string
 
 	// A reference to the associated api object
 	api *API
 }
 
+// trackStreams are the streams an encoding of the track is received with
+type trackStreams struct {
+	track *Track
+
+	rtpReadStream  *srtp.ReadStreamSRTP
+	rtcpReadStream *srtp.ReadStreamSRTCP
+
+	// rtpBuffer holds the packets read off the streams of the encoding
+	// until the application reads them, the packets of the RTX stream are
+	// merged into it when rtxReadStream is set
+	rtxReadStream *srtp.ReadStreamSRTP
+	rtpBuffer     *packetio.Buffer
+
+	// fec describes how the encoding is protected, rtpBuffer also holds the
+	// packets recovered by fecDecoder. fecReadStream is set for the
+	// FlexFEC stream of the encoding.
+	fec           RTPFecParameters
+	fecDecoder    *fecDecoder
+	fecReadStream *srtp.ReadStreamSRTP
+
+	// rtpReader, rtxReader and fecReader read the packets of the streams
+	// through the interceptors
+	rtpReader  interceptor.RTPReader
+	rtxReader  interceptor.RTPReader
+	fecReader  interceptor.RTPReader
+	rtcpReader interceptor.RTCPReader
+
+	// nacks is set when NACK feedback was negotiated for the track
+	nacks *nackGenerator
+
+	inbound   rtpStreamCounters
+	reception rtpReceptionStats
+	feedback  rtcpFeedbackCounters
+
+	// retransmitted counts the packets repaired from the RTX stream, they
+	// are kept out of the loss accounting of the stream
+	retransmitted rtpStreamCounters
+}
+
 // NewRTPReceiver constructs a new RTPReceiver
 func (api *API) NewRTPReceiver(kind RTPCodecType, transport *DTLSTransport) (*RTPReceiver, error) {
 	if transport == nil {
@@ -37,6 +109,8 @@ func (api *API) NewRTPReceiver(kind RTPCodecType, transport *DTLSTransport) (*RT
 		kind:      kind,
 		transport: transport,
 		api:       api,
+		statsID:   newStatsID("RTPReceiver"),
+		rtcpSSRC:  rand.Uint32(),
 		closed:    make(chan interface{}),
 		received:  make(chan interface{}),
 	}, nil
@@ -50,14 +124,32 @@ func (r *RTPReceiver) Transport() *DTLSTransport {
 	return r.transport
 }
 
-// Track returns the RTCRtpTransceiver track
+// Track returns the RTCRtpTransceiver track, it is the one of the first
+// encoding when several are received
 func (r *RTPReceiver) Track() *Track {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	return r.track
+	if len(r.tracks) == 0 {
+		return nil
+	}
+	return r.tracks[0].track
+}
+
+// Tracks returns the tracks of all encodings that are received, like the
+// layers of simulcast
+func (r *RTPReceiver) Tracks() []*Track {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tracks := []*Track{}
+	for _, t := range r.tracks {
+		tracks = append(tracks, t.track)
+	}
+	return tracks
 }
 
-// Receive initialize the track and starts all the transports
+// Receive initialize the track and starts all the transports. Encodings
+// with an SSRC are received right away, the ones with only a RID once the
+// SSRC carrying it is known.
 func (r *RTPReceiver) Receive(parameters RTPReceiveParameters) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -66,20 +158,108 @@ func (r *RTPReceiver) Receive(parameters RTPReceiveParameters) error {
 		return fmt.Errorf("Receive has already been called")
 	default:
 	}
+	if len(parameters.Encodings) == 0 {
+		return fmt.Errorf("Receive needs at least one encoding")
+	}
 	close(r.received)
 
-	r.track = &Track{
-		kind:     r.kind,
-		ssrc:     parameters.Encodings.SSRC,
-		receiver: r,
+	r.parameters = parameters
+	r.headerExtensions = parameters.HeaderExtensions
+	r.transportCCExtensionID, _ = headerExtensionID(r.headerExtensions, TransportCCURI)
+	r.absSendTimeExtensionID, _ = headerExtensionID(r.headerExtensions, AbsSendTimeURI)
+
+	r.rtcpBuffer = packetio.NewBuffer()
+	r.rtcpBuffer.SetLimitSize(rtcpBufferSize)
+
+	for _, encoding := range parameters.Encodings {
+		t := &trackStreams{track: &Track{
+			kind:     r.kind,
+			ssrc:     encoding.SSRC,
+			rid:      encoding.RID,
+			receiver: r,
+		}}
+		r.tracks = append(r.tracks, t)
+
+		if encoding.SSRC == 0 {
+			continue
+		}
+		if err := r.receiveEncoding(t, encoding, nil); err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+// receiveForRID receives the encoding with rid on the stream with ssrc,
+// first is the packet of the stream that was read to learn its RID. It
+// returns the track of the encoding, it is false if no encoding with rid is
+// waiting for its stream.
+func (r *RTPReceiver) receiveForRID(rid string, ssrc uint32, first []byte) (*Track, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
+	if !r.hasReceived() || r.hasStopped() {
+		return nil, false, nil
+	}
+
+	for i, t := range r.tracks {
+		if t.track.RID() != rid || t.track.SSRC() != 0 {
+			continue
+		}
+
+		t.track.mu.Lock()
+		t.track.ssrc = ssrc
+		t.track.mu.Unlock()
+
+		encoding := r.parameters.Encodings[i]
+		encoding.SSRC = ssrc
+		return t.track, true, r.receiveEncoding(t, encoding, first)
+	}
+	return nil, false, nil
+}
+
+// receiveRTXForRID receives the RTX stream with ssrc of the encoding with
+// rid, first is the packet of the stream that was read to learn its RID. It
+// is false if the encoding isn't received or already has an RTX stream.
+// https://tools.ietf.org/html/rfc8852#section-3.2
+func (r *RTPReceiver) receiveRTXForRID(rid string, ssrc uint32, first []byte) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.hasReceived() || r.hasStopped() {
+		return false, nil
+	}
+
+	for _, t := range r.tracks {
+		if t.track.RID() != rid || t.track.SSRC() == 0 || t.rtxReadStream != nil || t.rtpBuffer == nil {
+			continue
+		}
+
+		srtpSession, err := r.transport.getSRTPSession()
+		if err != nil {
+			return false, err
+		}
+		t.rtxReadStream, err = srtpSession.OpenReadStream(ssrc)
+		if err != nil {
+			return false, err
+		}
+		t.rtxReader = r.bindRemoteStream(t, firstRTPReader(first, srtpReader(t.rtxReadStream)), ssrc, rtpPayloadType(first))
+		go r.readRTXLoop(t)
+		return true, nil
+	}
+	return false, nil
+}
+
+// receiveEncoding opens the streams of an encoding of the track and starts
+// reading them, first is the packet of the media stream that was read
+// before if any. r.mu is held.
+func (r *RTPReceiver) receiveEncoding(t *trackStreams, encoding RTPDecodingParameters, first []byte) error {
 	srtpSession, err := r.transport.getSRTPSession()
 	if err != nil {
 		return err
 	}
 
-	r.rtpReadStream, err = srtpSession.OpenReadStream(parameters.Encodings.SSRC)
+	t.rtpReadStream, err = srtpSession.OpenReadStream(encoding.SSRC)
 	if err != nil {
 		return err
 	}
@@ -89,10 +269,68 @@ func (r *RTPReceiver) Receive(parameters RTPReceiveParameters) error {
 		return err
 	}
 
-	r.rtcpReadStream, err = srtcpSession.OpenReadStream(parameters.Encodings.SSRC)
+	t.rtcpReadStream, err = srtcpSession.OpenReadStream(encoding.SSRC)
 	if err != nil {
 		return err
 	}
+	t.rtcpReader = r.transport.interceptor.BindRTCPReader(srtcpReader(t.rtcpReadStream))
+
+	// The codec of the track is only known once its first packet arrives,
+	// the interceptors get the one the remote peer prefers
+	payloadType := uint8(0)
+	if len(r.parameters.Codecs) != 0 {
+		payloadType = r.parameters.Codecs[0].PayloadType
+	}
+	t.rtpReader = r.bindRemoteStream(t, firstRTPReader(first, srtpReader(t.rtpReadStream)), encoding.SSRC, payloadType)
+
+	t.fec = encoding.FEC
+	if t.fec.Mechanism == FECMechanismFlexFEC && t.fec.SSRC != 0 {
+		t.fecReadStream, err = srtpSession.OpenReadStream(t.fec.SSRC)
+		if err != nil {
+			return err
+		}
+		t.fecReader = r.bindRemoteStream(t, srtpReader(t.fecReadStream), t.fec.SSRC, t.fec.PayloadType)
+	}
+
+	if encoding.RTX.SSRC != 0 {
+		t.rtxReadStream, err = srtpSession.OpenReadStream(encoding.RTX.SSRC)
+		if err != nil {
+			return err
+		}
+		t.rtxReader = r.bindRemoteStream(t, srtpReader(t.rtxReadStream), encoding.RTX.SSRC, encoding.RTX.PayloadType)
+	}
+
+	// The decoder has to be in place before the first packet is read, the
+	// read loops use it without locking
+	if t.fec.Mechanism != FECMechanism(Unknown) {
+		t.fecDecoder = newFECDecoder(encoding.SSRC)
+	}
+
+	// The packets are counted as they arrive rather than when the
+	// application reads them, so the arrival times of the statistics and
+	// feedback are accurate
+	t.rtpBuffer = packetio.NewBuffer()
+	t.rtpBuffer.SetLimitSize(rtpBufferSize)
+	go r.readRTPLoop(t)
+	if t.rtxReadStream != nil {
+		go r.readRTXLoop(t)
+	}
+	if t.fecReadStream != nil {
+		go r.readFlexFECLoop(t)
+	}
+
+	go r.readRTCPLoop(t)
+
+	interval := defaultReceiverReportInterval
+	if r.api.settingEngine.rtcp.ReceiverReportInterval != nil {
+		interval = *r.api.settingEngine.rtcp.ReceiverReportInterval
+	}
+	if interval > 0 {
+		go r.sendReportLoop(t, interval)
+	}
+	if r.transportCCExtensionID != 0 {
+		go r.sendTransportCCLoop(t)
+	}
 
 	return nil
 }
@@ -100,7 +338,7 @@ func (r *RTPReceiver) Receive(parameters RTPReceiveParameters) error {
 // Read reads incoming RTCP for this RTPReceiver
 func (r *RTPReceiver) Read(b []byte) (n int, err error) {
 	<-r.received
-	return r.rtcpReadStream.Read(b)
+	return r.rtcpBuffer.Read(b)
 }
 
 // ReadRTCP is a convenience method that wraps Read and unmarshals for you
@@ -127,11 +365,18 @@ func (r *RTPReceiver) Stop() error {
 
 	select {
 	case <-r.received:
-		if err := r.rtcpReadStream.Close(); err != nil {
+		// The RTCP of all encodings is read from one buffer, it ends when
+		// the RTPReceiver stops rather than with the stream of one of them
+		if err := r.rtcpBuffer.Close(); err != nil {
 			return err
 		}
-		if err := r.rtpReadStream.Close(); err != nil {
-			return err
+		for _, info := range r.streamInfos {
+			r.transport.interceptor.UnbindRemoteStream(info)
+		}
+		for _, t := range r.tracks {
+			if err := t.close(); err != nil {
+				return err
+			}
 		}
 	default:
 	}
@@ -140,8 +385,653 @@ func (r *RTPReceiver) Stop() error {
 	return nil
 }
 
+// GetHeaderExtension returns the payload of the header extension with uri
+// of a packet read from the track, it is false if the extension wasn't
+// negotiated or the packet doesn't carry it
+func (r *RTPReceiver) GetHeaderExtension(header *rtp.Header, uri string) ([]byte, bool) {
+	r.mu.RLock()
+	id, ok := headerExtensionID(r.headerExtensions, uri)
+	r.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	return getRTPHeaderExtension(header, id)
+}
+
+// receivesSSRC tells if the stream with ssrc is received by the RTPReceiver
+func (r *RTPReceiver) receivesSSRC(ssrc uint32) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, info := range r.streamInfos {
+		if info.SSRC == ssrc {
+			return true
+		}
+	}
+	return false
+}
+
+// close closes the streams of the encoding that were opened
+func (t *trackStreams) close() error {
+	if t.rtcpReadStream != nil {
+		if err := t.rtcpReadStream.Close(); err != nil {
+			return err
+		}
+	}
+	for _, stream := range []*srtp.ReadStreamSRTP{t.rtpReadStream, t.rtxReadStream, t.fecReadStream} {
+		if stream == nil {
+			continue
+		}
+		if err := stream.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bindRemoteStream binds the interceptors to the stream with ssrc of the
+// encoding of t and returns the reader its packets are read with, r.mu is
+// held
+func (r *RTPReceiver) bindRemoteStream(t *trackStreams, reader interceptor.RTPReader, ssrc uint32, payloadType uint8) interceptor.RTPReader {
+	info := newInterceptorStreamInfo(t.track.ID(), ssrc, payloadType, r.parameters.Codecs, r.parameters.HeaderExtensions, nil)
+	r.streamInfos = append(r.streamInfos, info)
+	return r.transport.interceptor.BindRemoteStream(info, reader)
+}
+
+// firstRTPReader returns a reader that returns first, a packet that was
+// read from reader before, and then the packets of reader
+func firstRTPReader(first []byte, reader interceptor.RTPReader) interceptor.RTPReader {
+	if first == nil {
+		return reader
+	}
+
+	var once sync.Once
+	return interceptor.RTPReaderFunc(func(b []byte, attributes interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n := -1
+		once.Do(func() {
+			n = copy(b, first)
+		})
+		if n == -1 {
+			return reader.Read(b, attributes)
+		}
+		if n < len(first) {
+			return 0, attributes, fmt.Errorf("buffer is too short for the packet")
+		}
+		return n, attributes, nil
+	})
+}
+
+// rtpPayloadType returns the payload type of the RTP packet b
+func rtpPayloadType(b []byte) uint8 {
+	if len(b) < 2 {
+		return 0
+	}
+	return b[1] & 0x7f
+}
+
+// trackStreamsFor returns the streams of the encoding of track
+func (r *RTPReceiver) trackStreamsFor(track *Track) *trackStreams {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, t := range r.tracks {
+		if t.track == track {
+			return t
+		}
+	}
+	return nil
+}
+
+// readRTCPLoop reads the RTCP sent to the source of an encoding, keeps its
+// Sender Reports and buffers it for Read
+func (r *RTPReceiver) readRTCPLoop(t *trackStreams) {
+	ssrc := t.track.SSRC()
+	b := make([]byte, receiveMTU)
+	for {
+		n, _, err := t.rtcpReader.Read(b, interceptor.Attributes{})
+		if err != nil {
+			return
+		}
+
+		if pkts, err := rtcp.Unmarshal(b[:n]); err == nil {
+			now := time.Now()
+			for _, pkt := range pkts {
+				if sr, ok := pkt.(*rtcp.SenderReport); ok && sr.SSRC == ssrc {
+					t.reception.senderReport(sr, now)
+				}
+			}
+		}
+
+		if err := bufferPacket(r.rtcpBuffer, b[:n]); err != nil {
+			return
+		}
+	}
+}
+
+// sendReportLoop sends an RTCP Receiver Report for the source of an
+// encoding every interval until the RTPReceiver is stopped
+// https://tools.ietf.org/html/rfc3550#section-6.4.2
+func (r *RTPReceiver) sendReportLoop(t *trackStreams, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	ssrc := t.track.SSRC()
+	for {
+		select {
+		case <-r.closed:
+			return
+		case <-ticker.C:
+			report, ok := t.reception.receptionReport(ssrc, time.Now())
+			if !ok {
+				continue
+			}
+
+			// Failed reports are dropped, like lost ones
+			_ = r.transport.writeRTCP([]rtcp.Packet{&rtcp.ReceiverReport{
+				SSRC:    r.rtcpSSRC,
+				Reports: []rtcp.ReceptionReport{report},
+			}})
+		}
+	}
+}
+
+// sendTransportCCLoop sends transport-cc feedback every transportCCInterval
+// until the RTPReceiver is stopped. The feedback is for all packets of the
+// transport, every encoding sends what arrived since the last one.
+func (r *RTPReceiver) sendTransportCCLoop(t *trackStreams) {
+	ticker := time.NewTicker(transportCCInterval)
+	defer ticker.Stop()
+
+	ssrc := t.track.SSRC()
+	recorder := r.transport.transportCCRecorder
+	for {
+		select {
+		case <-r.closed:
+			return
+		case now := <-ticker.C:
+			if !recorder.pending() {
+				continue
+			}
+
+			// SRTCP sessions route RTCP by the SSRCs it concerns, the feedback
+			// is preceded by a Receiver Report so it reaches the sender of
+			// ssrc in compound RTCP
+			// https://tools.ietf.org/html/rfc3550#section-6.1
+			report, ok := t.reception.receptionReport(ssrc, now)
+			if !ok {
+				continue
+			}
+			feedback := recorder.feedback(r.rtcpSSRC, ssrc)
+			if feedback == nil {
+				continue
+			}
+
+			// Failed feedback is dropped, like lost feedback
+			_ = r.transport.writeRTCP([]rtcp.Packet{
+				&rtcp.ReceiverReport{
+					SSRC:    r.rtcpSSRC,
+					Reports: []rtcp.ReceptionReport{report},
+				},
+				feedback,
+			})
+		}
+	}
+}
+
+// enableNACK starts sending Generic NACKs for the packets missing from
+// track, it has to be called once the encoding of track is received
+func (r *RTPReceiver) enableNACK(track *Track) {
+	t := r.trackStreamsFor(track)
+	if t == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t.nacks != nil || t.rtpReader == nil {
+		return
+	}
+	t.nacks = newNACKGenerator()
+	go r.sendNACKLoop(t, t.nacks)
+}
+
+// enableREMB starts sending REMB packets with the bitrate estimated from
+// the absolute send times of the packets received on the transport, it has
+// to be called after Receive
+func (r *RTPReceiver) enableREMB() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.remb != nil || len(r.tracks) == 0 || r.absSendTimeExtensionID == 0 {
+		return
+	}
+	r.remb = r.transport.rembEstimator
+	go r.sendREMBLoop(r.remb)
+}
+
+// sendREMBLoop sends the estimate when it is due until the RTPReceiver is
+// stopped, the estimate of the transport is sent by the first RTPReceiver
+// it is due for
+func (r *RTPReceiver) sendREMBLoop(remb *rembEstimator) {
+	ticker := time.NewTicker(rembCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.closed:
+			return
+		case now := <-ticker.C:
+			bitrate, ssrcs, ok := remb.report(now)
+			if !ok {
+				continue
+			}
+
+			// Failed feedback is dropped, like lost feedback
+			_ = r.transport.writeRTCP([]rtcp.Packet{&rtcp.ReceiverEstimatedMaximumBitrate{
+				SenderSSRC: r.rtcpSSRC,
+				Bitrate:    float32(bitrate),
+				SSRCs:      ssrcs,
+			}})
+		}
+	}
+}
+
+// sendNACKLoop sends the NACKs for the source of an encoding that are due
+// every nackInterval until the RTPReceiver is stopped
+func (r *RTPReceiver) sendNACKLoop(t *trackStreams, nacks *nackGenerator) {
+	ticker := time.NewTicker(nackInterval)
+	defer ticker.Stop()
+
+	ssrc := t.track.SSRC()
+	for {
+		select {
+		case <-r.closed:
+			return
+		case now := <-ticker.C:
+			pairs := nacks.nackPairs(now)
+			if len(pairs) == 0 {
+				continue
+			}
+
+			pkts := []rtcp.Packet{&rtcp.TransportLayerNack{
+				SenderSSRC: r.rtcpSSRC,
+				MediaSSRC:  ssrc,
+				Nacks:      pairs,
+			}}
+			if err := r.transport.writeRTCP(pkts); err == nil {
+				t.feedback.count(pkts, ssrc)
+			}
+		}
+	}
+}
+
+// readRTPLoop moves the packets of an encoding of the track into rtpBuffer
+func (r *RTPReceiver) readRTPLoop(t *trackStreams) {
+	defer func() {
+		_ = t.rtpBuffer.Close()
+	}()
+
+	b := make([]byte, receiveMTU)
+	for {
+		n, _, err := t.rtpReader.Read(b, interceptor.Attributes{})
+		if err != nil {
+			return
+		}
+
+		if err := r.handleRTP(t, b[:n]); err != nil {
+			return
+		}
+	}
+}
+
+// handleRTP counts a packet received on the stream of the track, unwraps
+// its media or ULPFEC packet if it is a RED packet and passes that on to
+// the FEC decoder. Invalid packets are dropped.
+// https://tools.ietf.org/html/rfc5109#section-14.1
+func (r *RTPReceiver) handleRTP(t *trackStreams, b []byte) error {
+	packet := &rtp.Packet{}
+	if err := packet.Unmarshal(b); err != nil {
+		return nil
+	}
+	r.countRTP(t, &packet.Header, len(packet.Payload))
+
+	if t.fec.REDPayloadType == 0 || packet.PayloadType != t.fec.REDPayloadType {
+		return r.bufferMedia(t, b)
+	}
+
+	blockPayloadType, block, err := unmarshalRED(packet.Payload)
+	if err != nil {
+		return nil
+	}
+
+	if blockPayloadType == t.fec.PayloadType {
+		if t.fec.Mechanism != FECMechanismULPFEC {
+			return nil
+		}
+
+		p, err := unmarshalULPFEC(block, packet.SSRC)
+		if err != nil {
+			return nil
+		}
+		return r.bufferRecovered(t, t.fecDecoder.addFEC(p))
+	}
+
+	packet.PayloadType = blockPayloadType
+	packet.Payload = block
+	raw, err := packet.Marshal()
+	if err != nil {
+		return nil
+	}
+	return r.bufferMedia(t, raw)
+}
+
+// readFlexFECLoop passes the packets of the FlexFEC stream of an encoding
+// of the track to the FEC decoder
+func (r *RTPReceiver) readFlexFECLoop(t *trackStreams) {
+	b := make([]byte, receiveMTU)
+	for {
+		n, _, err := t.fecReader.Read(b, interceptor.Attributes{})
+		if err != nil {
+			return
+		}
+
+		packet := &rtp.Packet{}
+		if err := packet.Unmarshal(b[:n]); err != nil || packet.PayloadType != t.fec.PayloadType {
+			continue
+		}
+		r.recordArrival(&packet.Header, n, time.Now())
+
+		p, err := unmarshalFlexFEC(packet.Payload)
+		if err != nil {
+			continue
+		}
+		if err := r.bufferRecovered(t, t.fecDecoder.addFEC(p)); err != nil {
+			return
+		}
+	}
+}
+
+// bufferMedia moves a media packet of an encoding of the track into
+// rtpBuffer with the packets recovered with it, packets that have been
+// recovered before are dropped
+func (r *RTPReceiver) bufferMedia(t *trackStreams, b []byte) error {
+	if t.fecDecoder == nil {
+		return t.bufferRTP(b)
+	}
+
+	isNew, recovered := t.fecDecoder.addMedia(append([]byte{}, b...))
+	if !isNew {
+		return nil
+	}
+	if err := t.bufferRTP(b); err != nil {
+		return err
+	}
+	return r.bufferRecovered(t, recovered)
+}
+
+// bufferRecovered moves the recovered packets into rtpBuffer, they are no
+// longer missing for the NACKs
+func (r *RTPReceiver) bufferRecovered(t *trackStreams, packets [][]byte) error {
+	r.mu.RLock()
+	nacks := t.nacks
+	r.mu.RUnlock()
+
+	now := time.Now()
+	for _, packet := range packets {
+		if nacks != nil {
+			nacks.received(rtpSequenceNumber(packet), now)
+		}
+		if err := t.bufferRTP(packet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bufferRTP moves a packet into rtpBuffer
+func (t *trackStreams) bufferRTP(b []byte) error {
+	return bufferPacket(t.rtpBuffer, b)
+}
+
+// bufferPacket writes a packet to buffer for the application to read, it is
+// dropped if the application doesn't keep up with reading
+func bufferPacket(buffer *packetio.Buffer, b []byte) error {
+	if _, err := buffer.Write(b); err != nil && err != packetio.ErrFull {
+		return err
+	}
+	return nil
+}
+
+// readRTXLoop restores the packets of the RTX stream of an encoding to the
+// packets that were retransmitted and moves them into rtpBuffer
+// https://tools.ietf.org/html/rfc4588#section-4
+func (r *RTPReceiver) readRTXLoop(t *trackStreams) {
+	r.mu.RLock()
+	reader := t.rtxReader
+	associatedPayloadTypes := rtxAssociatedPayloadTypes(r.parameters.Codecs)
+	r.mu.RUnlock()
+
+	ssrc := t.track.SSRC()
+	b := make([]byte, receiveMTU)
+	for {
+		n, _, err := reader.Read(b, interceptor.Attributes{})
+		if err != nil {
+			return
+		}
+
+		packet := &rtp.Packet{}
+		if err := packet.Unmarshal(b[:n]); err != nil || len(packet.Payload) < 2 {
+			continue
+		}
+
+		// The payload type of the original packet is the one the RTX
+		// payload type was negotiated for
+		// https://tools.ietf.org/html/rfc4588#section-8.1
+		payloadType, ok := associatedPayloadTypes[packet.PayloadType]
+		if !ok {
+			continue
+		}
+
+		packet.SSRC = ssrc
+		packet.PayloadType = payloadType
+		packet.SequenceNumber = binary.BigEndian.Uint16(packet.Payload)
+		packet.Payload = packet.Payload[2:]
+
+		raw, err := packet.Marshal()
+		if err != nil {
+			continue
+		}
+		r.countRetransmission(t, &packet.Header, len(packet.Payload))
+		if err := r.bufferMedia(t, raw); err != nil {
+			return
+		}
+	}
+}
+
+// rtxAssociatedPayloadTypes returns the payload types the RTX codecs of
+// codecs retransmit by the payload types of the RTX codecs
+func rtxAssociatedPayloadTypes(codecs []RTPCodecParameters) map[uint8]uint8 {
+	payloadTypes := map[uint8]uint8{}
+	for _, codec := range codecs {
+		if !strings.HasSuffix(strings.ToLower(codec.MimeType), "/"+RTX) {
+			continue
+		}
+		if apt, ok := rtxAssociatedPayloadType(codec.SDPFmtpLine); ok {
+			payloadTypes[codec.PayloadType] = apt
+		}
+	}
+	return payloadTypes
+}
+
 // readRTP should only be called by a track, this only exists so we can keep state in one place
-func (r *RTPReceiver) readRTP(b []byte) (n int, err error) {
+func (r *RTPReceiver) readRTP(b []byte, track *Track) (n int, err error) {
 	<-r.received
-	return r.rtpReadStream.Read(b)
+
+	t := r.trackStreamsFor(track)
+	r.mu.RLock()
+	if t == nil || t.rtpReader == nil {
+		r.mu.RUnlock()
+		return 0, fmt.Errorf("the encoding of the track is not received yet")
+	}
+	rtpBuffer := t.rtpBuffer
+	r.mu.RUnlock()
+
+	// The packets have been counted when they were received
+	return rtpBuffer.Read(b)
+}
+
+// countRTP updates the statistics and NACKs of an encoding of the track
+// with a received packet
+func (r *RTPReceiver) countRTP(t *trackStreams, header *rtp.Header, payloadLength int) {
+	t.inbound.count(header, payloadLength)
+
+	clockRate := uint32(0)
+	if codec := t.track.Codec(); codec != nil {
+		clockRate = codec.ClockRate
+	}
+	now := time.Now()
+	t.reception.update(header, clockRate, now)
+	r.recordArrival(header, header.MarshalSize()+payloadLength, now)
+
+	r.mu.RLock()
+	nacks := t.nacks
+	r.mu.RUnlock()
+	if nacks != nil {
+		nacks.received(header.SequenceNumber, now)
+	}
+}
+
+// countRetransmission updates the statistics and NACKs of an encoding of
+// the track with a packet repaired from its RTX stream. The gap it fills
+// was a loss of the stream, so the reception statistics are left alone.
+func (r *RTPReceiver) countRetransmission(t *trackStreams, header *rtp.Header, payloadLength int) {
+	t.retransmitted.count(header, payloadLength)
+
+	now := time.Now()
+	r.recordArrival(header, header.MarshalSize()+payloadLength, now)
+
+	r.mu.RLock()
+	nacks := t.nacks
+	r.mu.RUnlock()
+	if nacks != nil {
+		nacks.received(header.SequenceNumber, now)
+	}
+}
+
+// recordArrival records the arrival of a packet of size for the
+// transport-cc feedback or the REMB estimate
+func (r *RTPReceiver) recordArrival(header *rtp.Header, size int, now time.Time) {
+	if r.transportCCExtensionID != 0 {
+		if sequenceNumber, ok := transportCCSequenceNumber(header, r.transportCCExtensionID); ok {
+			r.transport.transportCCRecorder.record(sequenceNumber, now)
+		}
+	}
+
+	r.mu.RLock()
+	remb := r.remb
+	r.mu.RUnlock()
+	if remb != nil {
+		if sendTime, ok := getAbsSendTime(header, r.absSendTimeExtensionID); ok {
+			remb.record(header.SSRC, sendTime, size, now)
+		}
+	}
+}
+
+// countFeedback counts the feedback about the sources of the tracks in pkts
+// that is sent with WriteRTCP
+func (r *RTPReceiver) countFeedback(pkts []rtcp.Packet) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, t := range r.tracks {
+		if ssrc := t.track.SSRC(); ssrc != 0 {
+			t.feedback.count(pkts, ssrc)
+		}
+	}
+}
+
+// collectStats adds the stats of the RTPReceiver and the inbound RTP streams
+// of its tracks to report
+func (r *RTPReceiver) collectStats(report StatsReport, transportID string) {
+	r.mu.RLock()
+	tracks := append([]*trackStreams{}, r.tracks...)
+	r.mu.RUnlock()
+
+	if len(tracks) == 0 {
+		return
+	}
+
+	now := statsTimestampFrom(time.Now())
+	if r.kind == RTPCodecTypeAudio {
+		report[r.statsID] = AudioReceiverStats{
+			Timestamp: now,
+			Type:      StatsTypeReceiver,
+			ID:        r.statsID,
+		}
+	} else {
+		report[r.statsID] = VideoReceiverStats{
+			Timestamp: now,
+			Type:      StatsTypeReceiver,
+			ID:        r.statsID,
+		}
+	}
+
+	for _, t := range tracks {
+		track := t.track
+		if track.SSRC() == 0 {
+			continue
+		}
+
+		packets, bytes, lastPacket := t.inbound.get()
+		retransmittedPackets, retransmittedBytes, _ := t.retransmitted.get()
+		packetsLost, jitter := t.reception.get()
+		nacks, plis, firs := t.feedback.get()
+		stats := InboundRTPStreamStats{
+			Timestamp:                    now,
+			Type:                         StatsTypeInboundRTP,
+			ID:                           fmt.Sprintf("InboundRTPStream-%d", track.SSRC()),
+			SSRC:                         track.SSRC(),
+			Kind:                         track.Kind().String(),
+			TransportID:                  transportID,
+			CodecID:                      collectCodecStats(report, track.Codec(), CodecTypeDecode, transportID),
+			FIRCount:                     firs,
+			PLICount:                     plis,
+			NACKCount:                    nacks,
+			PacketsReceived:              packets,
+			PacketsLost:                  packetsLost,
+			Jitter:                       jitter,
+			ReceiverID:                   r.statsID,
+			LastPacketReceivedTimestamp:  lastPacket,
+			RetransmittedPacketsReceived: retransmittedPackets,
+			RetransmittedBytesReceived:   retransmittedBytes,
+			BytesReceived:                bytes,
+		}
+		report[stats.ID] = stats
+	}
+}
+
+// GetStats returns the stats of the RTPReceiver and its inbound RTP stream.
+// It is empty until the RTPReceiver has started receiving.
+// https://www.w3.org/TR/webrtc/#dom-rtcrtpreceiver-getstats
+func (r *RTPReceiver) GetStats() StatsReport {
+	report := StatsReport{}
+	r.collectStats(report, r.Transport().statsID)
+	return report
+}
+
+// hasReceived tells if Receive has been called for this instance
+func (r *RTPReceiver) hasReceived() bool {
+	select {
+	case <-r.received:
+		return true
+	default:
+		return false
+	}
+}
+
+// hasStopped tells if Stop has been called for this instance
+func (r *RTPReceiver) hasStopped() bool {
+	select {
+	case <-r.closed:
+		return true
+	default:
+		return false
+	}
 }
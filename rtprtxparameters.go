@@ -0,0 +1,9 @@
+package webrtc
+
+// RTPRtxParameters describes the RTX stream that retransmissions of an RTP
+// stream are sent on, it is unused when SSRC is zero
+// http://draft.ortc.org/#dom-rtcrtprtxparameters
+type RTPRtxParameters struct {
+	SSRC        uint32 `json:"ssrc"`
+	PayloadType uint8  `json:"payloadType"`
+}
@@ -3,22 +3,61 @@
 package webrtc
 
 import (
+	"encoding/binary"
 	"fmt"
+	"math/rand"
 	"sync"
+	"time"
 
 	"github.com/pion/ice"
 	"github.com/pion/rtcp"
 	"github.com/pion/rtp"
 	"github.com/pion/srtp"
+	"github.com/pion/transport/packetio"
+	"github.com/pion/webrtc/v2/pkg/interceptor"
 )
 
 // RTPSender allows an application to control how a given Track is encoded and transmitted to a remote peer
 type RTPSender struct {
-	track          *Track
-	rtcpReadStream *srtp.ReadStreamSRTCP
+	track *Track
+
+	// encodings are the encodings of the track that are sent, the layers
+	// of simulcast are sent from one track each
+	encodings []*trackEncoding
+
+	// rtcpBuffer holds the RTCP read by the readRTCPLoops until the
+	// application reads it
+	rtcpBuffer *packetio.Buffer
+
+	// streamInfos describe the streams to the interceptors
+	streamInfos []*interceptor.StreamInfo
+
+	// historySize is the number of sent packets every encoding keeps when
+	// NACKs are answered
+	historySize uint16
+
+	fecMechanism FECMechanism
+	fecOverhead  uint8
+
+	// headerExtensions were negotiated for the packets of the sender.
+	// transportCCExtensionID and absSendTimeExtensionID are set when
+	// transport-wide sequence numbers and absolute send times were
+	// negotiated, they are stamped on all packets of the sender.
+	headerExtensions       []RTPHeaderExtensionParameters
+	transportCCExtensionID uint8
+	absSendTimeExtensionID uint8
 
 	transport *DTLSTransport
 
+	// streamIDs are the ids of the media streams the track is signaled with
+	streamIDs []string
+
+	// mid is the mid of the media section the sender was negotiated in,
+	// the layers of simulcast carry it in their packets
+	mid string
+
+	statsID string
+
 	// A reference to the associated api object
 	api *API
 
@@ -26,6 +65,66 @@ type RTPSender struct {
 	sendCalled, stopCalled chan interface{}
 }
 
+// trackEncoding is an encoding of the media that is sent and the streams it
+// is sent with
+type trackEncoding struct {
+	track *Track
+
+	// payloadType was negotiated for the codec of the track, the packets
+	// of the track are sent with it
+	payloadType uint8
+
+	rtcpReadStream *srtp.ReadStreamSRTCP
+	rtcpReader     interceptor.RTCPReader
+
+	// rtpWriter sends the packets of the track through the interceptors,
+	// rtxWriter and fecWriter the ones of its RTX and FlexFEC streams
+	rtpWriter interceptor.RTPWriter
+	rtxWriter interceptor.RTPWriter
+	fecWriter interceptor.RTPWriter
+
+	// retransmission holds the sent packets when NACKs are answered
+	retransmission *retransmissionBuffer
+
+	// rtxSSRC is signaled for the RTX stream of the encoding, rtx is set by
+	// Send when retransmissions are sent on it
+	rtxSSRC           uint32
+	rtx               RTPRtxParameters
+	rtxSequenceNumber uint16
+
+	// fecSSRC is signaled for the FlexFEC stream of the encoding, fec is
+	// set by Send when the media is protected with FEC packets
+	fecSSRC uint32
+	fec     *fecEncoder
+
+	// extensions are set on the media packets and rtxExtensions on the
+	// retransmissions of the encoding, the layers of simulcast are
+	// identified by them
+	extensions    []RTPHeaderExtension
+	rtxExtensions []RTPHeaderExtension
+
+	outbound      rtpStreamCounters
+	feedback      rtcpFeedbackCounters
+	remoteInbound remoteInboundStats
+}
+
+func newTrackEncoding(track *Track) *trackEncoding {
+	return &trackEncoding{
+		track:   track,
+		rtxSSRC: rand.Uint32(),
+		fecSSRC: rand.Uint32(),
+	}
+}
+
+// trackEncodings returns the encodings of a sender of track, there are none
+// without a track
+func trackEncodings(track *Track) []*trackEncoding {
+	if track == nil {
+		return nil
+	}
+	return []*trackEncoding{newTrackEncoding(track)}
+}
+
 // NewRTPSender constructs a new RTPSender
 func (api *API) NewRTPSender(track *Track, transport *DTLSTransport) (*RTPSender, error) {
 	if track == nil {
@@ -34,20 +133,48 @@ func (api *API) NewRTPSender(track *Track, transport *DTLSTransport) (*RTPSender
 		return nil, fmt.Errorf("DTLSTransport must not be nil")
 	}
 
-	track.mu.RLock()
-	defer track.mu.RUnlock()
+	track.mu.Lock()
+	defer track.mu.Unlock()
 	if track.receiver != nil {
 		return nil, fmt.Errorf("RTPSender can not be constructed with remote track")
 	}
 	track.totalSenderCount++
 
+	return api.newRTPSender(track, transport), nil
+}
+
+// newRTPSender constructs an RTPSender that may not have a track yet
+func (api *API) newRTPSender(track *Track, transport *DTLSTransport) *RTPSender {
 	return &RTPSender{
 		track:      track,
+		encodings:  trackEncodings(track),
 		transport:  transport,
 		api:        api,
+		statsID:    newStatsID("RTPSender"),
 		sendCalled: make(chan interface{}),
 		stopCalled: make(chan interface{}),
-	}, nil
+	}
+}
+
+// Track returns the Track that is sent, it is nil if no track has been
+// added or the track was removed. It is the track of the first encoding
+// when simulcast is sent.
+func (r *RTPSender) Track() *Track {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.track
+}
+
+// Tracks returns the tracks of all encodings that are sent, the layers of
+// simulcast are written to them separately
+func (r *RTPSender) Tracks() []*Track {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tracks := []*Track{}
+	for _, e := range r.encodings {
+		tracks = append(tracks, e.track)
+	}
+	return tracks
 }
 
 // Transport returns the currently-configured *DTLSTransport or nil
@@ -58,30 +185,243 @@ func (r *RTPSender) Transport() *DTLSTransport {
 	return r.transport
 }
 
+// setSimulcastEncodings makes the sender send simulcast with a layer for
+// every encoding, identified by its RID. The track of the sender is the
+// first layer, the others get tracks like it with the SSRC of the encoding
+// or a random one. The RIDs have been validated.
+// https://tools.ietf.org/html/rfc8853
+func (r *RTPSender) setSimulcastEncodings(encodings []RTPEncodingParameters) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.track == nil {
+		return fmt.Errorf("RTPSender has no track to send")
+	} else if r.hasSent() {
+		return fmt.Errorf("Send has already been called")
+	}
+
+	r.track.mu.Lock()
+	r.track.rid = encodings[0].RID
+	r.track.mu.Unlock()
+
+	for _, encoding := range encodings[1:] {
+		ssrc := encoding.SSRC
+		if ssrc == 0 {
+			ssrc = rand.Uint32()
+		}
+
+		track, err := NewTrack(r.track.PayloadType(), ssrc, r.track.ID(), r.track.Label(), r.track.Codec())
+		if err != nil {
+			return err
+		}
+		track.rid = encoding.RID
+		track.totalSenderCount++
+
+		r.encodings = append(r.encodings, newTrackEncoding(track))
+	}
+	return nil
+}
+
+// validRID tells if rid can identify an RTP stream, WebRTC restricts it to
+// 16 alphanumeric characters
+// https://w3c.github.io/webrtc-pc/#dom-rtcpeerconnection-addtransceiver (step #8)
+func validRID(rid string) bool {
+	if rid == "" || len(rid) > 16 {
+		return false
+	}
+	for _, c := range rid {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// getRIDs returns the RIDs of the layers of simulcast that are sent, it is
+// empty unless the sender has several encodings
+func (r *RTPSender) getRIDs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.encodings) < 2 {
+		return nil
+	}
+
+	rids := []string{}
+	for _, e := range r.encodings {
+		rids = append(rids, e.track.RID())
+	}
+	return rids
+}
+
+// encodingForTrack returns the encoding that sends track
+func (r *RTPSender) encodingForTrack(track *Track) *trackEncoding {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, e := range r.encodings {
+		if e.track == track {
+			return e
+		}
+	}
+	return nil
+}
+
+// EnableRetransmission makes the RTPSender keep the last historySize sent
+// packets and send them again when the remote peer requests them with a
+// Generic NACK. The nack RTCP feedback is signaled for the codecs of the
+// sender, so this has to be called before the offer or answer is created.
+func (r *RTPSender) EnableRetransmission(historySize uint16) error {
+	if historySize == 0 {
+		return fmt.Errorf("historySize must be greater than zero")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.historySize = historySize
+	return nil
+}
+
+// retransmissionEnabled tells if EnableRetransmission has been called
+func (r *RTPSender) retransmissionEnabled() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.historySize != 0
+}
+
+// EnableFEC makes the RTPSender protect the sent packets with FEC packets
+// of mechanism, so lost packets can be recovered by the remote peer without
+// retransmissions. overhead is the number of FEC packets sent per 100 media
+// packets, from 1 to 100. The FEC codecs have to be negotiated and FlexFEC
+// streams are signaled, so this has to be called before the offer or answer
+// is created. The layers of simulcast can only be protected with ULPFEC,
+// their FlexFEC streams can't be signaled without SSRCs.
+func (r *RTPSender) EnableFEC(mechanism FECMechanism, overhead uint8) error {
+	if mechanism != FECMechanismULPFEC && mechanism != FECMechanismFlexFEC {
+		return fmt.Errorf("FEC mechanism %s is not supported", mechanism)
+	} else if overhead == 0 || overhead > 100 {
+		return fmt.Errorf("overhead must be between 1 and 100")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if mechanism == FECMechanismFlexFEC && len(r.encodings) > 1 {
+		return fmt.Errorf("FEC mechanism %s is not supported for the layers of simulcast", mechanism)
+	}
+	r.fecMechanism = mechanism
+	r.fecOverhead = overhead
+	return nil
+}
+
+// enabledFECMechanism returns the mechanism passed to EnableFEC
+func (r *RTPSender) enabledFECMechanism() FECMechanism {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.fecMechanism
+}
+
 // Send Attempts to set the parameters controlling the sending of media.
+// Every encoding is sent from the track with its SSRC, the tracks of
+// encodings that are left out aren't sent.
 func (r *RTPSender) Send(parameters RTPSendParameters) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	if r.hasSent() {
 		return fmt.Errorf("Send has already been called")
+	} else if r.track == nil {
+		return fmt.Errorf("RTPSender has no track to send")
+	} else if len(parameters.Encodings) == 0 {
+		return fmt.Errorf("Send needs at least one encoding")
 	}
 
+	r.headerExtensions = parameters.HeaderExtensions
+	r.transportCCExtensionID, _ = headerExtensionID(r.headerExtensions, TransportCCURI)
+	r.absSendTimeExtensionID, _ = headerExtensionID(r.headerExtensions, AbsSendTimeURI)
+
+	r.rtcpBuffer = packetio.NewBuffer()
+	r.rtcpBuffer.SetLimitSize(rtcpBufferSize)
+
+	for _, encoding := range parameters.Encodings {
+		var e *trackEncoding
+		for _, candidate := range r.encodings {
+			if candidate.track.SSRC() == encoding.SSRC {
+				e = candidate
+				break
+			}
+		}
+		if e == nil {
+			return fmt.Errorf("no track to send SSRC %d", encoding.SSRC)
+		}
+
+		if err := r.sendEncoding(e, encoding, parameters); err != nil {
+			return err
+		}
+	}
+
+	close(r.sendCalled)
+	return nil
+}
+
+// sendEncoding opens the streams of an encoding and starts sending its
+// track, r.mu is held
+func (r *RTPSender) sendEncoding(e *trackEncoding, encoding RTPEncodingParameters, parameters RTPSendParameters) error {
 	srtcpSession, err := r.transport.getSRTCPSession()
 	if err != nil {
 		return err
 	}
 
-	r.rtcpReadStream, err = srtcpSession.OpenReadStream(parameters.Encodings.SSRC)
+	e.rtcpReadStream, err = srtcpSession.OpenReadStream(encoding.SSRC)
 	if err != nil {
 		return err
 	}
+	e.rtcpReader = r.transport.interceptor.BindRTCPReader(srtcpReader(e.rtcpReadStream))
+	e.payloadType = encoding.PayloadType
 
-	r.track.mu.Lock()
-	r.track.activeSenders = append(r.track.activeSenders, r)
-	r.track.mu.Unlock()
+	// The sequence numbers of the RTX stream start at a random value
+	// https://tools.ietf.org/html/rfc4588#section-4
+	e.rtx = encoding.RTX
+	e.rtxSequenceNumber = uint16(rand.Uint32())
+	if r.historySize != 0 {
+		e.retransmission = newRetransmissionBuffer(r.historySize)
+	}
 
-	close(r.sendCalled)
+	if encoding.FEC.Mechanism != FECMechanism(Unknown) && r.fecOverhead != 0 {
+		e.fec = newFECEncoder(encoding.FEC, r.fecOverhead)
+	}
+
+	// The layers of simulcast carry their RID, the retransmissions the RID
+	// of the layer they repair
+	// https://tools.ietf.org/html/rfc8852#section-3
+	if encoding.RID != "" {
+		e.extensions = []RTPHeaderExtension{{URI: SDESRTPStreamIDURI, Payload: []byte(encoding.RID)}}
+		e.rtxExtensions = []RTPHeaderExtension{{URI: RepairedRTPStreamIDURI, Payload: []byte(encoding.RID)}}
+		if r.mid != "" {
+			mid := RTPHeaderExtension{URI: SDESMidURI, Payload: []byte(r.mid)}
+			e.extensions = append(e.extensions, mid)
+			e.rtxExtensions = append(e.rtxExtensions, mid)
+		}
+	}
+
+	e.rtpWriter = r.bindLocalStream(e, encoding.SSRC, encoding.PayloadType, parameters)
+	if e.rtx.SSRC != 0 {
+		e.rtxWriter = r.bindLocalStream(e, e.rtx.SSRC, e.rtx.PayloadType, parameters)
+	}
+	if e.fec != nil && encoding.FEC.Mechanism == FECMechanismFlexFEC {
+		e.fecWriter = r.bindLocalStream(e, encoding.FEC.SSRC, encoding.FEC.PayloadType, parameters)
+	}
+
+	go r.readRTCPLoop(e)
+
+	interval := defaultSenderReportInterval
+	if r.api.settingEngine.rtcp.SenderReportInterval != nil {
+		interval = *r.api.settingEngine.rtcp.SenderReportInterval
+	}
+	if interval > 0 {
+		go r.sendReportLoop(e, interval)
+	}
+
+	e.track.mu.Lock()
+	e.track.activeSenders = append(e.track.activeSenders, r)
+	e.track.mu.Unlock()
 	return nil
 }
 
@@ -96,30 +436,78 @@ func (r *RTPSender) Stop() error {
 	default:
 	}
 
-	r.track.mu.Lock()
-	defer r.track.mu.Unlock()
-	filtered := []*RTPSender{}
-	for _, s := range r.track.activeSenders {
-		if s != r {
-			filtered = append(filtered, s)
-		} else {
-			r.track.totalSenderCount--
-		}
-	}
-	r.track.activeSenders = filtered
 	close(r.stopCalled)
 
+	// The RTCP of all encodings is read from one buffer, it ends when the
+	// RTPSender stops rather than with the stream of one of them
 	if r.hasSent() {
-		return r.rtcpReadStream.Close()
+		if err := r.rtcpBuffer.Close(); err != nil {
+			return err
+		}
+	}
+
+	if r.track == nil {
+		return nil
 	}
 
+	for _, e := range r.encodings {
+		e.track.mu.Lock()
+		filtered := []*RTPSender{}
+		for _, s := range e.track.activeSenders {
+			if s != r {
+				filtered = append(filtered, s)
+			}
+		}
+		e.track.activeSenders = filtered
+		e.track.totalSenderCount-- // Senders that never started sending are counted too
+		e.track.mu.Unlock()
+	}
+
+	if !r.hasSent() {
+		return nil
+	}
+
+	for _, info := range r.streamInfos {
+		r.transport.interceptor.UnbindLocalStream(info)
+	}
+	for _, e := range r.encodings {
+		if e.rtcpReadStream == nil {
+			continue
+		}
+		if err := e.rtcpReadStream.Close(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// bindLocalStream binds the interceptors to the stream with ssrc of an
+// encoding and returns the writer its packets are sent with
+func (r *RTPSender) bindLocalStream(e *trackEncoding, ssrc uint32, payloadType uint8, parameters RTPSendParameters) interceptor.RTPWriter {
+	info := newInterceptorStreamInfo(e.track.ID(), ssrc, payloadType, parameters.Codecs, parameters.HeaderExtensions, e.track.Codec())
+	r.streamInfos = append(r.streamInfos, info)
+	return r.transport.interceptor.BindLocalStream(info, interceptor.RTPWriterFunc(r.writeRTP))
+}
+
+// writeRTP is the last writer of the interceptors, it sends a packet on the
+// SRTP session
+func (r *RTPSender) writeRTP(header *rtp.Header, payload []byte, _ interceptor.Attributes) (int, error) {
+	srtpSession, err := r.transport.getSRTPSession()
+	if err != nil {
+		return 0, err
+	}
+
+	writeStream, err := srtpSession.OpenWriteStream()
+	if err != nil {
+		return 0, err
+	}
+	return writeStream.WriteRTP(header, payload)
+}
+
 // Read reads incoming RTCP for this RTPReceiver
 func (r *RTPSender) Read(b []byte) (n int, err error) {
 	<-r.sendCalled
-	return r.rtcpReadStream.Read(b)
+	return r.rtcpBuffer.Read(b)
 }
 
 // ReadRTCP is a convenience method that wraps Read and unmarshals for you
@@ -133,28 +521,370 @@ func (r *RTPSender) ReadRTCP() ([]rtcp.Packet, error) {
 	return rtcp.Unmarshal(b[:i])
 }
 
-// sendRTP should only be called by a track, this only exists so we can keep state in one place
-func (r *RTPSender) sendRTP(header *rtp.Header, payload []byte) (int, error) {
+// readRTCPLoop reads the RTCP sent to the source of an encoding, keeps the
+// statistics learned from it and buffers it for Read
+func (r *RTPSender) readRTCPLoop(e *trackEncoding) {
+	b := make([]byte, receiveMTU)
+	for {
+		n, _, err := e.rtcpReader.Read(b, interceptor.Attributes{})
+		if err != nil {
+			return
+		}
+
+		if pkts, err := rtcp.Unmarshal(b[:n]); err == nil {
+			r.handleRTCP(pkts, e)
+		}
+
+		if err := bufferPacket(r.rtcpBuffer, b[:n]); err != nil {
+			return
+		}
+	}
+}
+
+// handleRTCP updates the statistics of the outbound stream of an encoding
+// from the received RTCP
+func (r *RTPSender) handleRTCP(pkts []rtcp.Packet, e *trackEncoding) {
+	now := time.Now()
+	ssrc := e.track.SSRC()
+	e.feedback.count(pkts, ssrc)
+	for _, pkt := range pkts {
+		var reports []rtcp.ReceptionReport
+		switch p := pkt.(type) {
+		case *rtcp.ReceiverReport:
+			reports = p.Reports
+		case *rtcp.SenderReport:
+			reports = p.Reports
+		case *rtcp.TransportLayerNack:
+			if p.MediaSSRC == ssrc {
+				r.retransmit(e, p.Nacks)
+			}
+		case *rtcp.TransportLayerCC:
+			// The feedback concerns all packets of the transport
+			r.transport.bandwidthEstimator.handleFeedback(p, now)
+		case *rtcp.ReceiverEstimatedMaximumBitrate:
+			r.transport.bandwidthEstimator.handleREMB(uint64(p.Bitrate))
+		}
+
+		for _, report := range reports {
+			if report.SSRC == ssrc {
+				e.remoteInbound.update(report, now)
+			}
+		}
+	}
+}
+
+// sendReportLoop sends an RTCP Sender Report for the source of an encoding
+// every interval until the RTPSender is stopped
+// https://tools.ietf.org/html/rfc3550#section-6.4.1
+func (r *RTPSender) sendReportLoop(e *trackEncoding, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stopCalled:
+			return
+		case <-ticker.C:
+			// Failed reports are dropped, like lost ones
+			_ = r.sendReport(e)
+		}
+	}
+}
+
+// sendReport sends a Sender Report mapping the current wall clock time to
+// the RTP timestamp of the track, nothing is sent before the first packet
+func (r *RTPSender) sendReport(e *trackEncoding) error {
+	packets, bytes, _ := e.outbound.get()
+	if packets == 0 {
+		return nil
+	}
+
+	track := e.track
+	ssrc := track.SSRC()
+	now := time.Now()
+	rtpTime, lastPacketTime := e.outbound.lastPacket()
+	if codec := track.Codec(); codec != nil {
+		rtpTime += uint32(now.Sub(lastPacketTime).Seconds() * float64(codec.ClockRate))
+	}
+
+	// Reports are compound packets with the CNAME of the source, pion/srtp
+	// delivers RTCP by the SSRCs it is about and the SDES chunk is the only
+	// part of the packet that carries ours
+	// https://tools.ietf.org/html/rfc3550#section-6.1
+	return r.transport.writeRTCP([]rtcp.Packet{
+		&rtcp.SenderReport{
+			SSRC:        ssrc,
+			NTPTime:     ntpTime(now),
+			RTPTime:     rtpTime,
+			PacketCount: packets,
+			OctetCount:  uint32(bytes),
+		},
+		&rtcp.SourceDescription{Chunks: []rtcp.SourceDescriptionChunk{{
+			Source: ssrc,
+			Items:  []rtcp.SourceDescriptionItem{{Type: rtcp.SDESCNAME, Text: track.Label()}},
+		}}},
+	})
+}
+
+// retransmit sends the NACKed packets of an encoding again that are still
+// in its retransmission buffer
+func (r *RTPSender) retransmit(e *trackEncoding, nacks []rtcp.NackPair) {
+	r.mu.RLock()
+	buffer := e.retransmission
+	rtx := e.rtx
+	r.mu.RUnlock()
+
+	if buffer == nil {
+		return
+	}
+
+	for i := range nacks {
+		for _, seq := range nacks[i].PacketList() {
+			packet := buffer.get(seq)
+			if packet == nil {
+				continue
+			}
+
+			// Failed retransmissions are dropped, like lost ones
+			if rtx.SSRC == 0 {
+				p := &rtp.Packet{}
+				if err := p.Unmarshal(packet); err == nil {
+					r.setHeaderExtensions(&p.Header, p.Payload)
+					_, _ = e.rtpWriter.Write(&p.Header, p.Payload, interceptor.Attributes{})
+				}
+			} else if header, payload, err := r.rtxPacket(e, packet); err == nil {
+				if r.setExtensions(header, e.rtxExtensions) == nil {
+					r.setHeaderExtensions(header, payload)
+					_, _ = e.rtxWriter.Write(header, payload, interceptor.Attributes{})
+				}
+			}
+		}
+	}
+}
+
+// rtxPacket wraps the marshaled packet for the RTX stream, its payload is
+// prefixed with the original sequence number
+// https://tools.ietf.org/html/rfc4588#section-4
+func (r *RTPSender) rtxPacket(e *trackEncoding, packet []byte) (*rtp.Header, []byte, error) {
+	p := &rtp.Packet{}
+	if err := p.Unmarshal(packet); err != nil {
+		return nil, nil, err
+	}
+
+	payload := make([]byte, 2+len(p.Payload))
+	binary.BigEndian.PutUint16(payload, p.SequenceNumber)
+	copy(payload[2:], p.Payload)
+
+	r.mu.Lock()
+	p.SequenceNumber = e.rtxSequenceNumber
+	e.rtxSequenceNumber++
+	r.mu.Unlock()
+
+	p.SSRC = e.rtx.SSRC
+	p.PayloadType = e.rtx.PayloadType
+	return &p.Header, payload, nil
+}
+
+// sendRTP should only be called by a track, this only exists so we can keep state in one place.
+// The header extensions that were negotiated are set on the packet.
+func (r *RTPSender) sendRTP(track *Track, header *rtp.Header, payload []byte, extensions []RTPHeaderExtension) (int, error) {
 	select {
 	case <-r.stopCalled:
 		return 0, fmt.Errorf("RTPSender has been stopped")
 	case <-r.sendCalled:
-		srtpSession, err := r.transport.getSRTPSession()
-		if err != nil {
-			return 0, err
+		e := r.encodingForTrack(track)
+		if e == nil || e.rtpWriter == nil {
+			return 0, nil
 		}
 
-		writeStream, err := srtpSession.OpenWriteStream()
-		if err != nil {
+		r.mu.RLock()
+		buffer := e.retransmission
+		fec := e.fec
+		payloadType := e.payloadType
+		r.mu.RUnlock()
+
+		// The media packet is numbered before it is protected, so the
+		// packets recovered from FEC are the ones that were sent
+		media := &rtp.Packet{Header: *header, Payload: payload}
+		media.PayloadType = payloadType
+		if err := r.setExtensions(&media.Header, extensions); err != nil {
+			return 0, err
+		} else if err := r.setExtensions(&media.Header, e.extensions); err != nil {
 			return 0, err
 		}
+		r.setHeaderExtensions(&media.Header, media.Payload)
+		packets := []*rtp.Packet{media}
+		if fec != nil {
+			var err error
+			if media, packets, err = fec.encode(media); err != nil {
+				return 0, err
+			}
+			for _, p := range packets[1:] {
+				r.setHeaderExtensions(&p.Header, p.Payload)
+			}
+		}
+
+		// Only a failure to send the media packet is returned, failed FEC
+		// packets are dropped like lost ones
+		n := 0
+		for i, p := range packets {
+			writer := e.rtpWriter
+			if p.SSRC != media.SSRC {
+				writer = e.fecWriter
+			}
+
+			written, writeErr := writer.Write(&p.Header, p.Payload, interceptor.Attributes{})
+			if writeErr == ice.ErrNoCandidatePairs {
+				continue
+			} else if writeErr != nil {
+				if i == 0 {
+					return written, writeErr
+				}
+				continue
+			}
+
+			if i == 0 {
+				n = written
+			}
+			if p.SSRC == media.SSRC {
+				e.outbound.count(&p.Header, len(p.Payload))
+			}
+		}
+
+		if buffer != nil {
+			if raw, err := media.Marshal(); err == nil {
+				buffer.add(media.SequenceNumber, raw)
+			}
+		}
+		return n, nil
+	}
+}
+
+// setExtensions sets the header extensions that were negotiated on header
+// with their negotiated IDs
+func (r *RTPSender) setExtensions(header *rtp.Header, extensions []RTPHeaderExtension) error {
+	for _, extension := range extensions {
+		if id, ok := headerExtensionID(r.headerExtensions, extension.URI); ok {
+			if err := setRTPHeaderExtension(header, id, extension.Payload); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setHeaderExtensions stamps the absolute send time and the next
+// transport-wide sequence number on the header of a packet that is about to
+// be sent, if they were negotiated
+// https://tools.ietf.org/html/draft-holmer-rmcat-transport-wide-cc-extensions-01#section-2
+func (r *RTPSender) setHeaderExtensions(header *rtp.Header, payload []byte) {
+	now := time.Now()
+
+	// Packets with a header extension of another profile are sent without
+	if r.absSendTimeExtensionID != 0 {
+		_ = setAbsSendTime(header, r.absSendTimeExtensionID, now)
+	}
+
+	if r.transportCCExtensionID != 0 {
+		b := make([]byte, 2)
+		binary.BigEndian.PutUint16(b, r.transport.bandwidthEstimator.sent(header.MarshalSize()+len(payload), now))
+		_ = setRTPHeaderExtension(header, r.transportCCExtensionID, b)
+	}
+}
+
+// collectStats adds the stats of the RTPSender and the outbound RTP streams
+// of its encodings to report
+func (r *RTPSender) collectStats(report StatsReport, transportID string) {
+	r.mu.RLock()
+	track := r.track
+	encodings := append([]*trackEncoding{}, r.encodings...)
+	r.mu.RUnlock()
+
+	if track == nil || !r.hasSent() {
+		return
+	}
 
-		n, err := writeStream.WriteRTP(header, payload)
-		if err == ice.ErrNoCandidatePairs {
-			err = nil
+	now := statsTimestampFrom(time.Now())
+	kind := track.Kind().String()
+	if track.Kind() == RTPCodecTypeAudio {
+		report[r.statsID] = AudioSenderStats{
+			Timestamp:       now,
+			Type:            StatsTypeSender,
+			ID:              r.statsID,
+			TrackIdentifier: track.ID(),
+			Ended:           r.hasStopped(),
+			Kind:            kind,
+		}
+	} else {
+		report[r.statsID] = VideoSenderStats{
+			Timestamp: now,
+			Type:      StatsTypeSender,
+			ID:        r.statsID,
 		}
-		return n, err
 	}
+
+	for _, e := range encodings {
+		// The tracks of encodings that aren't sent have no stream
+		r.mu.RLock()
+		sent := e.rtpWriter != nil
+		r.mu.RUnlock()
+		if !sent {
+			continue
+		}
+
+		codecID := collectCodecStats(report, e.track.Codec(), CodecTypeEncode, transportID)
+		packets, bytes, lastPacket := e.outbound.get()
+		nacks, plis, firs := e.feedback.get()
+		stats := OutboundRTPStreamStats{
+			Timestamp:               now,
+			Type:                    StatsTypeOutboundRTP,
+			ID:                      fmt.Sprintf("OutboundRTPStream-%d", e.track.SSRC()),
+			SSRC:                    e.track.SSRC(),
+			Kind:                    kind,
+			TransportID:             transportID,
+			CodecID:                 codecID,
+			FIRCount:                firs,
+			PLICount:                plis,
+			NACKCount:               nacks,
+			PacketsSent:             packets,
+			BytesSent:               bytes,
+			SenderID:                r.statsID,
+			LastPacketSentTimestamp: lastPacket,
+		}
+
+		rr, rrTime, roundTripTime := e.remoteInbound.get()
+		if rr != nil {
+			remoteStats := RemoteInboundRTPStreamStats{
+				Timestamp:     statsTimestampFrom(rrTime),
+				Type:          StatsTypeRemoteInboundRTP,
+				ID:            fmt.Sprintf("RemoteInboundRTPStream-%d", e.track.SSRC()),
+				SSRC:          e.track.SSRC(),
+				Kind:          kind,
+				TransportID:   transportID,
+				CodecID:       codecID,
+				PacketsLost:   int32(rr.TotalLost),
+				LocalID:       stats.ID,
+				RoundTripTime: roundTripTime,
+				FractionLost:  float64(rr.FractionLost) / 256,
+			}
+			if codec := e.track.Codec(); codec != nil && codec.ClockRate != 0 {
+				remoteStats.Jitter = float64(rr.Jitter) / float64(codec.ClockRate)
+			}
+			report[remoteStats.ID] = remoteStats
+			stats.RemoteID = remoteStats.ID
+		}
+		report[stats.ID] = stats
+	}
+}
+
+// GetStats returns the stats of the RTPSender, its outbound RTP stream and
+// the remote inbound RTP stream learned from the receiver reports of the
+// remote peer. It is empty until the RTPSender has started sending.
+// https://www.w3.org/TR/webrtc/#dom-rtcrtpsender-getstats
+func (r *RTPSender) GetStats() StatsReport {
+	report := StatsReport{}
+	r.collectStats(report, r.Transport().statsID)
+	return report
 }
 
 // hasSent tells if data has been ever sent for this instance
@@ -166,3 +896,13 @@ func (r *RTPSender) hasSent() bool {
 		return false
 	}
 }
+
+// hasStopped tells if Stop has been called for this instance
+func (r *RTPSender) hasStopped() bool {
+	select {
+	case <-r.stopCalled:
+		return true
+	default:
+		return false
+	}
+}
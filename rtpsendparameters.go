@@ -2,5 +2,7 @@ package webrtc
 
 // RTPSendParameters contains the RTP stack settings used by receivers
 type RTPSendParameters struct {
-	Encodings RTPEncodingParameters
+	Encodings        []RTPEncodingParameters
+	Codecs           []RTPCodecParameters
+	HeaderExtensions []RTPHeaderExtensionParameters
 }
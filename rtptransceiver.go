@@ -4,6 +4,9 @@ package webrtc
 
 import (
 	"fmt"
+	"sync"
+
+	"github.com/pion/webrtc/v2/pkg/rtcerr"
 )
 
 // RTPTransceiver represents a combination of an RTPSender and an RTPReceiver that share a common mid.
@@ -17,23 +20,130 @@ type RTPTransceiver struct {
 	// receptive bool
 	stopped bool
 	kind    RTPCodecType
+
+	mediaEngine *MediaEngine
+
+	// codecs are the codec preferences, they are guarded by codecsLock
+	codecs     []*RTPCodec
+	codecsLock sync.RWMutex
+
+	// updateNegotiationNeeded is called when a change of the transceiver
+	// has to be negotiated
+	updateNegotiationNeeded func()
 }
 
-func (t *RTPTransceiver) setSendingTrack(track *Track) error {
-	if track == nil {
-		return fmt.Errorf("track must not be nil")
+// SetDirection changes the direction the transceiver prefers. The change is
+// applied with the next negotiation, OnNegotiationNeeded fires for it.
+// https://w3c.github.io/webrtc-pc/#dom-rtcrtptransceiver-direction
+func (t *RTPTransceiver) SetDirection(direction RTPTransceiverDirection) error {
+	switch direction {
+	case RTPTransceiverDirectionSendrecv, RTPTransceiverDirectionSendonly,
+		RTPTransceiverDirectionRecvonly, RTPTransceiverDirectionInactive:
+	default:
+		return &rtcerr.TypeError{Err: ErrUnknownType}
 	}
 
-	t.Sender.track = track
+	if t.Direction == direction {
+		return nil
+	}
+	t.Direction = direction
+
+	if t.updateNegotiationNeeded != nil {
+		t.updateNegotiationNeeded()
+	}
+	return nil
+}
+
+// SetCodecPreferences restricts the codecs negotiated for the transceiver to
+// codecs, in order of preference. Every codec has to be registered with the
+// MediaEngine. An empty list restores the codecs of the MediaEngine.
+// https://w3c.github.io/webrtc-pc/#dom-rtcrtptransceiver-setcodecpreferences
+func (t *RTPTransceiver) SetCodecPreferences(codecs []RTPCodecCapability) error {
+	preferences := []*RTPCodec{}
+	for _, capability := range codecs {
+		if t.mediaEngine == nil {
+			return &rtcerr.InvalidModificationError{Err: ErrCodecNotFound}
+		}
+
+		codec, err := t.mediaEngine.getCodecByCapability(t.kind, capability)
+		if err != nil {
+			return &rtcerr.InvalidModificationError{Err: err}
+		}
+		preferences = append(preferences, codec)
+	}
+
+	t.codecsLock.Lock()
+	defer t.codecsLock.Unlock()
+	t.codecs = preferences
+	return nil
+}
 
-	switch t.Direction {
-	case RTPTransceiverDirectionRecvonly:
+// getCodecPreferences returns the codecs set with SetCodecPreferences
+func (t *RTPTransceiver) getCodecPreferences() []*RTPCodec {
+	t.codecsLock.RLock()
+	defer t.codecsLock.RUnlock()
+	return t.codecs
+}
+
+// setSendingTrack updates the direction of the transceiver when a track
+// starts or stops being sent, a nil track stops sending
+func (t *RTPTransceiver) setSendingTrack(track *Track) error {
+	if track != nil {
+		track.mu.RLock()
+		isRemote := track.receiver != nil
+		track.mu.RUnlock()
+		if isRemote {
+			return fmt.Errorf("RTPSender can not be constructed with remote track")
+		}
+	}
+
+	switch {
+	case track != nil && t.Direction == RTPTransceiverDirectionRecvonly:
 		t.Direction = RTPTransceiverDirectionSendrecv
-	case RTPTransceiverDirectionInactive:
+	case track != nil && t.Direction == RTPTransceiverDirectionInactive:
 		t.Direction = RTPTransceiverDirectionSendonly
+	case track != nil && t.Direction.hasSend():
+		// The transceiver was created without a track to send
+	case track == nil && t.Direction == RTPTransceiverDirectionSendrecv:
+		t.Direction = RTPTransceiverDirectionRecvonly
+	case track == nil && t.Direction == RTPTransceiverDirectionSendonly:
+		t.Direction = RTPTransceiverDirectionInactive
 	default:
 		return fmt.Errorf("invalid state change in RTPTransceiver.setSending")
 	}
+
+	if track != nil {
+		track.mu.Lock()
+		track.totalSenderCount++
+		track.mu.Unlock()
+	}
+
+	t.Sender.mu.Lock()
+	defer t.Sender.mu.Unlock()
+
+	// The layers of simulcast are kept when the track of the first layer is
+	// replaced, the new track is sent with its RID
+	encodings := trackEncodings(track)
+	dropped := t.Sender.encodings
+	if track != nil && len(dropped) > 1 {
+		track.mu.Lock()
+		track.rid = dropped[0].track.RID()
+		track.mu.Unlock()
+		encodings = append(encodings, dropped[1:]...)
+		dropped = dropped[:1]
+	}
+
+	// Stop already released the tracks of a stopped sender
+	if !t.Sender.hasStopped() {
+		for _, e := range dropped {
+			e.track.mu.Lock()
+			e.track.totalSenderCount--
+			e.track.mu.Unlock()
+		}
+	}
+
+	t.Sender.track = track
+	t.Sender.encodings = encodings
 	return nil
 }
 
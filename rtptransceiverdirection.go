@@ -60,3 +60,13 @@ func (t RTPTransceiverDirection) String() string {
 		return ErrUnknownType.Error()
 	}
 }
+
+// hasSend reports whether the direction includes sending RTP
+func (t RTPTransceiverDirection) hasSend() bool {
+	return t == RTPTransceiverDirectionSendrecv || t == RTPTransceiverDirectionSendonly
+}
+
+// hasRecv reports whether the direction includes receiving RTP
+func (t RTPTransceiverDirection) hasRecv() bool {
+	return t == RTPTransceiverDirectionSendrecv || t == RTPTransceiverDirectionRecvonly
+}
@@ -2,7 +2,16 @@ package webrtc
 
 // RtpTransceiverInit dictionary is used when calling the WebRTC function addTransceiver() to provide configuration options for the new transceiver.
 type RtpTransceiverInit struct {
-	Direction     RTPTransceiverDirection
+	Direction RTPTransceiverDirection
+
+	// SendEncodings with more than one encoding make AddTransceiverFromTrack
+	// send simulcast, a layer for every encoding identified by its RID. The
+	// track is the first layer, RTPSender.Tracks returns the tracks the
+	// layers are written to.
 	SendEncodings []RTPEncodingParameters
-	// Streams       []*Track
+
+	// StreamIDs are the ids of the media streams the sent track belongs to,
+	// they are signaled with the msid attribute. The label of the track is
+	// used when empty.
+	StreamIDs []string
 }
@@ -3,6 +3,7 @@
 package webrtc
 
 import (
+	"strconv"
 	"strings"
 	"testing"
 
@@ -123,6 +124,84 @@ func TestSDPSemantics_PlanBOfferTransceivers(t *testing.T) {
 	assert.ObjectsAreEqual(mdNames, []string{"video", "audio", "data"})
 }
 
+func TestSDPSemantics_PlanBOfferTransceiversFromTrack(t *testing.T) {
+	opc, err := NewPeerConnection(Configuration{
+		SDPSemantics: SDPSemanticsPlanB,
+	})
+	if err != nil {
+		t.Errorf("NewPeerConnection failed: %v", err)
+	}
+
+	// Every transceiver sends the track it was created from
+	for i, codec := range []*RTPCodec{
+		NewRTPH264Codec(DefaultPayloadTypeH264, 90000),
+		NewRTPH264Codec(DefaultPayloadTypeH264, 90000),
+		NewRTPOpusCodec(DefaultPayloadTypeOpus, 48000),
+		NewRTPOpusCodec(DefaultPayloadTypeOpus, 48000),
+	} {
+		id := strconv.Itoa(i + 1)
+		track, err := NewTrack(codec.PayloadType, uint32(i+1), id, id, codec)
+		if err != nil {
+			t.Errorf("Failed to create track")
+		}
+		if _, err = opc.AddTransceiverFromTrack(track, RtpTransceiverInit{
+			Direction: RTPTransceiverDirectionSendrecv,
+		}); err != nil {
+			t.Errorf("AddTransceiverFromTrack failed: %v", err)
+		}
+	}
+
+	offer, err := opc.CreateOffer(nil)
+	if err != nil {
+		t.Errorf("Plan B CreateOffer failed: %s", err)
+	}
+
+	mdNames := getMdNames(offer.parsed)
+	assert.ObjectsAreEqual(mdNames, []string{"video", "audio", "data"})
+
+	// Each section carries the SSRCs of the tracks of its kind
+	for section, ssrcs := range map[string][]string{
+		"video": {"1", "2"},
+		"audio": {"3", "4"},
+	} {
+		for _, media := range offer.parsed.MediaDescriptions {
+			if media.MediaName.Media == section {
+				assert.ElementsMatch(t, ssrcs, extractSsrcList(media))
+			}
+		}
+	}
+
+	apc, err := NewPeerConnection(Configuration{
+		SDPSemantics: SDPSemanticsPlanB,
+	})
+	if err != nil {
+		t.Errorf("NewPeerConnection failed: %v", err)
+	}
+
+	if _, err = apc.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{
+		Direction: RTPTransceiverDirectionRecvonly,
+	}); err != nil {
+		t.Errorf("AddTransceiverFromKind failed: %v", err)
+	}
+	if _, err = apc.AddTransceiverFromKind(RTPCodecTypeAudio, RtpTransceiverInit{
+		Direction: RTPTransceiverDirectionRecvonly,
+	}); err != nil {
+		t.Errorf("AddTransceiverFromKind failed: %v", err)
+	}
+
+	if err = apc.SetRemoteDescription(offer); err != nil {
+		t.Errorf("SetRemoteDescription failed: %s", err)
+	}
+
+	answer, err := apc.CreateAnswer(nil)
+	if err != nil {
+		t.Errorf("Plan B CreateAnswer failed: %s", err)
+	}
+
+	mdNames = getMdNames(answer.parsed)
+	assert.ObjectsAreEqual(mdNames, []string{"video", "audio", "data"})
+}
+
 func TestSDPSemantics_PlanBAnswerSenders(t *testing.T) {
 	opc, err := NewPeerConnection(Configuration{
 		SDPSemantics: SDPSemanticsPlanB,
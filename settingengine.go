@@ -26,6 +26,14 @@ type SettingEngine struct {
 	}
 	candidates struct {
 		ICENetworkTypes []NetworkType
+		ICETrickle      bool
+	}
+	iceRestart struct {
+		Automatic bool
+	}
+	rtcp struct {
+		SenderReportInterval   *time.Duration
+		ReceiverReportInterval *time.Duration
 	}
 	LoggerFactory logging.LoggerFactory
 }
@@ -62,3 +70,39 @@ func (e *SettingEngine) SetEphemeralUDPPortRange(portMin, portMax uint16) error
 func (e *SettingEngine) SetNetworkTypes(candidateTypes []NetworkType) {
 	e.candidates.ICENetworkTypes = candidateTypes
 }
+
+// SetTrickle configures whether or not the ICE agent should gather candidates
+// via the trickle method or synchronously. When enabled gathering starts with
+// SetLocalDescription and candidates are delivered one by one through
+// PeerConnection.OnICECandidate, they have to be signaled to the remote peer
+// in addition to the SessionDescription.
+func (e *SettingEngine) SetTrickle(trickle bool) {
+	e.candidates.ICETrickle = trickle
+}
+
+// SetAutomaticICERestart configures whether the PeerConnection restarts ICE
+// by itself once the ICEConnectionState becomes disconnected or failed, for
+// example after the network of a mobile client changed. The restart is
+// requested like with PeerConnection.RestartICE by the side that is the
+// controlling ICE agent, the application has to complete it by negotiating
+// when OnNegotiationNeeded fires. DTLS, SRTP and SCTP keep running on top of
+// the new candidate pair.
+func (e *SettingEngine) SetAutomaticICERestart(automatic bool) {
+	e.iceRestart.Automatic = automatic
+}
+
+// SetSenderReportInterval configures how often every RTPSender sends an RTCP
+// Sender Report for its track, they allow the remote peer to synchronize
+// tracks and to compute the round trip time. An interval of zero or less
+// disables Sender Reports. It defaults to one second.
+func (e *SettingEngine) SetSenderReportInterval(interval time.Duration) {
+	e.rtcp.SenderReportInterval = &interval
+}
+
+// SetReceiverReportInterval configures how often every RTPReceiver sends an
+// RTCP Receiver Report about its track, senders use them to adapt to packet
+// loss and to compute the round trip time. An interval of zero or less
+// disables Receiver Reports. It defaults to one second.
+func (e *SettingEngine) SetReceiverReportInterval(interval time.Duration) {
+	e.rtcp.ReceiverReportInterval = &interval
+}
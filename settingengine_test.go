@@ -61,3 +61,33 @@ func TestDetachDataChannels(t *testing.T) {
 		t.Fatalf("Failed to enable detached data channels.")
 	}
 }
+
+func TestSetSenderReportInterval(t *testing.T) {
+	s := SettingEngine{}
+
+	if s.rtcp.SenderReportInterval != nil {
+		t.Fatalf("SettingEngine defaults aren't as expected.")
+	}
+
+	s.SetSenderReportInterval(500 * time.Millisecond)
+
+	if s.rtcp.SenderReportInterval == nil ||
+		*s.rtcp.SenderReportInterval != 500*time.Millisecond {
+		t.Fatalf("Sender Report interval does not reflect requested value.")
+	}
+}
+
+func TestSetReceiverReportInterval(t *testing.T) {
+	s := SettingEngine{}
+
+	if s.rtcp.ReceiverReportInterval != nil {
+		t.Fatalf("SettingEngine defaults aren't as expected.")
+	}
+
+	s.SetReceiverReportInterval(500 * time.Millisecond)
+
+	if s.rtcp.ReceiverReportInterval == nil ||
+		*s.rtcp.ReceiverReportInterval != 500*time.Millisecond {
+		t.Fatalf("Receiver Report interval does not reflect requested value.")
+	}
+}
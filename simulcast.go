@@ -0,0 +1,81 @@
+// +build !js
+
+package webrtc
+
+import (
+	"strings"
+
+	"github.com/pion/sdp/v2"
+)
+
+// The directions of the RTP streams signaled with rid and simulcast
+// attributes
+// https://tools.ietf.org/html/rfc8851#section-4
+const (
+	ridDirectionSend = "send"
+	ridDirectionRecv = "recv"
+)
+
+// getMediaSimulcastRIDs returns the RIDs of the simulcast streams a media
+// section signals in direction, in the order of the simulcast attribute.
+// Only the first alternative of a stream is used, paused streams are
+// included.
+// https://tools.ietf.org/html/rfc8853#section-5.1
+func getMediaSimulcastRIDs(media *sdp.MediaDescription, direction string) []string {
+	rids := map[string]bool{}
+	for _, attr := range media.Attributes {
+		if attr.Key != "rid" {
+			continue
+		}
+
+		// a=rid:<rid-id> <direction> [pt=<fmt-list>;<restriction-list>]
+		fields := strings.Fields(attr.Value)
+		if len(fields) >= 2 && fields[1] == direction {
+			rids[fields[0]] = true
+		}
+	}
+
+	for _, attr := range media.Attributes {
+		if attr.Key != "simulcast" {
+			continue
+		}
+
+		// a=simulcast:<direction> <alt-list>;<alt-list> [<direction> ...]
+		fields := strings.Fields(attr.Value)
+		for i := 0; i+1 < len(fields); i += 2 {
+			if fields[i] != direction {
+				continue
+			}
+
+			simulcastRIDs := []string{}
+			for _, stream := range strings.Split(fields[i+1], ";") {
+				rid := strings.TrimPrefix(strings.Split(stream, ",")[0], "~")
+				if rids[rid] {
+					simulcastRIDs = append(simulcastRIDs, rid)
+				}
+			}
+			return simulcastRIDs
+		}
+	}
+	return nil
+}
+
+// addMediaSimulcast signals the simulcast streams with rids that are sent
+// in direction in a media section
+// https://tools.ietf.org/html/rfc8853#section-5.3
+func addMediaSimulcast(media *sdp.MediaDescription, direction string, rids []string) *sdp.MediaDescription {
+	for _, rid := range rids {
+		media = media.WithValueAttribute("rid", rid+" "+direction)
+	}
+	return media.WithValueAttribute("simulcast", direction+" "+strings.Join(rids, ";"))
+}
+
+// mediaHasSSRC tells if a media section signals the SSRCs of its streams
+func mediaHasSSRC(media *sdp.MediaDescription) bool {
+	for _, attr := range media.Attributes {
+		if attr.Key == sdp.AttrKeySSRC {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+// +build !js
+
+package webrtc
+
+import (
+	"testing"
+
+	"github.com/pion/sdp/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetMediaSimulcastRIDs(t *testing.T) {
+	// The first alternative of every stream is used, paused streams too
+	media := (&sdp.MediaDescription{}).
+		WithValueAttribute("rid", "h send pt=96;max-width=1280").
+		WithValueAttribute("rid", "m send").
+		WithValueAttribute("rid", "l send").
+		WithValueAttribute("rid", "x recv").
+		WithValueAttribute("simulcast", "send ~l;m,h;unknown recv x")
+	assert.Equal(t, []string{"l", "m"}, getMediaSimulcastRIDs(media, ridDirectionSend))
+	assert.Equal(t, []string{"x"}, getMediaSimulcastRIDs(media, ridDirectionRecv))
+
+	// RIDs without a simulcast attribute aren't simulcast streams
+	media = (&sdp.MediaDescription{}).WithValueAttribute("rid", "h send")
+	assert.Nil(t, getMediaSimulcastRIDs(media, ridDirectionSend))
+
+	media = addMediaSimulcast(&sdp.MediaDescription{}, ridDirectionRecv, []string{"h", "m", "l"})
+	assert.Equal(t, []string{"h", "m", "l"}, getMediaSimulcastRIDs(media, ridDirectionRecv))
+	assert.Nil(t, getMediaSimulcastRIDs(media, ridDirectionSend))
+}
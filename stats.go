@@ -1,6 +1,8 @@
 package webrtc
 
 import (
+	"fmt"
+	"sync/atomic"
 	"time"
 )
 
@@ -79,6 +81,20 @@ func (s StatsTimestamp) Time() time.Time {
 // StatsReport collects Stats objects indexed by their ID.
 type StatsReport map[string]Stats
 
+// statsIDCounter makes the IDs generated by newStatsID unique
+var statsIDCounter uint64
+
+// newStatsID returns an ID for the Stats objects of a component, it is
+// assigned once so the component keeps its ID across reports
+func newStatsID(prefix string) string {
+	return fmt.Sprintf("%s-%d", prefix, atomic.AddUint64(&statsIDCounter, 1))
+}
+
+// statsTimestampFrom converts a time.Time to a StatsTimestamp
+func statsTimestampFrom(t time.Time) StatsTimestamp {
+	return StatsTimestamp(float64(t.UnixNano()) / float64(time.Millisecond))
+}
+
 // CodecType specifies whether a CodecStats objects represents a media format
 // that is being encoded or decoded
 type CodecType string
@@ -261,6 +277,15 @@ type InboundRTPStreamStats struct {
 	// This counter can also be incremented when receiving FEC packets in-band with media packets (e.g., with Opus).
 	FECPacketsReceived uint32 `json:"fecPacketsReceived"`
 
+	// RetransmittedPacketsReceived is the total number of retransmitted packets
+	// received for this SSRC on its RTX stream. They are not counted in
+	// PacketsReceived, so PacketsLost is the loss of the original packets.
+	RetransmittedPacketsReceived uint32 `json:"retransmittedPacketsReceived"`
+
+	// RetransmittedBytesReceived is the total number of payload bytes of the
+	// packets counted in RetransmittedPacketsReceived.
+	RetransmittedBytesReceived uint64 `json:"retransmittedBytesReceived"`
+
 	// BytesReceived is the total number of bytes received for this SSRC.
 	BytesReceived uint64 `json:"bytesReceived"`
 
@@ -0,0 +1,323 @@
+// +build !js
+
+package webrtc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+)
+
+// rtpStreamCounters counts the packets and payload bytes of an RTP stream
+type rtpStreamCounters struct {
+	lock           sync.RWMutex
+	packets        uint32
+	bytes          uint64
+	lastPacketTime time.Time
+	lastTimestamp  uint32
+}
+
+func (c *rtpStreamCounters) count(header *rtp.Header, payloadLength int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.packets++
+	c.bytes += uint64(payloadLength)
+	c.lastPacketTime = time.Now()
+	c.lastTimestamp = header.Timestamp
+}
+
+// lastPacket returns the RTP timestamp of the latest packet and when it was
+// counted
+func (c *rtpStreamCounters) lastPacket() (timestamp uint32, at time.Time) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.lastTimestamp, c.lastPacketTime
+}
+
+func (c *rtpStreamCounters) get() (packets uint32, bytes uint64, lastPacket StatsTimestamp) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if !c.lastPacketTime.IsZero() {
+		lastPacket = statsTimestampFrom(c.lastPacketTime)
+	}
+	return c.packets, c.bytes, lastPacket
+}
+
+// rtpReceptionStats tracks the loss and interarrival jitter of a received
+// RTP stream
+// https://tools.ietf.org/html/rfc3550#appendix-A.3
+type rtpReceptionStats struct {
+	lock sync.RWMutex
+
+	started  bool
+	baseSeq  uint16
+	maxSeq   uint16
+	cycles   uint32
+	received uint32
+
+	// expectedPrior and receivedPrior are the values at the previous
+	// reception report, they are used to compute the fraction lost
+	expectedPrior uint32
+	receivedPrior uint32
+
+	// lastSenderReport is the middle 32 bits of the NTP timestamp of the
+	// latest Sender Report from the source
+	lastSenderReport     uint32
+	lastSenderReportTime time.Time
+
+	// jitter is in RTP timestamp units, it is computed from the arrival and
+	// RTP timestamp of the previous packet
+	clockRate     uint32
+	haveLast      bool
+	lastArrival   time.Time
+	lastTimestamp uint32
+	jitter        float64
+}
+
+func (s *rtpReceptionStats) update(header *rtp.Header, clockRate uint32, arrival time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.started {
+		s.started = true
+		s.baseSeq = header.SequenceNumber
+		s.maxSeq = header.SequenceNumber
+	} else if delta := header.SequenceNumber - s.maxSeq; delta != 0 && delta < 1<<15 {
+		if header.SequenceNumber < s.maxSeq {
+			s.cycles += 1 << 16
+		}
+		s.maxSeq = header.SequenceNumber
+	}
+	s.received++
+
+	// The clock rate is unknown until the codec has been determined
+	if clockRate == 0 {
+		return
+	}
+	if clockRate != s.clockRate {
+		s.clockRate = clockRate
+		s.haveLast = false
+	}
+
+	// The difference of the transit times is computed from the differences
+	// of the arrivals and of the RTP timestamps, so it is unaffected by the
+	// timestamps wrapping around
+	// https://tools.ietf.org/html/rfc3550#appendix-A.8
+	if s.haveLast {
+		d := arrival.Sub(s.lastArrival).Seconds()*float64(clockRate) - float64(int32(header.Timestamp-s.lastTimestamp))
+		if d < 0 {
+			d = -d
+		}
+		s.jitter += (d - s.jitter) / 16
+	}
+	s.lastArrival = arrival
+	s.lastTimestamp = header.Timestamp
+	s.haveLast = true
+}
+
+// get returns the cumulative number of packets lost and the interarrival
+// jitter in seconds
+func (s *rtpReceptionStats) get() (packetsLost int32, jitter float64) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if !s.started {
+		return 0, 0
+	}
+
+	expected := s.cycles + uint32(s.maxSeq) - uint32(s.baseSeq) + 1
+	packetsLost = int32(expected - s.received)
+	if s.clockRate != 0 {
+		jitter = s.jitter / float64(s.clockRate)
+	}
+	return packetsLost, jitter
+}
+
+// senderReport records the arrival of a Sender Report from the source
+func (s *rtpReceptionStats) senderReport(sr *rtcp.SenderReport, arrival time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.lastSenderReport = uint32(sr.NTPTime >> 16)
+	s.lastSenderReportTime = arrival
+}
+
+// receptionReport returns the report block about the source ssrc for a
+// Receiver Report sent at now. It is false until a packet was received.
+// https://tools.ietf.org/html/rfc3550#section-6.4.1
+func (s *rtpReceptionStats) receptionReport(ssrc uint32, now time.Time) (rtcp.ReceptionReport, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.started {
+		return rtcp.ReceptionReport{}, false
+	}
+
+	extendedMax := s.cycles + uint32(s.maxSeq)
+	expected := extendedMax - uint32(s.baseSeq) + 1
+
+	// The cumulative number of packets lost is a signed 24-bit value, it is
+	// negative when duplicates were received but pion/rtcp can't encode that
+	totalLost := int64(expected) - int64(s.received)
+	if totalLost < 0 {
+		totalLost = 0
+	} else if totalLost > 0x7FFFFF {
+		totalLost = 0x7FFFFF
+	}
+
+	// https://tools.ietf.org/html/rfc3550#appendix-A.3
+	expectedInterval := int64(expected - s.expectedPrior)
+	lostInterval := expectedInterval - int64(s.received-s.receivedPrior)
+	s.expectedPrior = expected
+	s.receivedPrior = s.received
+
+	fractionLost := uint8(0)
+	if expectedInterval != 0 && lostInterval > 0 {
+		fractionLost = uint8((lostInterval << 8) / expectedInterval)
+	}
+
+	report := rtcp.ReceptionReport{
+		SSRC:               ssrc,
+		FractionLost:       fractionLost,
+		TotalLost:          uint32(totalLost),
+		LastSequenceNumber: extendedMax,
+		Jitter:             uint32(s.jitter),
+	}
+	if !s.lastSenderReportTime.IsZero() {
+		report.LastSenderReport = s.lastSenderReport
+		report.Delay = uint32(now.Sub(s.lastSenderReportTime).Seconds() * 65536)
+	}
+	return report, true
+}
+
+// rtcpFeedbackCounters counts the NACK, PLI and FIR packets concerning a
+// media source
+type rtcpFeedbackCounters struct {
+	lock  sync.RWMutex
+	nacks uint32
+	plis  uint32
+	firs  uint32
+}
+
+func (c *rtcpFeedbackCounters) count(pkts []rtcp.Packet, mediaSSRC uint32) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, pkt := range pkts {
+		switch p := pkt.(type) {
+		case *rtcp.TransportLayerNack:
+			if p.MediaSSRC == mediaSSRC {
+				c.nacks++
+			}
+		case *rtcp.PictureLossIndication:
+			if p.MediaSSRC == mediaSSRC {
+				c.plis++
+			}
+		case *rtcp.RawPacket:
+			if isFullIntraRequestFor(*p, mediaSSRC) {
+				c.firs++
+			}
+		}
+	}
+}
+
+// isFullIntraRequestFor tells if raw is a Full Intra Request with an entry
+// for mediaSSRC, pion/rtcp doesn't parse them so they arrive as RawPacket
+// https://tools.ietf.org/html/rfc5104#section-4.3.1
+func isFullIntraRequestFor(raw rtcp.RawPacket, mediaSSRC uint32) bool {
+	const (
+		formatFIR      = 4
+		fciOffset      = 12
+		fciEntryLength = 8
+	)
+
+	header := raw.Header()
+	if header.Type != rtcp.TypePayloadSpecificFeedback || header.Count != formatFIR {
+		return false
+	}
+
+	for i := fciOffset; i+fciEntryLength <= len(raw); i += fciEntryLength {
+		if binary.BigEndian.Uint32(raw[i:]) == mediaSSRC {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *rtcpFeedbackCounters) get() (nacks, plis, firs uint32) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.nacks, c.plis, c.firs
+}
+
+// remoteInboundStats holds the latest reception report the remote peer sent
+// about one of our outbound RTP streams
+type remoteInboundStats struct {
+	lock          sync.RWMutex
+	report        *rtcp.ReceptionReport
+	reportTime    time.Time
+	roundTripTime float64
+}
+
+func (s *remoteInboundStats) update(report rtcp.ReceptionReport, arrival time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.report = &report
+	s.reportTime = arrival
+
+	// https://tools.ietf.org/html/rfc3550#section-6.4.1
+	if report.LastSenderReport != 0 {
+		now := uint32(ntpTime(arrival) >> 16)
+		if elapsed := now - report.LastSenderReport; elapsed >= report.Delay {
+			s.roundTripTime = float64(elapsed-report.Delay) / 65536
+		}
+	}
+}
+
+// get returns the latest reception report, or nil if none has been received
+func (s *remoteInboundStats) get() (report *rtcp.ReceptionReport, reportTime time.Time, roundTripTime float64) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.report, s.reportTime, s.roundTripTime
+}
+
+// ntpTime converts t to the 64-bit NTP timestamp format
+// https://tools.ietf.org/html/rfc3550#section-4
+func ntpTime(t time.Time) uint64 {
+	// seconds between 1900-01-01 and 1970-01-01
+	const ntpEpochOffset = 2208988800
+
+	nsec := uint64(t.UnixNano())
+	secs := nsec/1e9 + ntpEpochOffset
+	frac := (nsec % 1e9) << 32 / 1e9
+	return secs<<32 | frac
+}
+
+// collectCodecStats adds the stats of codec to report and returns their ID
+func collectCodecStats(report StatsReport, codec *RTPCodec, codecType CodecType, transportID string) string {
+	if codec == nil {
+		return ""
+	}
+
+	stats := CodecStats{
+		Timestamp:   statsTimestampFrom(time.Now()),
+		Type:        StatsTypeCodec,
+		ID:          fmt.Sprintf("Codec-%s-%d", codecType, codec.PayloadType),
+		PayloadType: uint32(codec.PayloadType),
+		CodecType:   codecType,
+		TransportID: transportID,
+		MimeType:    codec.MimeType,
+		ClockRate:   codec.ClockRate,
+		Channels:    uint32(codec.Channels),
+		SDPFmtpLine: codec.SDPFmtpLine,
+	}
+	report[stats.ID] = stats
+	return stats.ID
+}
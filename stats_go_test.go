@@ -0,0 +1,380 @@
+// +build !js
+
+package webrtc
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/transport/test"
+	"github.com/pion/webrtc/v2/pkg/media"
+	"github.com/stretchr/testify/assert"
+)
+
+func findStats(report StatsReport, statsType StatsType) []Stats {
+	found := []Stats{}
+	for _, s := range report {
+		switch stats := s.(type) {
+		case TransportStats:
+			if stats.Type == statsType {
+				found = append(found, stats)
+			}
+		case ICECandidatePairStats:
+			if stats.Type == statsType {
+				found = append(found, stats)
+			}
+		case OutboundRTPStreamStats:
+			if stats.Type == statsType {
+				found = append(found, stats)
+			}
+		case InboundRTPStreamStats:
+			if stats.Type == statsType {
+				found = append(found, stats)
+			}
+		case RemoteInboundRTPStreamStats:
+			if stats.Type == statsType {
+				found = append(found, stats)
+			}
+		case DataChannelStats:
+			if stats.Type == statsType {
+				found = append(found, stats)
+			}
+		case PeerConnectionStats:
+			if stats.Type == statsType {
+				found = append(found, stats)
+			}
+		}
+	}
+	return found
+}
+
+func dataChannelStatsByLabel(report StatsReport) map[string]DataChannelStats {
+	dataChannels := map[string]DataChannelStats{}
+	for _, s := range findStats(report, StatsTypeDataChannel) {
+		dataChannels[s.(DataChannelStats).Label] = s.(DataChannelStats)
+	}
+	return dataChannels
+}
+
+func TestPeerConnection_GetStats(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+	assert.NoError(t, err)
+
+	vp8Track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	assert.NoError(t, err)
+	_, err = pcOffer.AddTrack(vp8Track)
+	assert.NoError(t, err)
+
+	dc, err := pcOffer.CreateDataChannel("stats", nil)
+	assert.NoError(t, err)
+	dcOpened := make(chan struct{})
+	dc.OnOpen(func() {
+		close(dcOpened)
+	})
+
+	messageReceived := make(chan struct{})
+	pcAnswer.OnDataChannel(func(d *DataChannel) {
+		if d.Label() != "stats" {
+			return
+		}
+		d.OnMessage(func(DataChannelMessage) {
+			close(messageReceived)
+		})
+	})
+
+	packetReceived := make(chan struct{})
+	pcAnswer.OnTrack(func(track *Track, r *RTPReceiver) {
+		close(packetReceived)
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	func() {
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				assert.NoError(t, vp8Track.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}))
+			case <-packetReceived:
+				return
+			}
+		}
+	}()
+
+	<-dcOpened
+	assert.NoError(t, dc.SendText("hello"))
+	<-messageReceived
+
+	offerReport := pcOffer.GetStats()
+
+	transports := findStats(offerReport, StatsTypeTransport)
+	assert.Len(t, transports, 1)
+	transport := transports[0].(TransportStats)
+	assert.Equal(t, DTLSTransportStateConnected, transport.DTLSState)
+	assert.NotZero(t, transport.BytesSent)
+	assert.NotZero(t, transport.BytesReceived)
+	assert.Contains(t, offerReport, transport.SelectedCandidatePairID)
+	assert.Contains(t, offerReport, transport.LocalCertificateID)
+	assert.Contains(t, offerReport, transport.RemoteCertificateID)
+
+	pair := offerReport[transport.SelectedCandidatePairID].(ICECandidatePairStats)
+	assert.True(t, pair.Nominated)
+	assert.Contains(t, offerReport, pair.LocalCandidateID)
+	assert.Contains(t, offerReport, pair.RemoteCandidateID)
+
+	outbound := findStats(offerReport, StatsTypeOutboundRTP)
+	assert.Len(t, outbound, 1)
+	outboundStats := outbound[0].(OutboundRTPStreamStats)
+	assert.Equal(t, vp8Track.SSRC(), outboundStats.SSRC)
+	assert.NotZero(t, outboundStats.PacketsSent)
+	assert.Equal(t, transport.ID, outboundStats.TransportID)
+	assert.Contains(t, offerReport, outboundStats.CodecID)
+	assert.Contains(t, offerReport, outboundStats.SenderID)
+
+	// signalPair creates a data channel as well
+	dataChannels := dataChannelStatsByLabel(offerReport)
+	assert.Len(t, dataChannels, 2)
+	assert.Equal(t, uint32(1), dataChannels["stats"].MessagesSent)
+	assert.Equal(t, uint64(len("hello")), dataChannels["stats"].BytesSent)
+	assert.Equal(t, DataChannelStateOpen, dataChannels["stats"].State)
+
+	pcStats := findStats(offerReport, StatsTypePeerConnection)
+	assert.Len(t, pcStats, 1)
+	assert.Equal(t, uint32(2), pcStats[0].(PeerConnectionStats).DataChannelsRequested)
+	assert.Equal(t, uint32(2), pcStats[0].(PeerConnectionStats).DataChannelsOpened)
+
+	answerReport := pcAnswer.GetStats()
+
+	inbound := findStats(answerReport, StatsTypeInboundRTP)
+	assert.Len(t, inbound, 1)
+	inboundStats := inbound[0].(InboundRTPStreamStats)
+	assert.Equal(t, vp8Track.SSRC(), inboundStats.SSRC)
+	assert.NotZero(t, inboundStats.PacketsReceived)
+	assert.Contains(t, answerReport, inboundStats.ReceiverID)
+
+	dataChannels = dataChannelStatsByLabel(answerReport)
+	assert.Len(t, dataChannels, 2)
+	assert.Equal(t, uint32(1), dataChannels["stats"].MessagesReceived)
+
+	pcStats = findStats(answerReport, StatsTypePeerConnection)
+	assert.Len(t, pcStats, 1)
+	assert.Equal(t, uint32(2), pcStats[0].(PeerConnectionStats).DataChannelsAccepted)
+
+	// IDs are stable across reports
+	assert.Contains(t, pcOffer.GetStats(), outboundStats.ID)
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestRTPSenderReceiver_GetStats(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	// The reports are written by the test
+	s := SettingEngine{}
+	s.SetReceiverReportInterval(0)
+	api := NewAPI(WithSettingEngine(s))
+	api.mediaEngine.RegisterDefaultCodecs()
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+	assert.NoError(t, err)
+
+	vp8Track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	assert.NoError(t, err)
+	sender, err := pcOffer.AddTrack(vp8Track)
+	assert.NoError(t, err)
+
+	receiverChan := make(chan *RTPReceiver, 1)
+	pcAnswer.OnTrack(func(track *Track, r *RTPReceiver) {
+		receiverChan <- r
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	var receiver *RTPReceiver
+	func() {
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				assert.NoError(t, vp8Track.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}))
+			case receiver = <-receiverChan:
+				return
+			}
+		}
+	}()
+
+	assert.NoError(t, pcAnswer.WriteRTCP([]rtcp.Packet{
+		&rtcp.ReceiverReport{
+			SSRC: 1,
+			Reports: []rtcp.ReceptionReport{{
+				SSRC:         vp8Track.SSRC(),
+				FractionLost: 64,
+				TotalLost:    5,
+				Jitter:       9000,
+			}},
+		},
+		&rtcp.PictureLossIndication{SenderSSRC: 1, MediaSSRC: vp8Track.SSRC()},
+	}))
+
+	// The RTCP is still readable by the application
+	pkts, err := sender.ReadRTCP()
+	assert.NoError(t, err)
+	assert.Len(t, pkts, 2)
+
+	senderReport := sender.GetStats()
+	outbound := findStats(senderReport, StatsTypeOutboundRTP)
+	assert.Len(t, outbound, 1)
+	outboundStats := outbound[0].(OutboundRTPStreamStats)
+	assert.NotZero(t, outboundStats.PacketsSent)
+	assert.Equal(t, uint32(1), outboundStats.PLICount)
+	assert.Contains(t, senderReport, outboundStats.RemoteID)
+
+	remoteInbound := senderReport[outboundStats.RemoteID].(RemoteInboundRTPStreamStats)
+	assert.Equal(t, StatsTypeRemoteInboundRTP, remoteInbound.Type)
+	assert.Equal(t, vp8Track.SSRC(), remoteInbound.SSRC)
+	assert.Equal(t, int32(5), remoteInbound.PacketsLost)
+	assert.Equal(t, 0.25, remoteInbound.FractionLost)
+	assert.Equal(t, 0.1, remoteInbound.Jitter)
+	assert.Equal(t, outboundStats.ID, remoteInbound.LocalID)
+
+	receiverReport := receiver.GetStats()
+	inbound := findStats(receiverReport, StatsTypeInboundRTP)
+	assert.Len(t, inbound, 1)
+	inboundStats := inbound[0].(InboundRTPStreamStats)
+	assert.Equal(t, vp8Track.SSRC(), inboundStats.SSRC)
+	assert.NotZero(t, inboundStats.PacketsReceived)
+	assert.Equal(t, uint32(1), inboundStats.PLICount)
+	assert.Zero(t, inboundStats.NACKCount)
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestDataChannel_GetStats(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	pcOffer, pcAnswer, err := newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dc, err := pcOffer.CreateDataChannel("stats", nil)
+	assert.NoError(t, err)
+	dcOpened := make(chan struct{})
+	dc.OnOpen(func() {
+		close(dcOpened)
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+	<-dcOpened
+	assert.NoError(t, dc.Send([]byte{0x01, 0x02}))
+
+	stats := dc.GetStats()
+	assert.Equal(t, StatsTypeDataChannel, stats.Type)
+	assert.Equal(t, "stats", stats.Label)
+	assert.Equal(t, DataChannelStateOpen, stats.State)
+	assert.Equal(t, uint32(1), stats.MessagesSent)
+	assert.Equal(t, uint64(2), stats.BytesSent)
+	assert.Equal(t, pcOffer.dtlsTransport.statsID, stats.TransportID)
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestRTPReceptionStats(t *testing.T) {
+	s := rtpReceptionStats{}
+	start := time.Now()
+
+	// 65534, 65535, 0 and 2 arrive, 1 is lost across the wrap around
+	for i, seq := range []uint16{65534, 65535, 0, 2} {
+		s.update(&rtp.Header{SequenceNumber: seq, Timestamp: uint32(i) * 900}, 90000, start.Add(time.Duration(i)*10*time.Millisecond))
+	}
+
+	packetsLost, jitter := s.get()
+	assert.Equal(t, int32(1), packetsLost)
+	assert.InDelta(t, 0, jitter, 1e-9)
+
+	// A packet arriving 10ms late adds 900/16 timestamp units of jitter
+	s.update(&rtp.Header{SequenceNumber: 3, Timestamp: 4 * 900}, 90000, start.Add(50*time.Millisecond))
+	_, jitter = s.get()
+	assert.InDelta(t, 900.0/16/90000, jitter, 1e-9)
+
+	// The RTP timestamps wrapping around don't add jitter
+	s = rtpReceptionStats{}
+	for i := 0; i < 4; i++ {
+		s.update(&rtp.Header{SequenceNumber: uint16(i), Timestamp: math.MaxUint32 - 1000 + uint32(i)*900}, 90000, start.Add(time.Duration(i)*10*time.Millisecond))
+	}
+	_, jitter = s.get()
+	assert.InDelta(t, 0, jitter, 1e-9)
+}
+
+func TestIsFullIntraRequestFor(t *testing.T) {
+	fir := rtcp.RawPacket{
+		// V=2, FMT=4, PT=206, length=4
+		0x84, 0xce, 0x00, 0x04,
+		// sender SSRC
+		0x00, 0x00, 0x00, 0x01,
+		// media SSRC, unused
+		0x00, 0x00, 0x00, 0x00,
+		// FCI entry: SSRC and sequence number
+		0x00, 0x00, 0x00, 0x02,
+		0x01, 0x00, 0x00, 0x00,
+	}
+	assert.True(t, isFullIntraRequestFor(fir, 2))
+	assert.False(t, isFullIntraRequestFor(fir, 1))
+
+	counters := rtcpFeedbackCounters{}
+	counters.count([]rtcp.Packet{&fir, &rtcp.TransportLayerNack{MediaSSRC: 2}}, 2)
+	nacks, plis, firs := counters.get()
+	assert.Equal(t, uint32(1), nacks)
+	assert.Equal(t, uint32(0), plis)
+	assert.Equal(t, uint32(1), firs)
+}
+
+func TestRTPReceptionStats_ReceptionReport(t *testing.T) {
+	s := rtpReceptionStats{}
+	start := time.Now()
+
+	_, ok := s.receptionReport(1, start)
+	assert.False(t, ok)
+
+	// 10 of 12 packets arrive
+	for _, seq := range []uint16{100, 101, 102, 104, 105, 106, 107, 108, 110, 111} {
+		s.update(&rtp.Header{SequenceNumber: seq}, 0, start)
+	}
+	s.senderReport(&rtcp.SenderReport{NTPTime: 0x1122334455667788}, start)
+
+	report, ok := s.receptionReport(1, start.Add(500*time.Millisecond))
+	assert.True(t, ok)
+	assert.Equal(t, uint32(1), report.SSRC)
+	assert.Equal(t, uint32(2), report.TotalLost)
+	assert.Equal(t, uint8(2*256/12), report.FractionLost)
+	assert.Equal(t, uint32(111), report.LastSequenceNumber)
+	assert.Equal(t, uint32(0x33445566), report.LastSenderReport)
+	assert.Equal(t, uint32(65536/2), report.Delay)
+
+	// Nothing was lost since the previous report
+	s.update(&rtp.Header{SequenceNumber: 112}, 0, start)
+	report, _ = s.receptionReport(1, start)
+	assert.Equal(t, uint32(2), report.TotalLost)
+	assert.Zero(t, report.FractionLost)
+}
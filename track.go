@@ -26,6 +26,7 @@ type Track struct {
 	kind        RTPCodecType
 	label       string
 	ssrc        uint32
+	rid         string
 	codec       *RTPCodec
 
 	packetizer rtp.Packetizer
@@ -70,6 +71,14 @@ func (t *Track) SSRC() uint32 {
 	return t.ssrc
 }
 
+// RID gets the RTP stream id of the track, it is set for the layers of
+// simulcast that are received
+func (t *Track) RID() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.rid
+}
+
 // Codec gets the Codec of the track
 func (t *Track) Codec() *RTPCodec {
 	t.mu.RLock()
@@ -87,7 +96,7 @@ func (t *Track) Read(b []byte) (n int, err error) {
 	r := t.receiver
 	t.mu.RUnlock()
 
-	return r.readRTP(b)
+	return r.readRTP(b, t)
 }
 
 // ReadRTP is a convenience method that wraps Read and unmarshals for you
@@ -136,6 +145,13 @@ func (t *Track) WriteSample(s media.Sample) error {
 
 // WriteRTP writes RTP packets to the track
 func (t *Track) WriteRTP(p *rtp.Packet) error {
+	return t.WriteRTPWithHeaderExtensions(p)
+}
+
+// WriteRTPWithHeaderExtensions writes RTP packets to the track with header
+// extensions, every RTPSender of the track sends the ones that were
+// negotiated for it with their negotiated IDs
+func (t *Track) WriteRTPWithHeaderExtensions(p *rtp.Packet, extensions ...RTPHeaderExtension) error {
 	t.mu.RLock()
 	if t.receiver != nil {
 		t.mu.RUnlock()
@@ -150,7 +166,7 @@ func (t *Track) WriteRTP(p *rtp.Packet) error {
 	}
 
 	for _, s := range senders {
-		_, err := s.sendRTP(&p.Header, p.Payload)
+		_, err := s.sendRTP(t, &p.Header, p.Payload, extensions)
 		if err != nil {
 			return err
 		}
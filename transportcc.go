@@ -0,0 +1,162 @@
+// +build !js
+
+package webrtc
+
+import (
+	"math"
+	"time"
+
+	"github.com/pion/rtcp"
+)
+
+const (
+	transportCCHeaderSize = 20
+
+	// Received packets are sent in multiples of 250µs after the reference
+	// time, which is a multiple of 64ms
+	transportCCDeltaUnit         = rtcp.TypeTCCDeltaScaleFactor * time.Microsecond
+	transportCCReferenceTimeUnit = 64 * time.Millisecond
+
+	// Packet status chunks hold 14 bits of symbols, runs of the same symbol
+	// are sent as a run length of at most 13 bits
+	transportCCMaxRunLength = 1<<13 - 1
+)
+
+// transportCCStatus tells if a packet was received and its receive delta to
+// the previous one in multiples of transportCCDeltaUnit
+type transportCCStatus struct {
+	received bool
+	delta    int32
+}
+
+// symbol returns the packet status symbol of s
+func (s transportCCStatus) symbol() uint16 {
+	switch {
+	case !s.received:
+		return rtcp.TypeTCCPacketNotReceived
+	case s.delta >= 0 && s.delta <= math.MaxUint8:
+		return rtcp.TypeTCCPacketReceivedSmallDelta
+	default:
+		return rtcp.TypeTCCPacketReceivedLargeDelta
+	}
+}
+
+// clampTransportCCDelta limits delta to what a large receive delta holds
+func clampTransportCCDelta(delta int32) int32 {
+	if delta > math.MaxInt16 {
+		return math.MaxInt16
+	} else if delta < math.MinInt16 {
+		return math.MinInt16
+	}
+	return delta
+}
+
+// newTransportLayerCC creates transport-cc feedback with a status for every
+// packet from baseSequenceNumber on, the statuses are sent in status vector
+// chunks of 2-bit symbols unless they are part of a long run. The deltas of
+// statuses have to fit a large receive delta.
+// https://tools.ietf.org/html/draft-holmer-rmcat-transport-wide-cc-extensions-01#section-3.1
+func newTransportLayerCC(senderSSRC, mediaSSRC uint32, baseSequenceNumber uint16, referenceTime int32, count uint8, statuses []transportCCStatus) *rtcp.TransportLayerCC {
+	f := &rtcp.TransportLayerCC{
+		SenderSSRC:         senderSSRC,
+		MediaSSRC:          mediaSSRC,
+		BaseSequenceNumber: baseSequenceNumber,
+		PacketStatusCount:  uint16(len(statuses)),
+		ReferenceTime:      uint32(referenceTime) & 0xFFFFFF,
+		FbPktCount:         count,
+	}
+
+	for i := 0; i < len(statuses); {
+		symbol := statuses[i].symbol()
+		run := 1
+		for i+run < len(statuses) && run < transportCCMaxRunLength && statuses[i+run].symbol() == symbol {
+			run++
+		}
+
+		if run >= 7 {
+			f.PacketChunks = append(f.PacketChunks, &rtcp.RunLengthChunk{
+				Type:               rtcp.TypeTCCRunLengthChunk,
+				PacketStatusSymbol: symbol,
+				RunLength:          uint16(run),
+			})
+		} else {
+			chunk := &rtcp.StatusVectorChunk{
+				Type:       rtcp.TypeTCCStatusVectorChunk,
+				SymbolSize: rtcp.TypeTCCSymbolSizeTwoBit,
+			}
+			for run = 0; run < 7 && i+run < len(statuses); run++ {
+				chunk.SymbolList = append(chunk.SymbolList, statuses[i+run].symbol())
+			}
+			f.PacketChunks = append(f.PacketChunks, chunk)
+		}
+		i += run
+	}
+
+	size := transportCCHeaderSize + 2*len(f.PacketChunks)
+	for _, s := range statuses {
+		symbol := s.symbol()
+		if symbol == rtcp.TypeTCCPacketNotReceived {
+			continue
+		}
+
+		f.RecvDeltas = append(f.RecvDeltas, &rtcp.RecvDelta{
+			Type:  symbol,
+			Delta: int64(s.delta) * rtcp.TypeTCCDeltaScaleFactor,
+		})
+		if symbol == rtcp.TypeTCCPacketReceivedSmallDelta {
+			size++
+		} else {
+			size += 2
+		}
+	}
+
+	// The last byte of the padding is its length
+	// https://tools.ietf.org/html/rfc3550#section-6.4.1
+	padding := (4 - size%4) % 4
+	f.Header = rtcp.Header{
+		Padding: padding != 0,
+		Count:   rtcp.FormatTCC,
+		Type:    rtcp.TypeTransportSpecificFeedback,
+		Length:  uint16((size+padding)/4 - 1),
+	}
+	return f
+}
+
+// transportCCReferenceTime returns the signed 24-bit reference time of f
+func transportCCReferenceTime(f *rtcp.TransportLayerCC) time.Duration {
+	return time.Duration(int32(f.ReferenceTime<<8)>>8) * transportCCReferenceTimeUnit
+}
+
+// transportCCStatuses returns the status of every packet f reports on
+func transportCCStatuses(f *rtcp.TransportLayerCC) []transportCCStatus {
+	symbols := make([]uint16, 0, f.PacketStatusCount)
+	for _, chunk := range f.PacketChunks {
+		switch c := chunk.(type) {
+		case *rtcp.RunLengthChunk:
+			for run := c.RunLength; run > 0 && len(symbols) < int(f.PacketStatusCount); run-- {
+				symbols = append(symbols, c.PacketStatusSymbol)
+			}
+		case *rtcp.StatusVectorChunk:
+			for _, symbol := range c.SymbolList {
+				if len(symbols) == int(f.PacketStatusCount) {
+					break
+				}
+				symbols = append(symbols, symbol)
+			}
+		}
+	}
+
+	statuses := make([]transportCCStatus, len(symbols))
+	deltas := f.RecvDeltas
+	for i, symbol := range symbols {
+		if symbol != rtcp.TypeTCCPacketReceivedSmallDelta && symbol != rtcp.TypeTCCPacketReceivedLargeDelta {
+			continue
+		}
+		if len(deltas) == 0 {
+			return statuses[:i]
+		}
+		statuses[i] = transportCCStatus{received: true, delta: int32(deltas[0].Delta / rtcp.TypeTCCDeltaScaleFactor)}
+		deltas = deltas[1:]
+	}
+	return statuses
+}
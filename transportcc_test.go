@@ -0,0 +1,70 @@
+// +build !js
+
+package webrtc
+
+import (
+	"testing"
+
+	"github.com/pion/rtcp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransportLayerCC_Roundtrip(t *testing.T) {
+	statuses := []transportCCStatus{
+		{received: true, delta: 4},
+		{},
+		{received: true, delta: 300},
+		{received: true, delta: -2},
+	}
+	for i := 0; i < 20; i++ {
+		statuses = append(statuses, transportCCStatus{})
+	}
+	statuses = append(statuses, transportCCStatus{received: true, delta: 255})
+
+	f := newTransportLayerCC(1, 5000, 65530, -3, 7, statuses)
+	raw, err := f.Marshal()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(raw)%4)
+	assert.Equal(t, int(f.Header.Length+1)*4, len(raw))
+
+	pkts, err := rtcp.Unmarshal(raw)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(pkts))
+	parsed, ok := pkts[0].(*rtcp.TransportLayerCC)
+	assert.True(t, ok)
+
+	assert.Equal(t, uint32(1), parsed.SenderSSRC)
+	assert.Equal(t, []uint32{5000}, parsed.DestinationSSRC())
+	assert.Equal(t, uint16(65530), parsed.BaseSequenceNumber)
+	assert.Equal(t, uint8(7), parsed.FbPktCount)
+	assert.Equal(t, -3*transportCCReferenceTimeUnit, transportCCReferenceTime(parsed))
+	assert.Equal(t, statuses, transportCCStatuses(parsed))
+}
+
+func TestTransportCCStatuses(t *testing.T) {
+	// A status vector chunk of 1-bit symbols and a run length chunk
+	raw := []byte{
+		0x8F, 0xCD, 0x00, 0x06,
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x13, 0x88,
+		0x00, 0x0A, 0x00, 0x11,
+		0x00, 0x00, 0x10, 0x02,
+		0xA0, 0x00, 0x20, 0x03,
+		0x01, 0x02, 0x03, 0x04,
+	}
+	f := &rtcp.TransportLayerCC{}
+	assert.NoError(t, f.Unmarshal(raw))
+	assert.Equal(t, uint16(10), f.BaseSequenceNumber)
+	assert.Equal(t, 16*transportCCReferenceTimeUnit, transportCCReferenceTime(f))
+	assert.Equal(t, uint8(2), f.FbPktCount)
+
+	statuses := transportCCStatuses(f)
+	assert.Equal(t, 17, len(statuses))
+	assert.Equal(t, transportCCStatus{received: true, delta: 1}, statuses[0])
+	for i := 1; i < 14; i++ {
+		assert.Equal(t, transportCCStatus{}, statuses[i])
+	}
+	assert.Equal(t, transportCCStatus{received: true, delta: 2}, statuses[14])
+	assert.Equal(t, transportCCStatus{received: true, delta: 3}, statuses[15])
+	assert.Equal(t, transportCCStatus{received: true, delta: 4}, statuses[16])
+}
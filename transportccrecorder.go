@@ -0,0 +1,124 @@
+// +build !js
+
+package webrtc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+)
+
+// transportCCInterval is how often transport-cc feedback is sent
+const transportCCInterval = 100 * time.Millisecond
+
+// transportCCRecorder records when the packets of a transport arrive by
+// their transport-wide sequence number and turns them into transport-cc
+// feedback
+type transportCCRecorder struct {
+	lock sync.Mutex
+
+	// start is the origin of the reference times
+	start time.Time
+
+	// The sequence numbers are extended to tell the ones that wrapped
+	// around apart. next is the first one of the next feedback.
+	started  bool
+	highest  int64
+	next     int64
+	arrivals map[int64]time.Time
+
+	count uint8
+}
+
+func newTransportCCRecorder() *transportCCRecorder {
+	return &transportCCRecorder{
+		start:    time.Now(),
+		arrivals: map[int64]time.Time{},
+	}
+}
+
+// record records a packet that arrived at now, packets that have already
+// been reported as lost are ignored
+func (r *transportCCRecorder) record(sequenceNumber uint16, now time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.started {
+		r.started = true
+		r.highest = int64(sequenceNumber)
+		r.next = r.highest
+	}
+
+	extended := r.highest + int64(int16(sequenceNumber-uint16(r.highest)))
+	if extended < r.next {
+		return
+	}
+	if extended > r.highest {
+		r.highest = extended
+	}
+	r.arrivals[extended] = now
+}
+
+// pending tells if packets arrived since the last feedback
+func (r *transportCCRecorder) pending() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return len(r.arrivals) != 0
+}
+
+// feedback returns the feedback for the packets since the last feedback, it
+// is nil if none arrived
+func (r *transportCCRecorder) feedback(senderSSRC, mediaSSRC uint32) *rtcp.TransportLayerCC {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if len(r.arrivals) == 0 {
+		return nil
+	}
+
+	// A feedback holds at most a run length of statuses, the packets before
+	// are considered lost
+	if r.highest-r.next >= transportCCMaxRunLength {
+		for sequenceNumber := range r.arrivals {
+			if sequenceNumber <= r.highest-transportCCMaxRunLength {
+				delete(r.arrivals, sequenceNumber)
+			}
+		}
+		r.next = r.highest - transportCCMaxRunLength + 1
+		if len(r.arrivals) == 0 {
+			return nil
+		}
+	}
+
+	var first time.Time
+	for sequenceNumber := r.next; sequenceNumber <= r.highest; sequenceNumber++ {
+		if arrival, ok := r.arrivals[sequenceNumber]; ok {
+			first = arrival
+			break
+		}
+	}
+
+	// The deltas are rounded and clamped, the time they add up to is kept so
+	// the errors don't add up too
+	referenceTime := int32(first.Sub(r.start) / transportCCReferenceTimeUnit)
+	last := r.start.Add(time.Duration(referenceTime) * transportCCReferenceTimeUnit)
+	statuses := make([]transportCCStatus, 0, r.highest-r.next+1)
+	for sequenceNumber := r.next; sequenceNumber <= r.highest; sequenceNumber++ {
+		arrival, ok := r.arrivals[sequenceNumber]
+		if !ok {
+			statuses = append(statuses, transportCCStatus{})
+			continue
+		}
+
+		delta := clampTransportCCDelta(int32(arrival.Sub(last) / transportCCDeltaUnit))
+		last = last.Add(time.Duration(delta) * transportCCDeltaUnit)
+		statuses = append(statuses, transportCCStatus{received: true, delta: delta})
+	}
+
+	f := newTransportLayerCC(senderSSRC, mediaSSRC, uint16(r.next), referenceTime, r.count, statuses)
+	r.next = r.highest + 1
+	r.arrivals = map[int64]time.Time{}
+	r.count++
+	return f
+}
@@ -0,0 +1,64 @@
+// +build !js
+
+package webrtc
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransportCCRecorder(t *testing.T) {
+	r := newTransportCCRecorder()
+	assert.False(t, r.pending())
+	assert.Nil(t, r.feedback(1, 5000))
+
+	// The sequence numbers wrap around, 65535 is lost and 1 arrives late
+	start := r.start.Add(130 * time.Millisecond)
+	r.record(65533, start)
+	r.record(65534, start.Add(2*time.Millisecond))
+	r.record(0, start.Add(70*time.Millisecond))
+	r.record(2, start.Add(69*time.Millisecond))
+	r.record(1, start.Add(71*time.Millisecond))
+	assert.True(t, r.pending())
+
+	f := r.feedback(1, 5000)
+	assert.Equal(t, newTransportLayerCC(1, 5000, 65533, 2, 0, []transportCCStatus{
+		{received: true, delta: 8},
+		{received: true, delta: 8},
+		{},
+		{received: true, delta: 272},
+		{received: true, delta: 4},
+		{received: true, delta: -8},
+	}), f)
+	assert.False(t, r.pending())
+
+	// Packets that were reported as lost are ignored, the next feedback
+	// starts after the last one
+	r.record(65535, start.Add(100*time.Millisecond))
+	assert.False(t, r.pending())
+	r.record(5, start.Add(200*time.Millisecond))
+	f = r.feedback(1, 5000)
+	assert.Equal(t, uint16(3), f.BaseSequenceNumber)
+	assert.Equal(t, uint8(1), f.FbPktCount)
+	assert.Equal(t, []transportCCStatus{{}, {}, {received: true, delta: 40}}, transportCCStatuses(f))
+}
+
+func TestTransportCCRecorder_LargeDelta(t *testing.T) {
+	r := newTransportCCRecorder()
+
+	// The delta of the second packet doesn't fit, it is clamped and the
+	// delta of the third packet is relative to the time that was reported
+	r.record(0, r.start)
+	r.record(1, r.start.Add(10*time.Second))
+	r.record(2, r.start.Add(10*time.Second+time.Millisecond))
+
+	statuses := transportCCStatuses(r.feedback(1, 5000))
+	assert.Equal(t, []transportCCStatus{
+		{received: true, delta: 0},
+		{received: true, delta: math.MaxInt16},
+		{received: true, delta: 40000 + 4 - math.MaxInt16},
+	}, statuses)
+}